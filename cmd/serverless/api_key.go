@@ -6,14 +6,23 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/util/retry"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/kms"
-	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 )
 
@@ -23,15 +32,107 @@ const encryptionContextKey = "LambdaFunctionName"
 // functionNameEnvVar is the environment variable that stores the function name.
 const functionNameEnvVar = "AWS_LAMBDA_FUNCTION_NAME"
 
+// encryptionContextEnvVar is the environment variable that stores additional encryption context pairs,
+// formatted as k1=v1,k2=v2
+const encryptionContextEnvVar = "DD_KMS_ENCRYPTION_CONTEXT"
+
+// regionEnvVar is the environment variable that stores the region of the function.
+const regionEnvVar = "AWS_REGION"
+
+// strictSecretRegionEnvVar is the environment variable that makes reading a secret stored in another
+// region than the function's one fail, instead of logging a warning.
+const strictSecretRegionEnvVar = "DD_SECRET_REGION_STRICT"
+
 // kmsKeySuffix is the suffix of all environment variables which should be decrypted by KMS
 const kmsKeySuffix = "_KMS_ENCRYPTED"
 
 // secretArnSuffix is the suffix of all environment variables which should be decrypted by secrets manager
 const secretArnSuffix = "_SECRET_ARN"
 
+// awsMaxAttempts is the maximum number of attempts of a KMS or Secrets Manager call failing
+// because of throttling or a timeout. It is a variable so that tests can change it.
+var awsMaxAttempts = 3
+
+// awsInitialRetryDelay is the delay before the first retry of a KMS or Secrets Manager call,
+// doubled at each new retry. It is a variable so that tests can shrink it.
+var awsInitialRetryDelay = 100 * time.Millisecond
+
+// withAWSRetry calls fn until it succeeds, up to awsMaxAttempts times with an exponential backoff.
+// Only the throttling and timeout errors are retried, the other errors are returned right away.
+func withAWSRetry(name string, fn func() error) error {
+	var retrier retry.Retrier
+	err := retrier.SetupRetrier(&retry.Config{
+		Name:              name,
+		AttemptMethod:     fn,
+		Strategy:          retry.Backoff,
+		InitialRetryDelay: awsInitialRetryDelay,
+		MaxRetryDelay:     awsInitialRetryDelay << awsMaxAttempts,
+	})
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		retryErr := retrier.TriggerRetry()
+		if retryErr == nil {
+			return nil
+		}
+		if attempt >= awsMaxAttempts || !isRetryableAWSError(retryErr.LastTryError) {
+			return retryErr.LastTryError
+		}
+		log.Debugf("%s failed, retrying: %v", name, retryErr.LastTryError)
+		time.Sleep(time.Until(retrier.NextRetry()))
+	}
+}
+
+// isRetryableAWSError returns whether an AWS call failed because of throttling or a timeout
+func isRetryableAWSError(err error) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case request.ErrCodeResponseTimeout, "RequestTimeout", "RequestTimeoutException":
+			return true
+		}
+		if request.IsErrorThrottle(awsErr) {
+			return true
+		}
+		err = awsErr.OrigErr()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// kmsDecrypter is the subset of the KMS client used to decrypt API keys.
+// It is satisfied by the v1 kmsiface.KMSAPI and by kmsDecryptFunc.
+type kmsDecrypter interface {
+	Decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, error)
+}
+
+// kmsDecryptFunc adapts a KMS client with the AWS SDK v2 calling convention (context,
+// plain encryption context map) to the kmsDecrypter interface.
+type kmsDecryptFunc func(ctx context.Context, ciphertext []byte, encryptionContext map[string]string) ([]byte, error)
+
+// Decrypt implements kmsDecrypter
+func (f kmsDecryptFunc) Decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	var encryptionContext map[string]string
+	if len(input.EncryptionContext) > 0 {
+		encryptionContext = make(map[string]string, len(input.EncryptionContext))
+		for k, v := range input.EncryptionContext {
+			if v != nil {
+				encryptionContext[k] = *v
+			}
+		}
+	}
+	plaintext, err := f(context.TODO(), input.CiphertextBlob, encryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.DecryptOutput{Plaintext: plaintext}, nil
+}
+
 // decryptKMS decodes and deciphers the base64-encoded ciphertext given as a parameter using KMS.
 // For this to work properly, the Lambda function must have the appropriate IAM permissions.
-func decryptKMS(kmsClient kmsiface.KMSAPI, ciphertext string) (string, error) {
+func decryptKMS(kmsClient kmsDecrypter, ciphertext string) (string, error) {
 	decodedBytes, err := base64.StdEncoding.DecodeString(ciphertext)
 	if err != nil {
 		return "", fmt.Errorf("Failed to decode ciphertext from base64: %v", err)
@@ -39,32 +140,72 @@ func decryptKMS(kmsClient kmsiface.KMSAPI, ciphertext string) (string, error) {
 
 	// When the API key is encrypted using the AWS console, the function name is added as an
 	// encryption context. When the API key is encrypted using the AWS CLI, no encryption context
-	// is added. We need to try decrypting the API key both with and without the encryption context.
+	// is added, unless some pairs are given, which are then read from DD_KMS_ENCRYPTION_CONTEXT.
+	// We need to try decrypting the API key with each of these encryption contexts.
+	extraContext, err := parseEncryptionContext(os.Getenv(encryptionContextEnvVar))
+	if err != nil {
+		log.Warnf("Ignoring the KMS encryption context: %v", err)
+		extraContext = nil
+	}
 
-	// Try without encryption context, in case API key was encrypted using the AWS CLI
+	// Try without encryption context first, in case API key was encrypted using the AWS CLI
+	encryptionContexts := []map[string]*string{nil}
+	if len(extraContext) > 0 {
+		// API key encrypted using the AWS CLI with the additional context pairs
+		encryptionContexts = append(encryptionContexts, extraContext)
+	}
+	// API key encrypted using the AWS Console, along with the additional context pairs
 	functionName := os.Getenv(functionNameEnvVar)
-	params := &kms.DecryptInput{
-		CiphertextBlob: decodedBytes,
+	consoleContext := map[string]*string{encryptionContextKey: &functionName}
+	for k, v := range extraContext {
+		consoleContext[k] = v
 	}
-	response, err := kmsClient.Decrypt(params)
+	encryptionContexts = append(encryptionContexts, consoleContext)
 
-	if err != nil {
-		log.Debug("Failed to decrypt ciphertext without encryption context, retrying with encryption context")
-		// Try with encryption context, in case API key was encrypted using the AWS Console
-		params = &kms.DecryptInput{
-			CiphertextBlob: decodedBytes,
-			EncryptionContext: map[string]*string{
-				encryptionContextKey: &functionName,
-			},
+	for i, encryptionContext := range encryptionContexts {
+		params := &kms.DecryptInput{
+			CiphertextBlob:    decodedBytes,
+			EncryptionContext: encryptionContext,
 		}
-		response, err = kmsClient.Decrypt(params)
-		if err != nil {
-			return "", fmt.Errorf("Failed to decrypt ciphertext with kms: %v", err)
+		var response *kms.DecryptOutput
+		response, err = decryptWithRetry(kmsClient, params)
+		if err == nil {
+			return string(response.Plaintext), nil
+		}
+		if i < len(encryptionContexts)-1 {
+			log.Debug("Failed to decrypt ciphertext, retrying with another encryption context")
 		}
 	}
+	return "", fmt.Errorf("Failed to decrypt ciphertext with kms: %v", err)
+}
 
-	plaintext := string(response.Plaintext)
-	return plaintext, nil
+// parseEncryptionContext parses encryption context pairs formatted as k1=v1,k2=v2
+func parseEncryptionContext(value string) (map[string]*string, error) {
+	encryptionContext := make(map[string]*string)
+	if value == "" {
+		return encryptionContext, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || key == "" {
+			return nil, fmt.Errorf("Invalid encryption context pair %q in %s, expected key=value", pair, encryptionContextEnvVar)
+		}
+		val := strings.TrimSpace(kv[1])
+		encryptionContext[key] = &val
+	}
+	return encryptionContext, nil
+}
+
+// decryptWithRetry calls Decrypt, retrying on throttling and timeout errors
+func decryptWithRetry(kmsClient kmsDecrypter, params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	var response *kms.DecryptOutput
+	err := withAWSRetry("KMS decrypt", func() error {
+		var err error
+		response, err = kmsClient.Decrypt(params)
+		return err
+	})
+	return response, err
 }
 
 // readAPIKeyFromKMS gets and decrypts an API key encrypted with KMS if the env var DD_KMS_API_KEY has been set.
@@ -85,6 +226,38 @@ func readAPIKeyFromKMS(cipherText string) (string, error) {
 	return plaintext, nil
 }
 
+// readAPIKeyFromSecretsManagerWithJSONKey reads an API Key from AWS Secrets Manager, the same way
+// readAPIKeyFromSecretsManager does. When jsonKey is not empty, the secret is expected to be a JSON
+// object and the API key is read from its jsonKey field.
+func readAPIKeyFromSecretsManagerWithJSONKey(arn string, jsonKey string) (string, error) {
+	secret, err := readAPIKeyFromSecretsManager(arn)
+	if err != nil || secret == "" {
+		return secret, err
+	}
+	return extractSecretJSONKey(arn, secret, jsonKey)
+}
+
+// extractSecretJSONKey returns the value of the jsonKey field of the JSON object stored in secret,
+// or the secret itself when jsonKey is empty.
+func extractSecretJSONKey(arn string, secret string, jsonKey string) (string, error) {
+	if jsonKey == "" {
+		return secret, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("Secrets Manager secret %s is not a valid JSON object: %s", arn, err)
+	}
+	rawValue, found := fields[jsonKey]
+	if !found {
+		return "", fmt.Errorf("Secrets Manager secret %s has no key %s", arn, jsonKey)
+	}
+	var value string
+	if err := json.Unmarshal(rawValue, &value); err != nil {
+		return "", fmt.Errorf("Secrets Manager secret %s has a non string value for key %s", arn, jsonKey)
+	}
+	return value, nil
+}
+
 // readAPIKeyFromSecretsManager reads an API Key from AWS Secrets Manager if the env var DD_API_KEY_SECRET_ARN has been set.
 // If none has been set, it returns an empty string and a nil error.
 func readAPIKeyFromSecretsManager(arn string) (string, error) {
@@ -92,6 +265,9 @@ func readAPIKeyFromSecretsManager(arn string) (string, error) {
 		return "", nil
 	}
 	log.Debugf("Found %s value, trying to use it.", arn)
+	if err := validateSecretRegion(arn, os.Getenv(regionEnvVar)); err != nil {
+		return "", err
+	}
 	sess, err := session.NewSession(nil)
 	if err != nil {
 		return "", err
@@ -100,7 +276,12 @@ func readAPIKeyFromSecretsManager(arn string) (string, error) {
 	secret := &secretsmanager.GetSecretValueInput{}
 	secret.SetSecretId(arn)
 
-	output, err := secretsManagerClient.GetSecretValue(secret)
+	var output *secretsmanager.GetSecretValueOutput
+	err = withAWSRetry("Secrets Manager read", func() error {
+		var readErr error
+		output, readErr = secretsManagerClient.GetSecretValue(secret)
+		return readErr
+	})
 	if err != nil {
 		return "", fmt.Errorf("Secrets Manager read error: %s", err)
 	}
@@ -120,3 +301,35 @@ func readAPIKeyFromSecretsManager(arn string) (string, error) {
 	log.Warn("Secrets Manager returned something but there seems to be no data available")
 	return "", nil
 }
+
+// extractRegionFromSecretsManagerArn returns the region of a Secrets Manager secret ARN,
+// formatted as arn:partition:secretsmanager:region:account-id:secret:name
+func extractRegionFromSecretsManagerArn(secretArn string) (string, error) {
+	parts := strings.Split(secretArn, ":")
+	if len(parts) < 7 || parts[0] != "arn" || parts[2] != "secretsmanager" {
+		return "", fmt.Errorf("%s is not a valid Secrets Manager ARN", secretArn)
+	}
+	return parts[3], nil
+}
+
+// validateSecretRegion checks that a secret is stored in the region of the function, reading
+// it from another region adding latency. A region mismatch is logged as a warning, unless
+// DD_SECRET_REGION_STRICT is enabled, in which case an error is returned.
+// Secrets referenced by name rather than by ARN are always in the region of the function.
+func validateSecretRegion(secretArn, functionRegion string) error {
+	if functionRegion == "" || !strings.HasPrefix(secretArn, "arn:") {
+		return nil
+	}
+	secretRegion, err := extractRegionFromSecretsManagerArn(secretArn)
+	if err != nil {
+		return err
+	}
+	if secretRegion == functionRegion {
+		return nil
+	}
+	if strict, _ := strconv.ParseBool(os.Getenv(strictSecretRegionEnvVar)); strict {
+		return fmt.Errorf("secret %s is stored in region %s, but the function runs in region %s", secretArn, secretRegion, functionRegion)
+	}
+	log.Warnf("Secret %s is stored in region %s, but the function runs in region %s: reading it adds latency", secretArn, secretRegion, functionRegion)
+	return nil
+}
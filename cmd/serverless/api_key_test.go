@@ -7,10 +7,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 	"github.com/stretchr/testify/assert"
@@ -78,3 +81,263 @@ func TestDecryptKMSNoEncryptionContext(t *testing.T) {
 	result, _ := decryptKMS(client, mockEncryptedAPIKeyBase64)
 	assert.Equal(t, expectedDecryptedAPIKey, result)
 }
+
+// mockKMSDecryptV2 mimics the behavior of a KMS client using the AWS SDK v2 calling convention
+func mockKMSDecryptV2(withEncryptionContext bool) kmsDecryptFunc {
+	return func(ctx context.Context, ciphertext []byte, encryptionContext map[string]string) ([]byte, error) {
+		functionName, exists := encryptionContext[encryptionContextKey]
+		if exists != withEncryptionContext {
+			return nil, errors.New("InvalidCiphertextException")
+		}
+		if withEncryptionContext && functionName != mockFunctionName {
+			return nil, errors.New("InvalidCiphertextException")
+		}
+		if bytes.Equal(ciphertext, []byte(mockDecodedEncryptedAPIKey)) {
+			return []byte(expectedDecryptedAPIKey), nil
+		}
+		return nil, errors.New("KMS error")
+	}
+}
+
+func TestDecryptKMSV2Adapter(t *testing.T) {
+	t.Run("with encryption context", func(t *testing.T) {
+		t.Setenv(functionNameEnvVar, mockFunctionName)
+		result, err := decryptKMS(mockKMSDecryptV2(true), mockEncryptedAPIKeyBase64)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedDecryptedAPIKey, result)
+	})
+
+	t.Run("without encryption context", func(t *testing.T) {
+		result, err := decryptKMS(mockKMSDecryptV2(false), mockEncryptedAPIKeyBase64)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedDecryptedAPIKey, result)
+	})
+
+	t.Run("decryption error", func(t *testing.T) {
+		_, err := decryptKMS(mockKMSDecryptV2(false), "MzMzMw==")
+		assert.Error(t, err)
+	})
+}
+
+func TestExtractSecretJSONKey(t *testing.T) {
+	arn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:my-secret"
+
+	tests := []struct {
+		name          string
+		secret        string
+		jsonKey       string
+		expected      string
+		expectedError string
+	}{
+		{name: "raw string secret", secret: "my-api-key", expected: "my-api-key"},
+		{name: "raw JSON secret without key", secret: `{"api_key":"my-api-key"}`, expected: `{"api_key":"my-api-key"}`},
+		{name: "JSON keyed secret", secret: `{"api_key":"my-api-key","app_key":"my-app-key"}`, jsonKey: "api_key", expected: "my-api-key"},
+		{name: "malformed JSON", secret: "my-api-key", jsonKey: "api_key", expectedError: "secret " + arn + " is not a valid JSON object"},
+		{name: "missing key", secret: `{"app_key":"my-app-key"}`, jsonKey: "api_key", expectedError: "secret " + arn + " has no key api_key"},
+		{name: "non string value", secret: `{"api_key":42}`, jsonKey: "api_key", expectedError: "secret " + arn + " has a non string value for key api_key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := extractSecretJSONKey(arn, tt.secret, tt.jsonKey)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+// mockKMSClientFlaky fails the first failures calls to Decrypt with err, then decrypts the API key
+type mockKMSClientFlaky struct {
+	kmsiface.KMSAPI
+	failures int
+	err      error
+	calls    int
+}
+
+func (m *mockKMSClientFlaky) Decrypt(params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	m.calls++
+	if m.calls <= m.failures {
+		return nil, m.err
+	}
+	return mockKMSClientNoEncryptionContext{}.Decrypt(params)
+}
+
+func shrinkAWSRetryDelay(t *testing.T) {
+	initialRetryDelay := awsInitialRetryDelay
+	awsInitialRetryDelay = time.Millisecond
+	t.Cleanup(func() { awsInitialRetryDelay = initialRetryDelay })
+}
+
+func TestDecryptKMSRetriesOnThrottling(t *testing.T) {
+	shrinkAWSRetryDelay(t)
+
+	client := &mockKMSClientFlaky{
+		failures: 2,
+		err:      awserr.New("ThrottlingException", "Rate exceeded", nil),
+	}
+	result, err := decryptKMS(client, mockEncryptedAPIKeyBase64)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedDecryptedAPIKey, result)
+	assert.Equal(t, 3, client.calls)
+}
+
+func TestDecryptKMSFailsFastOnInvalidCiphertext(t *testing.T) {
+	shrinkAWSRetryDelay(t)
+
+	client := &mockKMSClientFlaky{
+		failures: 10,
+		err:      awserr.New(kms.ErrCodeInvalidCiphertextException, "", nil),
+	}
+	_, err := decryptKMS(client, mockEncryptedAPIKeyBase64)
+	assert.Error(t, err)
+	// one call without and one call with the encryption context, none of them retried
+	assert.Equal(t, 2, client.calls)
+}
+
+func TestDecryptKMSGivesUpAfterMaxAttempts(t *testing.T) {
+	shrinkAWSRetryDelay(t)
+
+	client := &mockKMSClientFlaky{
+		failures: 10,
+		err:      awserr.New("RequestTimeout", "", nil),
+	}
+	_, err := decryptKMS(client, mockEncryptedAPIKeyBase64)
+	assert.Error(t, err)
+	assert.Equal(t, 2*awsMaxAttempts, client.calls)
+}
+
+func TestIsRetryableAWSError(t *testing.T) {
+	assert.True(t, isRetryableAWSError(awserr.New("ThrottlingException", "", nil)))
+	assert.True(t, isRetryableAWSError(awserr.New("RequestTimeout", "", nil)))
+	assert.False(t, isRetryableAWSError(awserr.New(kms.ErrCodeInvalidCiphertextException, "", nil)))
+	assert.False(t, isRetryableAWSError(errors.New("KMS error")))
+}
+
+func TestValidateSecretRegion(t *testing.T) {
+	secretArn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:my-secret"
+
+	t.Run("same region", func(t *testing.T) {
+		t.Setenv(strictSecretRegionEnvVar, "true")
+		assert.NoError(t, validateSecretRegion(secretArn, "us-east-1"))
+	})
+
+	t.Run("cross region warning", func(t *testing.T) {
+		t.Setenv(strictSecretRegionEnvVar, "")
+		assert.NoError(t, validateSecretRegion(secretArn, "eu-west-1"))
+	})
+
+	t.Run("cross region error", func(t *testing.T) {
+		t.Setenv(strictSecretRegionEnvVar, "true")
+		assert.ErrorContains(t, validateSecretRegion(secretArn, "eu-west-1"), "stored in region us-east-1")
+	})
+
+	t.Run("secret name", func(t *testing.T) {
+		t.Setenv(strictSecretRegionEnvVar, "true")
+		assert.NoError(t, validateSecretRegion("my-secret", "eu-west-1"))
+	})
+
+	t.Run("invalid arn", func(t *testing.T) {
+		assert.Error(t, validateSecretRegion("arn:aws:kms:us-east-1", "eu-west-1"))
+	})
+}
+
+func TestExtractRegionFromSecretsManagerArn(t *testing.T) {
+	region, err := extractRegionFromSecretsManagerArn("arn:aws:secretsmanager:us-east-1:123456789012:secret:my-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1", region)
+
+	_, err = extractRegionFromSecretsManagerArn("arn:aws:kms:us-east-1:123456789012:key/my-key")
+	assert.Error(t, err)
+}
+
+// mockKMSClientWithExtraEncryptionContext only decrypts the API key with the team=serverless context pair
+type mockKMSClientWithExtraEncryptionContext struct {
+	kmsiface.KMSAPI
+}
+
+func (mockKMSClientWithExtraEncryptionContext) Decrypt(params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	team, exists := params.EncryptionContext["team"]
+	if !exists || *team != "serverless" {
+		return nil, errors.New("InvalidCiphertextException")
+	}
+	return mockKMSClientWithEncryptionContext{}.Decrypt(params)
+}
+
+func TestDecryptKMSWithExtraEncryptionContext(t *testing.T) {
+	t.Setenv(functionNameEnvVar, mockFunctionName)
+	t.Setenv(encryptionContextEnvVar, "team=serverless, env=prod")
+
+	client := mockKMSClientWithExtraEncryptionContext{}
+	result, err := decryptKMS(client, mockEncryptedAPIKeyBase64)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedDecryptedAPIKey, result)
+}
+
+// mockKMSClientWithOnlyExtraEncryptionContext only decrypts the API key with the team=serverless context pair
+// and without the function name, like keys encrypted using the AWS CLI with an encryption context
+type mockKMSClientWithOnlyExtraEncryptionContext struct {
+	kmsiface.KMSAPI
+}
+
+func (mockKMSClientWithOnlyExtraEncryptionContext) Decrypt(params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	team, exists := params.EncryptionContext["team"]
+	if !exists || *team != "serverless" {
+		return nil, errors.New("InvalidCiphertextException")
+	}
+	return mockKMSClientNoEncryptionContext{}.Decrypt(params)
+}
+
+func TestDecryptKMSWithOnlyExtraEncryptionContext(t *testing.T) {
+	t.Setenv(functionNameEnvVar, mockFunctionName)
+	t.Setenv(encryptionContextEnvVar, "team=serverless")
+
+	client := mockKMSClientWithOnlyExtraEncryptionContext{}
+	result, err := decryptKMS(client, mockEncryptedAPIKeyBase64)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedDecryptedAPIKey, result)
+}
+
+func TestDecryptKMSWithMalformedEncryptionContext(t *testing.T) {
+	t.Setenv(functionNameEnvVar, mockFunctionName)
+	t.Setenv(encryptionContextEnvVar, "team=serverless,env")
+
+	// the malformed context is ignored, keys needing no context pair still decrypt
+	result, err := decryptKMS(mockKMSClientNoEncryptionContext{}, mockEncryptedAPIKeyBase64)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedDecryptedAPIKey, result)
+
+	result, err = decryptKMS(mockKMSClientWithEncryptionContext{}, mockEncryptedAPIKeyBase64)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedDecryptedAPIKey, result)
+}
+
+func TestParseEncryptionContext(t *testing.T) {
+	team, env := "serverless", "prod"
+	tests := []struct {
+		name     string
+		value    string
+		expected map[string]*string
+		wantErr  bool
+	}{
+		{name: "empty", value: "", expected: map[string]*string{}},
+		{name: "single pair", value: "team=serverless", expected: map[string]*string{"team": &team}},
+		{name: "multiple pairs", value: "team=serverless, env=prod", expected: map[string]*string{"team": &team, "env": &env}},
+		{name: "missing value", value: "team", wantErr: true},
+		{name: "missing key", value: "=serverless", wantErr: true},
+		{name: "trailing comma", value: "team=serverless,", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encryptionContext, err := parseEncryptionContext(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, encryptionContext)
+		})
+	}
+}
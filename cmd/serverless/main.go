@@ -30,12 +30,13 @@ import (
 )
 
 var (
-	kmsAPIKeyEnvVar            = "DD_KMS_API_KEY"
-	secretsManagerAPIKeyEnvVar = "DD_API_KEY_SECRET_ARN"
-	apiKeyEnvVar               = "DD_API_KEY"
-	logLevelEnvVar             = "DD_LOG_LEVEL"
-	flushStrategyEnvVar        = "DD_SERVERLESS_FLUSH_STRATEGY"
-	logsLogsTypeSubscribed     = "DD_LOGS_CONFIG_LAMBDA_LOGS_TYPE"
+	kmsAPIKeyEnvVar             = "DD_KMS_API_KEY"
+	secretsManagerAPIKeyEnvVar  = "DD_API_KEY_SECRET_ARN"
+	secretsManagerJSONKeyEnvVar = "DD_API_KEY_SECRET_JSON_KEY"
+	apiKeyEnvVar                = "DD_API_KEY"
+	logLevelEnvVar              = "DD_LOG_LEVEL"
+	flushStrategyEnvVar         = "DD_SERVERLESS_FLUSH_STRATEGY"
+	logsLogsTypeSubscribed      = "DD_LOGS_CONFIG_LAMBDA_LOGS_TYPE"
 
 	// AWS Lambda is writing the Lambda function files in /var/task, we want the
 	// configuration file to be at the root of this directory.
@@ -171,7 +172,7 @@ func runAgent(stopCh chan struct{}) (serverlessDaemon *daemon.Daemon, err error)
 	// try to read the API key from Secrets Manager, only if not set from KMS
 
 	if apiKey == "" {
-		if apiKey, err = readAPIKeyFromSecretsManager(os.Getenv(secretsManagerAPIKeyEnvVar)); err != nil {
+		if apiKey, err = readAPIKeyFromSecretsManagerWithJSONKey(os.Getenv(secretsManagerAPIKeyEnvVar), os.Getenv(secretsManagerJSONKeyEnvVar)); err != nil {
 			log.Errorf("Error while trying to read an API Key from Secrets Manager: %s", err)
 		} else if apiKey != "" {
 			log.Info("Using API key set in Secrets Manager.")
@@ -128,6 +128,8 @@ func InitSystemProbeConfig(cfg Config) {
 	cfg.BindEnvAndSetDefault(join(spNS, "enable_conntrack_all_namespaces"), true, "DD_SYSTEM_PROBE_ENABLE_CONNTRACK_ALL_NAMESPACES")
 	cfg.BindEnvAndSetDefault(join(netNS, "ignore_conntrack_init_failure"), false, "DD_SYSTEM_PROBE_NETWORK_IGNORE_CONNTRACK_INIT_FAILURE")
 	cfg.BindEnvAndSetDefault(join(netNS, "conntrack_init_timeout"), 10*time.Second)
+	cfg.BindEnvAndSetDefault(join(netNS, "enable_conntrack_state_size_stats"), false, "DD_SYSTEM_PROBE_NETWORK_ENABLE_CONNTRACK_STATE_SIZE_STATS")
+	cfg.BindEnvAndSetDefault(join(netNS, "conntrack_disable_ipv4"), false, "DD_SYSTEM_PROBE_NETWORK_CONNTRACK_DISABLE_IPV4")
 
 	cfg.BindEnvAndSetDefault(join(spNS, "source_excludes"), map[string][]string{})
 	cfg.BindEnvAndSetDefault(join(spNS, "dest_excludes"), map[string][]string{})
@@ -153,6 +155,8 @@ func InitSystemProbeConfig(cfg Config) {
 
 	// windows config
 	cfg.BindEnvAndSetDefault(join(spNS, "windows.enable_monotonic_count"), false)
+	// list of interface indices to restrict the driver flow filters to, all the interfaces if empty
+	cfg.BindEnvAndSetDefault(join(spNS, "windows.interface_indices"), []string{})
 
 	// oom_kill module
 	cfg.BindEnvAndSetDefault(join(spNS, "enable_oom_kill"), false)
@@ -33,3 +33,9 @@ func FormatMask(ipAddr []byte, maskRawValue uint32) string {
 	}
 	return maskedIP.String() + maskSuffix
 }
+
+// FormatFlowMasks formats both source and destination masks of a flow into CIDR format.
+// Each end is formatted independently, so source and destination can be of different IP families.
+func FormatFlowMasks(srcIP []byte, srcMask uint32, dstIP []byte, dstMask uint32) (srcCIDR, dstCIDR string) {
+	return FormatMask(srcIP, srcMask), FormatMask(dstIP, dstMask)
+}
@@ -80,3 +80,59 @@ func TestFormatMask(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatFlowMasks(t *testing.T) {
+	tests := []struct {
+		name            string
+		srcIP           []byte
+		srcMask         uint32
+		dstIP           []byte
+		dstMask         uint32
+		expectedSrcCIDR string
+		expectedDstCIDR string
+	}{
+		{
+			name:            "ipv4 source and ipv4 destination",
+			srcIP:           []byte{192, 1, 128, 108},
+			srcMask:         26,
+			dstIP:           []byte{10, 0, 5, 3},
+			dstMask:         16,
+			expectedSrcCIDR: "192.1.128.64/26",
+			expectedDstCIDR: "10.0.0.0/16",
+		},
+		{
+			name:            "ipv4 source and ipv6 destination",
+			srcIP:           []byte{192, 1, 128, 108},
+			srcMask:         26,
+			dstIP:           net.ParseIP("2001:0DB8:ABCD:0012:0000:0000:0000:0010"),
+			dstMask:         112,
+			expectedSrcCIDR: "192.1.128.64/26",
+			expectedDstCIDR: "2001:db8:abcd:12::/112",
+		},
+		{
+			name:            "ipv6 source and ipv4 destination",
+			srcIP:           net.ParseIP("::1"),
+			srcMask:         127,
+			dstIP:           []byte{192, 1, 128, 54},
+			dstMask:         25,
+			expectedSrcCIDR: "::/127",
+			expectedDstCIDR: "192.1.128.0/25",
+		},
+		{
+			name:            "invalid source and valid destination",
+			srcIP:           []byte{},
+			srcMask:         20,
+			dstIP:           []byte{192, 1, 128, 54},
+			dstMask:         25,
+			expectedSrcCIDR: "/20",
+			expectedDstCIDR: "192.1.128.0/25",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcCIDR, dstCIDR := FormatFlowMasks(tt.srcIP, tt.srcMask, tt.dstIP, tt.dstMask)
+			assert.Equal(t, tt.expectedSrcCIDR, srcCIDR)
+			assert.Equal(t, tt.expectedDstCIDR, dstCIDR)
+		})
+	}
+}
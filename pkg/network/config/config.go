@@ -6,6 +6,7 @@
 package config
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
@@ -135,6 +136,14 @@ type Config struct {
 	// default is true
 	EnableConntrackAllNamespaces bool
 
+	// EnableConntrackStateSizeStats enables reporting the live size of the eBPF conntrack map and its saturation,
+	// at the cost of iterating over the whole map on each stats collection
+	EnableConntrackStateSizeStats bool
+
+	// ConntrackDisableIPv4 disables the tracking of IPv4 NAT translations by the eBPF conntracker,
+	// for hosts only running IPv6. IPv6 translations are tracked if CollectIPv6Conns is set.
+	ConntrackDisableIPv4 bool
+
 	// ClosedChannelSize specifies the size for closed channel for the tracer
 	ClosedChannelSize int
 
@@ -150,6 +159,10 @@ type Config struct {
 	// EnableMonotonicCount (Windows only) determines if we will calculate send/recv bytes of connections with headers and retransmits
 	EnableMonotonicCount bool
 
+	// InterfaceIndices (Windows only) restricts the flows collected by the driver to the given interfaces.
+	// Flows from all the interfaces are collected if empty
+	InterfaceIndices []uint64
+
 	// EnableGatewayLookup enables looking up gateway information for connection destinations
 	EnableGatewayLookup bool
 
@@ -160,6 +173,20 @@ type Config struct {
 	HTTPReplaceRules []*ReplaceRule
 }
 
+// parseInterfaceIndices parses the configured interface indices, skipping the invalid ones
+func parseInterfaceIndices(values []string) []uint64 {
+	var indices []uint64
+	for _, v := range values {
+		index, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			log.Warnf("ignoring invalid interface index %q: %s", v, err)
+			continue
+		}
+		indices = append(indices, index)
+	}
+	return indices
+}
+
 func join(pieces ...string) string {
 	return strings.Join(pieces, ".")
 }
@@ -205,16 +232,19 @@ func New() *Config {
 		EnableHTTPSMonitoring: cfg.GetBool(join(netNS, "enable_https_monitoring")),
 		MaxHTTPStatsBuffered:  100000,
 
-		EnableConntrack:              cfg.GetBool(join(spNS, "enable_conntrack")),
-		ConntrackMaxStateSize:        cfg.GetInt(join(spNS, "conntrack_max_state_size")),
-		ConntrackRateLimit:           cfg.GetInt(join(spNS, "conntrack_rate_limit")),
-		EnableConntrackAllNamespaces: cfg.GetBool(join(spNS, "enable_conntrack_all_namespaces")),
-		IgnoreConntrackInitFailure:   cfg.GetBool(join(netNS, "ignore_conntrack_init_failure")),
-		ConntrackInitTimeout:         cfg.GetDuration(join(netNS, "conntrack_init_timeout")),
+		EnableConntrack:               cfg.GetBool(join(spNS, "enable_conntrack")),
+		ConntrackMaxStateSize:         cfg.GetInt(join(spNS, "conntrack_max_state_size")),
+		ConntrackRateLimit:            cfg.GetInt(join(spNS, "conntrack_rate_limit")),
+		EnableConntrackAllNamespaces:  cfg.GetBool(join(spNS, "enable_conntrack_all_namespaces")),
+		IgnoreConntrackInitFailure:    cfg.GetBool(join(netNS, "ignore_conntrack_init_failure")),
+		ConntrackInitTimeout:          cfg.GetDuration(join(netNS, "conntrack_init_timeout")),
+		EnableConntrackStateSizeStats: cfg.GetBool(join(netNS, "enable_conntrack_state_size_stats")),
+		ConntrackDisableIPv4:          cfg.GetBool(join(netNS, "conntrack_disable_ipv4")),
 
 		EnableGatewayLookup: cfg.GetBool(join(netNS, "enable_gateway_lookup")),
 
 		EnableMonotonicCount: cfg.GetBool(join(spNS, "windows.enable_monotonic_count")),
+		InterfaceIndices:     parseInterfaceIndices(cfg.GetStringSlice(join(spNS, "windows.interface_indices"))),
 
 		RecordedQueryTypes: cfg.GetStringSlice(join(netNS, "dns_recorded_query_types")),
 	}
@@ -325,3 +325,22 @@ func TestMaxClosedConnectionsBuffered(t *testing.T) {
 		require.Equal(t, int(cfg.MaxTrackedConnections), cfg.MaxClosedConnectionsBuffered)
 	})
 }
+
+func TestInterfaceIndices(t *testing.T) {
+	newConfig()
+	defer restoreGlobalConfig()
+
+	t.Run("default", func(t *testing.T) {
+		cfg := New()
+		assert.Empty(t, cfg.InterfaceIndices)
+	})
+
+	t.Run("via ENV variable", func(t *testing.T) {
+		newConfig()
+		os.Setenv("DD_SYSTEM_PROBE_CONFIG_WINDOWS_INTERFACE_INDICES", "3 7 foo")
+		defer os.Unsetenv("DD_SYSTEM_PROBE_CONFIG_WINDOWS_INTERFACE_INDICES")
+
+		cfg := New()
+		assert.Equal(t, []uint64{3, 7}, cfg.InterfaceIndices)
+	})
+}
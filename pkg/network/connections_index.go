@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package network
+
+import (
+	"inet.af/netaddr"
+)
+
+// connTuple is the normalized tuple used to index connections
+type connTuple struct {
+	src   netaddr.IPPort
+	dst   netaddr.IPPort
+	proto ConnectionType
+}
+
+// newConnTuple builds a normalized tuple, unmapping IPv4-in-6 addresses and dropping zones
+// so that the same connection is always indexed under the same key
+func newConnTuple(src, dst netaddr.IPPort, proto ConnectionType) connTuple {
+	return connTuple{
+		src:   netaddr.IPPortFrom(src.IP().Unmap().WithZone(""), src.Port()),
+		dst:   netaddr.IPPortFrom(dst.IP().Unmap().WithZone(""), dst.Port()),
+		proto: proto,
+	}
+}
+
+// ConnectionsIndex indexes a slice of connections by their tuple, to avoid linear scans
+// when the same set of connections is looked up repeatedly (e.g. during enrichment)
+type ConnectionsIndex struct {
+	conns map[connTuple]*ConnectionStats
+}
+
+// NewConnectionsIndex builds an index over the given connections.
+// The index references the elements of the slice, which must not be modified while the index is in use.
+// If several connections share the same tuple, the last one wins.
+func NewConnectionsIndex(conns []ConnectionStats) *ConnectionsIndex {
+	idx := &ConnectionsIndex{
+		conns: make(map[connTuple]*ConnectionStats, len(conns)),
+	}
+	for i := range conns {
+		c := &conns[i]
+		src := netaddr.IPPortFrom(c.Source.IP, c.SPort)
+		dst := netaddr.IPPortFrom(c.Dest.IP, c.DPort)
+		idx.conns[newConnTuple(src, dst, c.Type)] = c
+	}
+	return idx
+}
+
+// Lookup returns the connection matching the given tuple, if any
+func (idx *ConnectionsIndex) Lookup(src, dst netaddr.IPPort, proto ConnectionType) (*ConnectionStats, bool) {
+	c, ok := idx.conns[newConnTuple(src, dst, proto)]
+	return c, ok
+}
+
+// Len returns the number of indexed connections
+func (idx *ConnectionsIndex) Len() int {
+	return len(idx.conns)
+}
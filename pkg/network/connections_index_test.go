@@ -0,0 +1,89 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package network
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/process/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"inet.af/netaddr"
+)
+
+func testIndexedConns() []ConnectionStats {
+	return []ConnectionStats{
+		{
+			Pid:    1,
+			Type:   TCP,
+			Family: AFINET,
+			Source: util.AddressFromString("10.0.0.1"),
+			Dest:   util.AddressFromString("10.0.0.2"),
+			SPort:  40000,
+			DPort:  443,
+		},
+		{
+			Pid:    2,
+			Type:   UDP,
+			Family: AFINET,
+			Source: util.AddressFromString("10.0.0.1"),
+			Dest:   util.AddressFromString("10.0.0.2"),
+			SPort:  40000,
+			DPort:  443,
+		},
+		{
+			Pid:    3,
+			Type:   TCP,
+			Family: AFINET6,
+			Source: util.AddressFromString("fd00::1"),
+			Dest:   util.AddressFromString("fd00::2"),
+			SPort:  50000,
+			DPort:  8080,
+		},
+	}
+}
+
+func TestConnectionsIndexLookupIPv4(t *testing.T) {
+	idx := NewConnectionsIndex(testIndexedConns())
+	assert.Equal(t, 3, idx.Len())
+
+	c, ok := idx.Lookup(netaddr.MustParseIPPort("10.0.0.1:40000"), netaddr.MustParseIPPort("10.0.0.2:443"), TCP)
+	require.True(t, ok)
+	assert.Equal(t, uint32(1), c.Pid)
+
+	c, ok = idx.Lookup(netaddr.MustParseIPPort("10.0.0.1:40000"), netaddr.MustParseIPPort("10.0.0.2:443"), UDP)
+	require.True(t, ok)
+	assert.Equal(t, uint32(2), c.Pid)
+
+	// IPv4-in-6 addresses are normalized
+	c, ok = idx.Lookup(netaddr.MustParseIPPort("[::ffff:10.0.0.1]:40000"), netaddr.MustParseIPPort("[::ffff:10.0.0.2]:443"), TCP)
+	require.True(t, ok)
+	assert.Equal(t, uint32(1), c.Pid)
+}
+
+func TestConnectionsIndexLookupIPv6(t *testing.T) {
+	idx := NewConnectionsIndex(testIndexedConns())
+
+	c, ok := idx.Lookup(netaddr.MustParseIPPort("[fd00::1]:50000"), netaddr.MustParseIPPort("[fd00::2]:8080"), TCP)
+	require.True(t, ok)
+	assert.Equal(t, uint32(3), c.Pid)
+}
+
+func TestConnectionsIndexLookupNotFound(t *testing.T) {
+	idx := NewConnectionsIndex(testIndexedConns())
+
+	_, ok := idx.Lookup(netaddr.MustParseIPPort("10.0.0.1:40000"), netaddr.MustParseIPPort("10.0.0.2:444"), TCP)
+	assert.False(t, ok)
+	// the tuple isn't matched in the reverse direction
+	_, ok = idx.Lookup(netaddr.MustParseIPPort("10.0.0.2:443"), netaddr.MustParseIPPort("10.0.0.1:40000"), TCP)
+	assert.False(t, ok)
+	_, ok = idx.Lookup(netaddr.MustParseIPPort("[fd00::1]:50000"), netaddr.MustParseIPPort("[fd00::2]:8080"), UDP)
+	assert.False(t, ok)
+
+	_, ok = NewConnectionsIndex(nil).Lookup(netaddr.MustParseIPPort("10.0.0.1:40000"), netaddr.MustParseIPPort("10.0.0.2:443"), TCP)
+	assert.False(t, ok)
+}
@@ -10,18 +10,20 @@ import (
 	"unsafe"
 )
 
-// TCPFlow returns the TCP-specific flow data
-func (f PerFlowData) TCPFlow() *TCPFlowData {
-	if f.Protocol == syscall.IPPROTO_TCP {
-		return (*TCPFlowData)(unsafe.Pointer(&f.U[0]))
+// TCPData returns the TCP-specific flow data stored in the union of the flow,
+// ok is false if the flow isn't a TCP flow
+func (f *PerFlowData) TCPData() (data TCPFlowData, ok bool) {
+	if f.Protocol != syscall.IPPROTO_TCP {
+		return TCPFlowData{}, false
 	}
-	return nil
+	return *(*TCPFlowData)(unsafe.Pointer(&f.U[0])), true
 }
 
-// UDPFlow returns the UDP-specific flow data
-func (f PerFlowData) UDPFlow() *UDPFlowData {
-	if f.Protocol == syscall.IPPROTO_UDP {
-		return (*UDPFlowData)(unsafe.Pointer(&f.U[0]))
+// UDPData returns the UDP-specific flow data stored in the union of the flow,
+// ok is false if the flow isn't a UDP flow
+func (f *PerFlowData) UDPData() (data UDPFlowData, ok bool) {
+	if f.Protocol != syscall.IPPROTO_UDP {
+		return UDPFlowData{}, false
 	}
-	return nil
+	return *(*UDPFlowData)(unsafe.Pointer(&f.U[0])), true
 }
@@ -0,0 +1,41 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package driver
+
+import (
+	"encoding/binary"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerFlowDataTCPData(t *testing.T) {
+	f := PerFlowData{Protocol: syscall.IPPROTO_TCP}
+	binary.LittleEndian.PutUint64(f.U[0:], 100)
+	binary.LittleEndian.PutUint64(f.U[8:], 200)
+	binary.LittleEndian.PutUint64(f.U[16:], 30)
+	binary.LittleEndian.PutUint64(f.U[24:], 4)
+
+	data, ok := f.TCPData()
+	assert.True(t, ok)
+	assert.Equal(t, TCPFlowData{IRTT: 100, SRTT: 200, RttVariance: 30, RetransmitCount: 4}, data)
+
+	_, ok = f.UDPData()
+	assert.False(t, ok)
+}
+
+func TestPerFlowDataUDPData(t *testing.T) {
+	f := PerFlowData{Protocol: syscall.IPPROTO_UDP}
+	binary.LittleEndian.PutUint64(f.U[0:], 42)
+
+	data, ok := f.UDPData()
+	assert.True(t, ok)
+	assert.Equal(t, UDPFlowData{Reserved: 42}, data)
+
+	_, ok = f.TCPData()
+	assert.False(t, ok)
+}
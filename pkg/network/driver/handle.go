@@ -11,6 +11,7 @@ package driver
 import (
 	"encoding/binary"
 	"fmt"
+	"sync"
 	"unsafe"
 
 	"github.com/DataDog/datadog-agent/pkg/util/log"
@@ -62,6 +63,10 @@ type Handle struct {
 	windows.Handle
 	handleType HandleType
 
+	// statsLock guards the fields below, GetStatsForHandle being called concurrently by
+	// the connections check and the expvar stats
+	statsLock sync.Mutex
+
 	// record the last value of number of flows missed due to max exceeded
 	lastNumFlowsMissed uint64
 }
@@ -132,17 +137,28 @@ func (dh *Handle) SetDataFilters(filters []FilterDefinition) error {
 
 // GetStatsForHandle gets the relevant stats depending on the handle type
 func (dh *Handle) GetStatsForHandle() (map[string]int64, error) {
-	var (
-		bytesReturned uint32
-		statbuf       = make([]byte, DriverStatsSize)
-	)
+	stats, err := dh.getDriverStats()
+	if err != nil {
+		return nil, err
+	}
+	return dh.statsToMap(stats)
+}
 
-	err := windows.DeviceIoControl(dh.Handle, GetStatsIOCTL, &ddAPIVersionBuf[0], uint32(len(ddAPIVersionBuf)), &statbuf[0], uint32(len(statbuf)), &bytesReturned, nil)
+// GetStatsAndHTTPStatsForHandle gets the same stats as GetStatsForHandle along with the total HTTP stats
+// of the driver, both derived from a single stats query
+func (dh *Handle) GetStatsAndHTTPStatsForHandle() (map[string]int64, map[string]int64, error) {
+	stats, err := dh.getDriverStats()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read driver stats for filter type %v - returned error %v", dh.handleType, err)
+		return nil, nil, err
 	}
-	stats := *(*DriverStats)(unsafe.Pointer(&statbuf[0]))
+	handleStats, err := dh.statsToMap(stats)
+	if err != nil {
+		return nil, nil, err
+	}
+	return handleStats, httpStatsToMap(stats.Total.Http_stats), nil
+}
 
+func (dh *Handle) statsToMap(stats DriverStats) (map[string]int64, error) {
 	switch dh.handleType {
 
 	// A stats handle returns the total values of the driver
@@ -168,10 +184,14 @@ func (dh *Handle) GetStatsForHandle() (map[string]int64, error) {
 		}, nil
 	// A FlowHandle handle returns the flow stats specific to this handle
 	case FlowHandle:
+		dh.statsLock.Lock()
+		defer dh.statsLock.Unlock()
+
 		if dh.lastNumFlowsMissed < uint64(stats.Handle.Flow_stats.Num_flows_missed_max_exceeded) {
 			log.Warnf("Flows missed due to maximum flow limit. %v", stats.Handle.Flow_stats.Num_flows_missed_max_exceeded)
 		}
 		dh.lastNumFlowsMissed = uint64(stats.Handle.Flow_stats.Num_flows_missed_max_exceeded)
+
 		return map[string]int64{
 			"read_calls":                    stats.Handle.Handle_stats.Read_calls,
 			"read_calls_outstanding":        stats.Handle.Handle_stats.Read_calls_outstanding,
@@ -209,3 +229,26 @@ func (dh *Handle) GetStatsForHandle() (map[string]int64, error) {
 		return nil, fmt.Errorf("no matching handle type for pulling handle stats")
 	}
 }
+
+func (dh *Handle) getDriverStats() (DriverStats, error) {
+	var (
+		bytesReturned uint32
+		statbuf       = make([]byte, DriverStatsSize)
+	)
+
+	err := windows.DeviceIoControl(dh.Handle, GetStatsIOCTL, &ddAPIVersionBuf[0], uint32(len(ddAPIVersionBuf)), &statbuf[0], uint32(len(statbuf)), &bytesReturned, nil)
+	if err != nil {
+		return DriverStats{}, fmt.Errorf("failed to read driver stats for filter type %v - returned error %v", dh.handleType, err)
+	}
+	return *(*DriverStats)(unsafe.Pointer(&statbuf[0])), nil
+}
+
+func httpStatsToMap(stats HttpStats) map[string]int64 {
+	return map[string]int64{
+		"packets_processed":             stats.Packets_processed,
+		"num_flow_collisions":           stats.Num_flow_collisions,
+		"num_flows_missed_max_exceeded": stats.Num_flows_missed_max_exceeded,
+		"read_batch_skipped":            stats.Read_batch_skipped,
+		"batches_reported":              stats.Batches_reported,
+	}
+}
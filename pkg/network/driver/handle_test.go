@@ -0,0 +1,33 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows
+// +build windows
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPStatsToMap(t *testing.T) {
+	stats := HttpStats{
+		Packets_processed:             500,
+		Num_flow_collisions:           3,
+		Num_flows_missed_max_exceeded: 7,
+		Read_batch_skipped:            2,
+		Batches_reported:              42,
+	}
+
+	assert.Equal(t, map[string]int64{
+		"packets_processed":             500,
+		"num_flow_collisions":           3,
+		"num_flows_missed_max_exceeded": 7,
+		"read_batch_skipped":            2,
+		"batches_reported":              42,
+	}, httpStatsToMap(stats))
+}
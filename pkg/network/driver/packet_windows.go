@@ -0,0 +1,42 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package driver
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// ParsePacketBatch walks a buffer of packet capture records, each made of a FilterPacketHeader
+// followed by PktSize bytes of packet data, and returns the headers along with their packets.
+// The returned packets share the memory of the given buffer.
+// An error is returned if a record is truncated or if its header doesn't carry the driver signature.
+func ParsePacketBatch(buf []byte) ([]FilterPacketHeader, [][]byte, error) {
+	var (
+		headers []FilterPacketHeader
+		packets [][]byte
+	)
+	for offset := 0; offset < len(buf); {
+		if len(buf)-offset < FilterPacketHeaderSize {
+			return nil, nil, fmt.Errorf("truncated packet header at offset %d: %d bytes left, expected %d", offset, len(buf)-offset, FilterPacketHeaderSize)
+		}
+		header := *(*FilterPacketHeader)(unsafe.Pointer(&buf[offset]))
+		if header.FilterVersion != Signature {
+			return nil, nil, fmt.Errorf("invalid packet header signature at offset %d: got %#x, expected %#x", offset, header.FilterVersion, uint64(Signature))
+		}
+		offset += FilterPacketHeaderSize
+
+		if header.PktSize > uint64(len(buf)-offset) {
+			return nil, nil, fmt.Errorf("truncated packet at offset %d: %d bytes left, expected %d", offset, len(buf)-offset, header.PktSize)
+		}
+		end := offset + int(header.PktSize)
+
+		headers = append(headers, header)
+		packets = append(packets, buf[offset:end:end])
+		offset = end
+	}
+	return headers, packets, nil
+}
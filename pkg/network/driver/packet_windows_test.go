@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package driver
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendPacketRecord(buf []byte, header FilterPacketHeader, packet []byte) []byte {
+	header.PktSize = uint64(len(packet))
+	raw := (*[FilterPacketHeaderSize]byte)(unsafe.Pointer(&header))
+	buf = append(buf, raw[:]...)
+	return append(buf, packet...)
+}
+
+func TestParsePacketBatch(t *testing.T) {
+	first := []byte{0x45, 0x00, 0x00, 0x1c}
+	second := []byte{0x60, 0x00, 0x00, 0x00, 0x00, 0x08}
+
+	var buf []byte
+	buf = appendPacketRecord(buf, FilterPacketHeader{FilterVersion: Signature, Sz: FilterPacketHeaderSize, OwnerPid: 42, Timestamp: 1}, first)
+	buf = appendPacketRecord(buf, FilterPacketHeader{FilterVersion: Signature, Sz: FilterPacketHeaderSize, OwnerPid: 43, Timestamp: 2}, second)
+	buf = appendPacketRecord(buf, FilterPacketHeader{FilterVersion: Signature, Sz: FilterPacketHeaderSize}, nil)
+
+	t.Run("well formed", func(t *testing.T) {
+		headers, packets, err := ParsePacketBatch(buf)
+		require.NoError(t, err)
+		require.Len(t, headers, 3)
+		require.Len(t, packets, 3)
+		assert.Equal(t, uint64(42), headers[0].OwnerPid)
+		assert.Equal(t, uint64(43), headers[1].OwnerPid)
+		assert.Equal(t, first, packets[0])
+		assert.Equal(t, second, packets[1])
+		assert.Empty(t, packets[2])
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		headers, packets, err := ParsePacketBatch(nil)
+		require.NoError(t, err)
+		assert.Empty(t, headers)
+		assert.Empty(t, packets)
+	})
+
+	t.Run("truncated header", func(t *testing.T) {
+		_, _, err := ParsePacketBatch(buf[:FilterPacketHeaderSize-1])
+		assert.Error(t, err)
+	})
+
+	t.Run("truncated packet", func(t *testing.T) {
+		_, _, err := ParsePacketBatch(buf[:FilterPacketHeaderSize+len(first)-1])
+		assert.Error(t, err)
+	})
+
+	t.Run("signature mismatch", func(t *testing.T) {
+		invalid := appendPacketRecord(nil, FilterPacketHeader{FilterVersion: Signature - 1}, first)
+		_, _, err := ParsePacketBatch(invalid)
+		assert.Error(t, err)
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/network/driver"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 	"go.uber.org/atomic"
+	"go.uber.org/multierr"
 	"golang.org/x/sys/windows"
 )
 
@@ -29,6 +30,8 @@ const (
 	flowHandleStats              = "driver_flow_handle_stats"
 	flowStats                    = "flows"
 	driverStats                  = "driver"
+	httpStats                    = "http"
+	statsErrors                  = "errors"
 )
 
 const (
@@ -40,10 +43,13 @@ const (
 	// starting number of entries usermode flow buffer can contain
 	defaultFlowEntries      = 50
 	defaultDriverBufferSize = defaultFlowEntries * driver.PerFlowDataSize
+
+	// weight of the latest read in the moving average of the read sizes
+	readSizeAvgWeight = 0.25
 )
 
 // DriverExpvarNames is a list of all the DriverExpvar names returned from GetStats
-var DriverExpvarNames = []DriverExpvar{totalFlowStats, flowHandleStats, flowStats, driverStats}
+var DriverExpvarNames = []DriverExpvar{totalFlowStats, flowHandleStats, flowStats, driverStats, httpStats, statsErrors}
 
 // DriverInterface holds all necessary information for interacting with the windows driver
 type DriverInterface struct {
@@ -52,6 +58,8 @@ type DriverInterface struct {
 	openFlows      *atomic.Int64
 	moreDataErrors *atomic.Int64
 	bufferSize     *atomic.Int64
+	// maximum number of ReadFile calls made by a single GetConnectionStats call
+	maxReadIterations *atomic.Int64
 
 	maxOpenFlows   uint64
 	maxClosedFlows uint64
@@ -61,8 +69,19 @@ type DriverInterface struct {
 
 	enableMonotonicCounts bool
 
+	// interfaceIndices restricts the flow filters to the given interfaces.
+	// An empty list (or one containing 0) filters on all interfaces.
+	interfaceIndices []uint64
+
 	bufferLock sync.Mutex
 	readBuffer []uint8
+	readFile   func(handle windows.Handle, buf []byte, done *uint32, overlapped *windows.Overlapped) error
+	// exponentially weighted moving average of the bytes read per GetConnectionStats call
+	readSizeAvg float64
+	// size below which the read buffer is never shrunk
+	minBufferSize int
+	// size above which the read buffer is never grown, enough to hold all the flows the driver tracks
+	maxBufferSize int
 
 	cfg *config.Config
 }
@@ -76,20 +95,28 @@ func NewDriverInterface(cfg *config.Config) (*DriverInterface, error) {
 		moreDataErrors: atomic.NewInt64(0),
 		bufferSize:     atomic.NewInt64(defaultDriverBufferSize),
 
+		maxReadIterations: atomic.NewInt64(0),
+
 		cfg:                   cfg,
 		enableMonotonicCounts: cfg.EnableMonotonicCount,
 		readBuffer:            make([]byte, defaultDriverBufferSize),
+		readFile:              windows.ReadFile,
+		minBufferSize:         defaultDriverBufferSize,
 		maxOpenFlows:          uint64(cfg.MaxTrackedConnections),
 		maxClosedFlows:        uint64(cfg.MaxClosedConnectionsBuffered),
+		interfaceIndices:      cfg.InterfaceIndices,
 	}
+	dc.maxBufferSize = int(dc.maxFlows()) * driver.PerFlowDataSize
 
 	err := dc.setupFlowHandle()
 	if err != nil {
+		_ = dc.Close()
 		return nil, fmt.Errorf("error creating driver flow handle: %w", err)
 	}
 
 	err = dc.setupStatsHandle()
 	if err != nil {
+		_ = dc.Close()
 		return nil, fmt.Errorf("Error creating stats handle: %w", err)
 	}
 
@@ -98,13 +125,20 @@ func NewDriverInterface(cfg *config.Config) (*DriverInterface, error) {
 
 // Close shuts down the driver interface
 func (di *DriverInterface) Close() error {
-	if err := di.driverFlowHandle.Close(); err != nil {
-		return fmt.Errorf("error closing flow file handle: %w", err)
+	var err error
+	if di.driverFlowHandle != nil {
+		if closeErr := di.driverFlowHandle.Close(); closeErr != nil {
+			err = multierr.Append(err, fmt.Errorf("error closing flow file handle: %w", closeErr))
+		}
+		di.driverFlowHandle = nil
 	}
-	if err := di.driverStatsHandle.Close(); err != nil {
-		return fmt.Errorf("error closing stat file handle: %w", err)
+	if di.driverStatsHandle != nil {
+		if closeErr := di.driverStatsHandle.Close(); closeErr != nil {
+			err = multierr.Append(err, fmt.Errorf("error closing stat file handle: %w", closeErr))
+		}
+		di.driverStatsHandle = nil
 	}
-	return nil
+	return err
 }
 
 // setupFlowHandle generates a windows Driver Handle, and creates a DriverHandle struct to pull flows from the driver
@@ -146,36 +180,62 @@ func (di *DriverInterface) setupStatsHandle() error {
 	return nil
 }
 
-// GetStats returns statistics for the driver interface used by the windows tracer
+// GetStats returns statistics for the driver interface used by the windows tracer.
+// When a driver handle can't be queried, the stats of the other sources are still returned
+// and the failure is logged and counted in the "errors" entry.
 func (di *DriverInterface) GetStats() (map[DriverExpvar]interface{}, error) {
-	handleStats, err := di.driverFlowHandle.GetStatsForHandle()
-	if err != nil {
-		return nil, err
+	return di.getStats(di.driverFlowHandle, di.driverStatsHandle), nil
+}
+
+// handleStatsGetter queries the stats of a driver handle
+type handleStatsGetter interface {
+	GetStatsForHandle() (map[string]int64, error)
+	GetStatsAndHTTPStatsForHandle() (map[string]int64, map[string]int64, error)
+}
+
+func (di *DriverInterface) getStats(flowHandle, statsHandle handleStatsGetter) map[DriverExpvar]interface{} {
+	stats := make(map[DriverExpvar]interface{})
+	errs := make(map[string]int64)
+
+	if handleStats, err := flowHandle.GetStatsForHandle(); err != nil {
+		log.Warnf("failed to get flow handle stats: %s", err)
+		errs["flow_handle"]++
+	} else {
+		stats[flowHandleStats] = handleStats
 	}
 
-	totalDriverStats, err := di.driverStatsHandle.GetStatsForHandle()
-	if err != nil {
-		return nil, err
+	if totalDriverStats, httpDriverStats, err := statsHandle.GetStatsAndHTTPStatsForHandle(); err != nil {
+		log.Warnf("failed to get stats handle stats: %s", err)
+		errs["stats_handle"]++
+	} else {
+		stats[totalFlowStats] = totalDriverStats
+		stats[httpStats] = httpDriverStats
+	}
+
+	stats[flowStats] = map[string]int64{
+		"total":  di.totalFlows.Load(),
+		"open":   di.openFlows.Swap(0),
+		"closed": di.closedFlows.Swap(0),
+	}
+	stats[driverStats] = map[string]int64{
+		"more_data_errors":    di.moreDataErrors.Swap(0),
+		"buffer_size":         di.bufferSize.Load(),
+		"max_read_iterations": di.maxReadIterations.Swap(0),
+	}
+
+	if len(errs) > 0 {
+		stats[statsErrors] = errs
 	}
-	totalFlows := di.totalFlows.Load()
-	openFlows := di.openFlows.Swap(0)
-	closedFlows := di.closedFlows.Swap(0)
-	moreDataErrors := di.moreDataErrors.Swap(0)
-	bufferSize := di.bufferSize.Load()
-
-	return map[DriverExpvar]interface{}{
-		totalFlowStats:  totalDriverStats,
-		flowHandleStats: handleStats,
-		flowStats: map[string]int64{
-			"total":  totalFlows,
-			"open":   openFlows,
-			"closed": closedFlows,
-		},
-		driverStats: map[string]int64{
-			"more_data_errors": moreDataErrors,
-			"buffer_size":      bufferSize,
-		},
-	}, nil
+	return stats
+}
+
+// SetMonotonicCounts selects whether the connection bytes are the monotonic counts of the driver,
+// which include the IP and transport headers, rather than the transport bytes
+func (di *DriverInterface) SetMonotonicCounts(enabled bool) {
+	di.bufferLock.Lock()
+	defer di.bufferLock.Unlock()
+
+	di.enableMonotonicCounts = enabled
 }
 
 // GetConnectionStats will read all flows from the driver and convert them into ConnectionStats.
@@ -188,9 +248,11 @@ func (di *DriverInterface) GetConnectionStats(activeBuf *ConnectionBuffer, close
 
 	var bytesRead uint32
 	var totalBytesRead uint32
+	var iterations int64
 	// keep reading while driver says there is more data available
 	for err := error(windows.ERROR_MORE_DATA); err == windows.ERROR_MORE_DATA; {
-		err = windows.ReadFile(di.driverFlowHandle.Handle, di.readBuffer, &bytesRead, nil)
+		iterations++
+		err = di.readFile(di.driverFlowHandle.Handle, di.readBuffer, &bytesRead, nil)
 		if err != nil {
 			if err == windows.ERROR_NO_MORE_ITEMS {
 				break
@@ -202,10 +264,13 @@ func (di *DriverInterface) GetConnectionStats(activeBuf *ConnectionBuffer, close
 		}
 		totalBytesRead += bytesRead
 
-		var buf []byte
-		for bytesUsed := uint32(0); bytesUsed < bytesRead; bytesUsed += driver.PerFlowDataSize {
-			buf = di.readBuffer[bytesUsed:]
-			pfd := (*driver.PerFlowData)(unsafe.Pointer(&(buf[0])))
+		for bytesUsed := 0; bytesUsed < int(bytesRead); {
+			pfd, n, err := DecodePerFlowData(di.readBuffer[bytesUsed:bytesRead])
+			if err != nil {
+				log.Debugf("skipping malformed flow data: %s", err)
+				break
+			}
+			bytesUsed += n
 
 			if isFlowClosed(pfd.Flags) {
 				c := closedBuf.Next()
@@ -224,10 +289,22 @@ func (di *DriverInterface) GetConnectionStats(activeBuf *ConnectionBuffer, close
 			}
 		}
 
-		di.readBuffer = resizeDriverBuffer(int(totalBytesRead), di.readBuffer)
-		di.bufferSize.Store(int64(len(di.readBuffer)))
+		// the flows of the buffer were all consumed, grow it right away so that the next reads drain the
+		// driver faster, the moving average only shrinking it back once the bursts are over
+		if err == windows.ERROR_MORE_DATA {
+			di.readBuffer = growDriverBuffer(di.readBuffer, di.maxBufferSize)
+		}
 	}
 
+	// only GetConnectionStats updates the max, under bufferLock, so the load and store can't race
+	if iterations > di.maxReadIterations.Load() {
+		di.maxReadIterations.Store(iterations)
+	}
+
+	di.readSizeAvg = updateReadSizeAvg(di.readSizeAvg, int(totalBytesRead))
+	di.readBuffer = resizeDriverBuffer(di.readSizeAvg, di.minBufferSize, di.readBuffer)
+	di.bufferSize.Store(int64(len(di.readBuffer)))
+
 	activeCount := activeBuf.Len() - startActive
 	closedCount := closedBuf.Len() - startClosed
 	di.openFlows.Add(int64(activeCount))
@@ -237,13 +314,51 @@ func (di *DriverInterface) GetConnectionStats(activeBuf *ConnectionBuffer, close
 	return activeCount, closedCount, nil
 }
 
-func resizeDriverBuffer(compareSize int, buffer []uint8) []uint8 {
+// DecodePerFlowData decodes the flow at the start of the given buffer, as returned by the driver,
+// along with the number of bytes consumed. It returns an error if the buffer is too short to hold a whole flow.
+func DecodePerFlowData(buf []byte) (*driver.PerFlowData, int, error) {
+	if len(buf) < driver.PerFlowDataSize {
+		return nil, 0, fmt.Errorf("flow data is truncated: got %d bytes, expected %d", len(buf), driver.PerFlowDataSize)
+	}
+	return (*driver.PerFlowData)(unsafe.Pointer(&buf[0])), driver.PerFlowDataSize, nil
+}
+
+// updateReadSizeAvg adds the size of the latest read to the moving average of the read sizes
+func updateReadSizeAvg(avg float64, readSize int) float64 {
+	if avg == 0 {
+		return float64(readSize)
+	}
+	return readSizeAvgWeight*float64(readSize) + (1-readSizeAvgWeight)*avg
+}
+
+// growDriverBuffer returns a buffer twice the size of the given one, for the driver to return more flows per read.
+// The new buffer is at most maxSize bytes, and the given buffer is returned as is if it already reached that size.
+func growDriverBuffer(buffer []uint8, maxSize int) []uint8 {
+	size := cap(buffer) * 2
+	if size > maxSize {
+		size = maxSize
+	}
+	if size <= cap(buffer) {
+		return buffer
+	}
 	// Explicitly setting len to 0 causes the ReadFile syscall to break, so allocate buffer with cap = len
-	if compareSize >= cap(buffer)*2 {
-		return make([]uint8, cap(buffer)*2)
-	} else if compareSize <= cap(buffer)/2 {
-		// Take the max of buffer/2 and compareSize to limit future array resizes
-		return make([]uint8, int(math.Max(float64(cap(buffer)/2), float64(compareSize))))
+	return make([]uint8, size)
+}
+
+// resizeDriverBuffer grows or shrinks the buffer once the average read size crosses twice or half its size,
+// so that a single burst of flows doesn't cause the buffer to be reallocated back and forth
+func resizeDriverBuffer(avgReadSize float64, minSize int, buffer []uint8) []uint8 {
+	size := cap(buffer)
+	// Explicitly setting len to 0 causes the ReadFile syscall to break, so allocate buffer with cap = len
+	if avgReadSize >= float64(size*2) {
+		return make([]uint8, size*2)
+	} else if avgReadSize <= float64(size/2) && size > minSize {
+		// Take the max of buffer/2 and the average read size to limit future array resizes
+		newSize := int(math.Max(float64(size/2), avgReadSize))
+		if newSize < minSize {
+			newSize = minSize
+		}
+		return make([]uint8, newSize)
 	}
 	return buffer
 }
@@ -255,6 +370,13 @@ func minUint64(a, b uint64) uint64 {
 	return a
 }
 
+// maxFlows returns the maximum number of flows tracked by the driver, the sum of the configured max open and
+// closed flows. This makes it so that the config can clamp down, but can never make it larger than the coded
+// defaults above.
+func (di *DriverInterface) maxFlows() uint64 {
+	return minUint64(defaultMaxOpenFlows+defaultMaxClosedFlows, di.maxOpenFlows+di.maxClosedFlows)
+}
+
 // setParams passes any configuration values from the config file down
 // to the driver.
 func (di *DriverInterface) setFlowParams() error {
@@ -265,9 +387,7 @@ func (di *DriverInterface) setFlowParams() error {
 	// (hard_coded) maximum.  This will be updated to actually honor the separate
 	// config values when the driver is updated to track them separately.
 
-	// this makes it so that the config can clamp down, but can never make it
-	// larger than the coded defaults above.
-	maxFlows := minUint64(defaultMaxOpenFlows+defaultMaxClosedFlows, di.maxOpenFlows+di.maxClosedFlows)
+	maxFlows := di.maxFlows()
 	log.Debugf("Setting max flows in driver to %v", maxFlows)
 	err := windows.DeviceIoControl(di.driverFlowHandle.Handle,
 		driver.SetMaxFlowsIOCTL,
@@ -283,14 +403,51 @@ func (di *DriverInterface) setFlowParams() error {
 
 func (di *DriverInterface) createFlowHandleFilters() ([]driver.FilterDefinition, error) {
 	var filters []driver.FilterDefinition
-	log.Debugf("Creating filters for all interfaces")
+	for _, iface := range normalizeInterfaceIndices(di.interfaceIndices) {
+		if iface == 0 {
+			log.Debugf("Creating filters for all interfaces")
+		} else {
+			log.Debugf("Creating filters for interface %d", iface)
+		}
+		filters = append(filters, di.createFlowHandleFiltersForInterface(iface)...)
+	}
+	return filters, nil
+}
+
+// normalizeInterfaceIndices removes duplicate interface indices and collapses the list
+// to the wildcard (0) when it is present alongside specific indices, since the wildcard
+// filter already covers every interface.
+func normalizeInterfaceIndices(indices []uint64) []uint64 {
+	if len(indices) == 0 {
+		return []uint64{0}
+	}
+
+	seen := make(map[uint64]struct{}, len(indices))
+	normalized := make([]uint64, 0, len(indices))
+	for _, idx := range indices {
+		if _, ok := seen[idx]; ok {
+			continue
+		}
+		seen[idx] = struct{}{}
+		normalized = append(normalized, idx)
+	}
+
+	if _, ok := seen[0]; ok && len(normalized) > 1 {
+		log.Infof("Interface index list %v contains the wildcard index 0, filtering on all interfaces", indices)
+		return []uint64{0}
+	}
+	return normalized
+}
+
+func (di *DriverInterface) createFlowHandleFiltersForInterface(iface uint64) []driver.FilterDefinition {
+	var filters []driver.FilterDefinition
 	if di.cfg.CollectTCPConns {
 		filters = append(filters, driver.FilterDefinition{
 			FilterVersion:  driver.Signature,
 			Size:           driver.FilterDefinitionSize,
 			Direction:      driver.DirectionOutbound,
 			FilterLayer:    driver.LayerTransport,
-			InterfaceIndex: uint64(0),
+			InterfaceIndex: iface,
 			Af:             windows.AF_INET,
 			Protocol:       windows.IPPROTO_TCP,
 		}, driver.FilterDefinition{
@@ -298,7 +455,7 @@ func (di *DriverInterface) createFlowHandleFilters() ([]driver.FilterDefinition,
 			Size:           driver.FilterDefinitionSize,
 			Direction:      driver.DirectionInbound,
 			FilterLayer:    driver.LayerTransport,
-			InterfaceIndex: uint64(0),
+			InterfaceIndex: iface,
 			Af:             windows.AF_INET,
 			Protocol:       windows.IPPROTO_TCP,
 		})
@@ -308,7 +465,7 @@ func (di *DriverInterface) createFlowHandleFilters() ([]driver.FilterDefinition,
 				Size:           driver.FilterDefinitionSize,
 				Direction:      driver.DirectionOutbound,
 				FilterLayer:    driver.LayerTransport,
-				InterfaceIndex: uint64(0),
+				InterfaceIndex: iface,
 				Af:             windows.AF_INET6,
 				Protocol:       windows.IPPROTO_TCP,
 			}, driver.FilterDefinition{
@@ -316,7 +473,7 @@ func (di *DriverInterface) createFlowHandleFilters() ([]driver.FilterDefinition,
 				Size:           driver.FilterDefinitionSize,
 				Direction:      driver.DirectionInbound,
 				FilterLayer:    driver.LayerTransport,
-				InterfaceIndex: uint64(0),
+				InterfaceIndex: iface,
 				Af:             windows.AF_INET6,
 				Protocol:       windows.IPPROTO_TCP,
 			})
@@ -329,7 +486,7 @@ func (di *DriverInterface) createFlowHandleFilters() ([]driver.FilterDefinition,
 			Size:           driver.FilterDefinitionSize,
 			Direction:      driver.DirectionOutbound,
 			FilterLayer:    driver.LayerTransport,
-			InterfaceIndex: uint64(0),
+			InterfaceIndex: iface,
 			Af:             windows.AF_INET,
 			Protocol:       windows.IPPROTO_UDP,
 		}, driver.FilterDefinition{
@@ -337,7 +494,7 @@ func (di *DriverInterface) createFlowHandleFilters() ([]driver.FilterDefinition,
 			Size:           driver.FilterDefinitionSize,
 			Direction:      driver.DirectionInbound,
 			FilterLayer:    driver.LayerTransport,
-			InterfaceIndex: uint64(0),
+			InterfaceIndex: iface,
 			Af:             windows.AF_INET,
 			Protocol:       windows.IPPROTO_UDP,
 		})
@@ -347,7 +504,7 @@ func (di *DriverInterface) createFlowHandleFilters() ([]driver.FilterDefinition,
 				Size:           driver.FilterDefinitionSize,
 				Direction:      driver.DirectionOutbound,
 				FilterLayer:    driver.LayerTransport,
-				InterfaceIndex: uint64(0),
+				InterfaceIndex: iface,
 				Af:             windows.AF_INET6,
 				Protocol:       windows.IPPROTO_UDP,
 			}, driver.FilterDefinition{
@@ -355,12 +512,12 @@ func (di *DriverInterface) createFlowHandleFilters() ([]driver.FilterDefinition,
 				Size:           driver.FilterDefinitionSize,
 				Direction:      driver.DirectionInbound,
 				FilterLayer:    driver.LayerTransport,
-				InterfaceIndex: uint64(0),
+				InterfaceIndex: iface,
 				Af:             windows.AF_INET6,
 				Protocol:       windows.IPPROTO_UDP,
 			})
 		}
 	}
 
-	return filters, nil
+	return filters
 }
@@ -0,0 +1,453 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows && npm
+// +build windows,npm
+
+package network
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+
+	"github.com/DataDog/datadog-agent/pkg/network/config"
+	"github.com/DataDog/datadog-agent/pkg/network/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/multierr"
+	"golang.org/x/sys/windows"
+)
+
+func TestNormalizeInterfaceIndices(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		assert.Equal(t, []uint64{0}, normalizeInterfaceIndices(nil))
+	})
+	t.Run("duplicates", func(t *testing.T) {
+		assert.Equal(t, []uint64{3, 7}, normalizeInterfaceIndices([]uint64{3, 7, 3, 7, 7}))
+	})
+	t.Run("wildcard and specific", func(t *testing.T) {
+		assert.Equal(t, []uint64{0}, normalizeInterfaceIndices([]uint64{3, 0, 7}))
+	})
+	t.Run("specific only", func(t *testing.T) {
+		assert.Equal(t, []uint64{3, 7}, normalizeInterfaceIndices([]uint64{3, 7}))
+	})
+}
+
+func TestCreateFlowHandleFiltersInterfaceIndices(t *testing.T) {
+	cfg := &config.Config{
+		CollectTCPConns:  true,
+		CollectUDPConns:  true,
+		CollectIPv6Conns: true,
+	}
+	// 2 directions * 2 protocols * 2 families
+	const filtersPerInterface = 8
+
+	tests := []struct {
+		name     string
+		indices  []uint64
+		expected int
+	}{
+		{"duplicates", []uint64{3, 3, 3}, filtersPerInterface},
+		{"wildcard and specific", []uint64{0, 3, 7}, filtersPerInterface},
+		{"specific only", []uint64{3, 7}, 2 * filtersPerInterface},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			di := &DriverInterface{cfg: cfg, interfaceIndices: tt.indices}
+			filters, err := di.createFlowHandleFilters()
+			require.NoError(t, err)
+			assert.Len(t, filters, tt.expected)
+		})
+	}
+}
+
+func TestDriverExpvarNamesIncludeHTTP(t *testing.T) {
+	assert.Contains(t, DriverExpvarNames, DriverExpvar(httpStats))
+}
+
+func TestResizeDriverBuffer(t *testing.T) {
+	const size = 4 * defaultDriverBufferSize
+
+	t.Run("grow", func(t *testing.T) {
+		buf := resizeDriverBuffer(2*size, defaultDriverBufferSize, make([]uint8, size))
+		assert.Len(t, buf, 2*size)
+	})
+	t.Run("shrink", func(t *testing.T) {
+		buf := resizeDriverBuffer(size/4, defaultDriverBufferSize, make([]uint8, size))
+		assert.Len(t, buf, size/2)
+	})
+	t.Run("shrink to floor", func(t *testing.T) {
+		buf := resizeDriverBuffer(0, 3*defaultDriverBufferSize, make([]uint8, size))
+		assert.Len(t, buf, 3*defaultDriverBufferSize)
+	})
+	t.Run("at floor", func(t *testing.T) {
+		orig := make([]uint8, defaultDriverBufferSize)
+		buf := resizeDriverBuffer(0, defaultDriverBufferSize, orig)
+		assert.Equal(t, &orig[0], &buf[0])
+	})
+	t.Run("stable", func(t *testing.T) {
+		orig := make([]uint8, size)
+		buf := resizeDriverBuffer(size, defaultDriverBufferSize, orig)
+		assert.Equal(t, &orig[0], &buf[0])
+	})
+}
+
+func TestResizeDriverBufferBurst(t *testing.T) {
+	const size = 4 * defaultDriverBufferSize
+	buf := make([]uint8, size)
+
+	// a single burst doesn't move the average far enough to resize the buffer
+	avg := updateReadSizeAvg(0, size)
+	avg = updateReadSizeAvg(avg, 3*size)
+	buf = resizeDriverBuffer(avg, defaultDriverBufferSize, buf)
+	assert.Len(t, buf, size)
+	avg = updateReadSizeAvg(avg, size)
+	buf = resizeDriverBuffer(avg, defaultDriverBufferSize, buf)
+	assert.Len(t, buf, size)
+
+	// a sustained increase does
+	for i := 0; i < 10; i++ {
+		avg = updateReadSizeAvg(avg, 3*size)
+		buf = resizeDriverBuffer(avg, defaultDriverBufferSize, buf)
+	}
+	assert.Len(t, buf, 2*size)
+}
+
+func BenchmarkResizeDriverBuffer(b *testing.B) {
+	const size = 4 * defaultDriverBufferSize
+	buf := make([]uint8, size)
+	var avg float64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// alternate between quiet and bursty reads
+		readSize := size / 4
+		if i%2 == 0 {
+			readSize = 3 * size
+		}
+		avg = updateReadSizeAvg(avg, readSize)
+		buf = resizeDriverBuffer(avg, defaultDriverBufferSize, buf)
+	}
+}
+
+func TestCreateFlowHandleFiltersTwoInterfaces(t *testing.T) {
+	cfg := &config.Config{
+		CollectTCPConns:  true,
+		CollectUDPConns:  true,
+		CollectIPv6Conns: true,
+		InterfaceIndices: []uint64{3, 7},
+	}
+	di := &DriverInterface{cfg: cfg, interfaceIndices: cfg.InterfaceIndices}
+	filters, err := di.createFlowHandleFilters()
+	require.NoError(t, err)
+	require.Len(t, filters, 16)
+
+	type permutation struct {
+		iface     uint64
+		af        uint64
+		protocol  uint64
+		direction uint64
+	}
+	seen := make(map[permutation]struct{})
+	for _, f := range filters {
+		assert.Equal(t, uint64(driver.Signature), f.FilterVersion)
+		assert.Equal(t, uint64(driver.LayerTransport), f.FilterLayer)
+		seen[permutation{f.InterfaceIndex, f.Af, f.Protocol, f.Direction}] = struct{}{}
+	}
+
+	for _, iface := range []uint64{3, 7} {
+		for _, af := range []uint64{windows.AF_INET, windows.AF_INET6} {
+			for _, protocol := range []uint64{windows.IPPROTO_TCP, windows.IPPROTO_UDP} {
+				for _, direction := range []uint64{driver.DirectionInbound, driver.DirectionOutbound} {
+					assert.Contains(t, seen, permutation{iface, af, protocol, direction})
+				}
+			}
+		}
+	}
+}
+
+// newTestDriverInterface returns a DriverInterface reading its flows with readFile instead of the driver
+func newTestDriverInterface(t *testing.T, readFile func(windows.Handle, []byte, *uint32, *windows.Overlapped) error) *DriverInterface {
+	t.Helper()
+	return &DriverInterface{
+		totalFlows:        atomic.NewInt64(0),
+		closedFlows:       atomic.NewInt64(0),
+		openFlows:         atomic.NewInt64(0),
+		moreDataErrors:    atomic.NewInt64(0),
+		bufferSize:        atomic.NewInt64(defaultDriverBufferSize),
+		maxReadIterations: atomic.NewInt64(0),
+		driverFlowHandle:  &driver.Handle{},
+		readBuffer:        make([]byte, defaultDriverBufferSize),
+		minBufferSize:     defaultDriverBufferSize,
+		maxBufferSize:     int(defaultMaxOpenFlows+defaultMaxClosedFlows) * driver.PerFlowDataSize,
+		readFile:          readFile,
+	}
+}
+
+func TestGetConnectionStatsMaxReadIterations(t *testing.T) {
+	newDriverInterface := func(moreDataReads int) *DriverInterface {
+		return newTestDriverInterface(t, func(_ windows.Handle, _ []byte, done *uint32, _ *windows.Overlapped) error {
+			*done = 0
+			if moreDataReads > 0 {
+				moreDataReads--
+				return windows.ERROR_MORE_DATA
+			}
+			return nil
+		})
+	}
+	getConnectionStats := func(di *DriverInterface) {
+		_, _, err := di.GetConnectionStats(NewConnectionBuffer(10, 10), NewConnectionBuffer(10, 10), func(*ConnectionStats) bool { return true })
+		require.NoError(t, err)
+	}
+
+	di := newDriverInterface(3)
+	getConnectionStats(di)
+	assert.Equal(t, int64(4), di.maxReadIterations.Load())
+	assert.Equal(t, int64(3), di.moreDataErrors.Load())
+
+	// a call with fewer iterations doesn't lower the max
+	getConnectionStats(di)
+	assert.Equal(t, int64(4), di.maxReadIterations.Load())
+}
+
+func TestGetConnectionStatsGrowsBufferOnMoreData(t *testing.T) {
+	var readSizes []int
+	moreDataReads := 2
+	di := newTestDriverInterface(t, func(_ windows.Handle, buf []byte, done *uint32, _ *windows.Overlapped) error {
+		readSizes = append(readSizes, len(buf))
+		*done = 0
+		if moreDataReads > 0 {
+			moreDataReads--
+			return windows.ERROR_MORE_DATA
+		}
+		return nil
+	})
+
+	_, _, err := di.GetConnectionStats(NewConnectionBuffer(10, 10), NewConnectionBuffer(10, 10), func(*ConnectionStats) bool { return true })
+	require.NoError(t, err)
+	assert.Equal(t, []int{defaultDriverBufferSize, 2 * defaultDriverBufferSize, 4 * defaultDriverBufferSize}, readSizes)
+
+	// the moving average of the read sizes shrinks the buffer back once the reads are done
+	assert.Equal(t, int64(2*defaultDriverBufferSize), di.bufferSize.Load())
+}
+
+func TestGrowDriverBuffer(t *testing.T) {
+	maxSize := 5 * defaultDriverBufferSize
+	buf := make([]uint8, defaultDriverBufferSize)
+
+	buf = growDriverBuffer(buf, maxSize)
+	assert.Len(t, buf, 2*defaultDriverBufferSize)
+	buf = growDriverBuffer(buf, maxSize)
+	assert.Len(t, buf, 4*defaultDriverBufferSize)
+
+	// doubling would go past the max size
+	buf = growDriverBuffer(buf, maxSize)
+	assert.Len(t, buf, maxSize)
+
+	// the buffer is kept as is once it reached the max size
+	grown := growDriverBuffer(buf, maxSize)
+	assert.Len(t, grown, maxSize)
+	assert.Equal(t, &buf[0], &grown[0])
+}
+
+func TestGetConnectionStatsBufferGrowthIsCapped(t *testing.T) {
+	var readSizes []int
+	moreDataReads := 4
+	di := newTestDriverInterface(t, func(_ windows.Handle, buf []byte, done *uint32, _ *windows.Overlapped) error {
+		readSizes = append(readSizes, len(buf))
+		*done = 0
+		if moreDataReads > 0 {
+			moreDataReads--
+			return windows.ERROR_MORE_DATA
+		}
+		return nil
+	})
+	// the driver tracks at most 120 flows
+	di.maxOpenFlows, di.maxClosedFlows = 100, 20
+	di.maxBufferSize = int(di.maxFlows()) * driver.PerFlowDataSize
+
+	_, _, err := di.GetConnectionStats(NewConnectionBuffer(10, 10), NewConnectionBuffer(10, 10), func(*ConnectionStats) bool { return true })
+	require.NoError(t, err)
+	assert.Equal(t, []int{
+		defaultDriverBufferSize,
+		2 * defaultDriverBufferSize,
+		120 * driver.PerFlowDataSize,
+		120 * driver.PerFlowDataSize,
+		120 * driver.PerFlowDataSize,
+	}, readSizes)
+}
+
+type fakeHandleStats struct {
+	stats    map[string]int64
+	statsErr error
+}
+
+func (f fakeHandleStats) GetStatsForHandle() (map[string]int64, error) {
+	return f.stats, f.statsErr
+}
+
+func (f fakeHandleStats) GetStatsAndHTTPStatsForHandle() (map[string]int64, map[string]int64, error) {
+	if f.statsErr != nil {
+		return nil, nil, f.statsErr
+	}
+	return f.stats, map[string]int64{"batches_reported": 1}, nil
+}
+
+func TestGetStatsHandleFailures(t *testing.T) {
+	newDriverInterface := func() *DriverInterface {
+		di := newTestDriverInterface(t, nil)
+		di.totalFlows.Store(5)
+		di.closedFlows.Store(2)
+		di.openFlows.Store(3)
+		return di
+	}
+	healthy := fakeHandleStats{stats: map[string]int64{"read_calls": 1}}
+	broken := fakeHandleStats{statsErr: errors.New("stats failed")}
+
+	t.Run("no failure", func(t *testing.T) {
+		stats := newDriverInterface().getStats(healthy, healthy)
+		for _, name := range []DriverExpvar{totalFlowStats, flowHandleStats, flowStats, driverStats, httpStats} {
+			assert.Contains(t, stats, name)
+		}
+		assert.NotContains(t, stats, DriverExpvar(statsErrors))
+	})
+
+	t.Run("flow handle failure", func(t *testing.T) {
+		stats := newDriverInterface().getStats(broken, healthy)
+		assert.NotContains(t, stats, DriverExpvar(flowHandleStats))
+		assert.Contains(t, stats, totalFlowStats)
+		assert.Contains(t, stats, DriverExpvar(httpStats))
+		assert.Equal(t, int64(5), stats[flowStats].(map[string]int64)["total"])
+		assert.Equal(t, map[string]int64{"flow_handle": 1}, stats[statsErrors])
+	})
+
+	t.Run("stats handle failure", func(t *testing.T) {
+		stats := newDriverInterface().getStats(healthy, broken)
+		assert.Contains(t, stats, DriverExpvar(flowHandleStats))
+		assert.NotContains(t, stats, totalFlowStats)
+		assert.NotContains(t, stats, DriverExpvar(httpStats))
+		assert.Equal(t, int64(5), stats[flowStats].(map[string]int64)["total"])
+		assert.Equal(t, map[string]int64{"stats_handle": 1}, stats[statsErrors])
+	})
+}
+
+func TestSetMonotonicCounts(t *testing.T) {
+	flow := driver.PerFlowData{
+		AddressFamily:      windows.AF_INET,
+		Protocol:           windows.IPPROTO_UDP,
+		MonotonicSentBytes: 150,
+		TransportBytesOut:  100,
+		MonotonicRecvBytes: 80,
+		TransportBytesIn:   50,
+	}
+	di := newTestDriverInterface(t, func(_ windows.Handle, buf []byte, done *uint32, _ *windows.Overlapped) error {
+		*(*driver.PerFlowData)(unsafe.Pointer(&buf[0])) = flow
+		*done = driver.PerFlowDataSize
+		return nil
+	})
+	getConnection := func() ConnectionStats {
+		active := NewConnectionBuffer(10, 10)
+		_, _, err := di.GetConnectionStats(active, NewConnectionBuffer(10, 10), func(*ConnectionStats) bool { return true })
+		require.NoError(t, err)
+		require.Equal(t, 1, active.Len())
+		return active.Connections()[0]
+	}
+
+	c := getConnection()
+	assert.Equal(t, uint64(100), c.Monotonic.SentBytes)
+	assert.Equal(t, uint64(50), c.Monotonic.RecvBytes)
+
+	di.SetMonotonicCounts(true)
+	c = getConnection()
+	assert.Equal(t, uint64(150), c.Monotonic.SentBytes)
+	assert.Equal(t, uint64(80), c.Monotonic.RecvBytes)
+}
+
+func TestDriverInterfaceClose(t *testing.T) {
+	newHandle := func(t *testing.T) *driver.Handle {
+		h, err := windows.CreateEvent(nil, 0, 0, nil)
+		require.NoError(t, err)
+		return &driver.Handle{Handle: h}
+	}
+
+	t.Run("double close", func(t *testing.T) {
+		di := &DriverInterface{driverFlowHandle: newHandle(t), driverStatsHandle: newHandle(t)}
+		require.NoError(t, di.Close())
+		assert.Nil(t, di.driverFlowHandle)
+		assert.Nil(t, di.driverStatsHandle)
+		assert.NoError(t, di.Close())
+	})
+
+	t.Run("nil stats handle", func(t *testing.T) {
+		di := &DriverInterface{driverFlowHandle: newHandle(t)}
+		assert.NoError(t, di.Close())
+		assert.Nil(t, di.driverFlowHandle)
+	})
+
+	t.Run("errors from both handles", func(t *testing.T) {
+		di := &DriverInterface{
+			driverFlowHandle:  &driver.Handle{},
+			driverStatsHandle: &driver.Handle{},
+		}
+		err := di.Close()
+		require.Error(t, err)
+		assert.Len(t, multierr.Errors(err), 2)
+	})
+}
+
+func TestDecodePerFlowData(t *testing.T) {
+	flow := driver.PerFlowData{FlowHandle: 7, AddressFamily: windows.AF_INET, Protocol: windows.IPPROTO_TCP}
+	newBuf := func(size int) []byte {
+		buf := make([]byte, size)
+		*(*driver.PerFlowData)(unsafe.Pointer(&buf[0])) = flow
+		return buf
+	}
+
+	t.Run("exact", func(t *testing.T) {
+		pfd, n, err := DecodePerFlowData(newBuf(driver.PerFlowDataSize))
+		require.NoError(t, err)
+		assert.Equal(t, driver.PerFlowDataSize, n)
+		assert.Equal(t, flow, *pfd)
+	})
+
+	t.Run("short", func(t *testing.T) {
+		pfd, n, err := DecodePerFlowData(newBuf(driver.PerFlowDataSize)[:driver.PerFlowDataSize-1])
+		assert.Error(t, err)
+		assert.Zero(t, n)
+		assert.Nil(t, pfd)
+
+		_, _, err = DecodePerFlowData(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("oversized", func(t *testing.T) {
+		pfd, n, err := DecodePerFlowData(newBuf(driver.PerFlowDataSize + 10))
+		require.NoError(t, err)
+		assert.Equal(t, driver.PerFlowDataSize, n)
+		assert.Equal(t, flow, *pfd)
+	})
+}
+
+func TestGetConnectionStatsTrailingData(t *testing.T) {
+	flow := driver.PerFlowData{
+		AddressFamily: windows.AF_INET,
+		Protocol:      windows.IPPROTO_UDP,
+	}
+	di := newTestDriverInterface(t, func(_ windows.Handle, buf []byte, done *uint32, _ *windows.Overlapped) error {
+		*(*driver.PerFlowData)(unsafe.Pointer(&buf[0])) = flow
+		// a partial second record follows the first one
+		*done = driver.PerFlowDataSize + driver.PerFlowDataSize/2
+		return nil
+	})
+
+	active, closed := NewConnectionBuffer(10, 10), NewConnectionBuffer(10, 10)
+	activeCount, closedCount, err := di.GetConnectionStats(active, closed, func(*ConnectionStats) bool { return true })
+	require.NoError(t, err)
+	assert.Equal(t, 1, activeCount)
+	assert.Equal(t, 0, closedCount)
+}
@@ -145,6 +145,8 @@ const (
 	MonotonicDNSPacketsDropped         ConnTelemetryType = "dns_packets_dropped"
 	HTTPRequestsDropped                ConnTelemetryType = "http_requests_dropped"
 	HTTPRequestsMissed                 ConnTelemetryType = "http_requests_missed"
+	MonotonicDriverFlowCollisions      ConnTelemetryType = "driver_flow_collisions"
+	MonotonicDriverFlowsDropped        ConnTelemetryType = "driver_flows_dropped"
 )
 
 //revive:enable
@@ -173,6 +175,8 @@ var (
 		MonotonicUDPSendsProcessed,
 		MonotonicUDPSendsMissed,
 		MonotonicDNSPacketsDropped,
+		MonotonicDriverFlowCollisions,
+		MonotonicDriverFlowsDropped,
 	}
 )
 
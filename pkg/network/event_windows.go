@@ -109,8 +109,7 @@ func FlowToConnStat(cs *ConnectionStats, flow *driver.PerFlowData, enableMonoton
 	cs.SPortIsEphemeral = IsPortInEphemeralRange(cs.Family, cs.Type, cs.SPort)
 
 	if connectionType == TCP {
-		tf := flow.TCPFlow()
-		if tf != nil {
+		if tf, ok := flow.TCPData(); ok {
 			cs.Monotonic.Retransmits = uint32(tf.RetransmitCount)
 			cs.RTT = uint32(tf.SRTT)
 			cs.RTTVar = uint32(tf.RttVariance)
@@ -124,3 +123,67 @@ func FlowToConnStat(cs *ConnectionStats, flow *driver.PerFlowData, enableMonoton
 		}
 	}
 }
+
+// flowTupleKey identifies a flow by its addresses and ports, as seen from the local host
+type flowTupleKey struct {
+	family     uint16
+	localAddr  [16]uint8
+	remoteAddr [16]uint8
+	localPort  uint16
+	remotePort uint16
+}
+
+func newFlowTupleKey(family uint16, localAddr, remoteAddr [16]uint8, localPort, remotePort uint16) flowTupleKey {
+	if family == syscall.AF_INET {
+		// only the first 4 bytes are meaningful for v4 addresses
+		localAddr = v4AddrBytes(localAddr)
+		remoteAddr = v4AddrBytes(remoteAddr)
+	}
+	return flowTupleKey{
+		family:     family,
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+		localPort:  localPort,
+		remotePort: remotePort,
+	}
+}
+
+func v4AddrBytes(addr [16]uint8) [16]uint8 {
+	var v4 [16]uint8
+	copy(v4[:net.IPv4len], addr[:net.IPv4len])
+	return v4
+}
+
+// JoinHTTPToFlows correlates HTTP transactions with the TCP flows they were seen on, using the
+// tuple of each transaction. A transaction matches a flow whichever side of the connection is local,
+// so that both client and server transactions are joined. The result is keyed by flow handle,
+// transactions which don't match any flow are left out.
+func JoinHTTPToFlows(flows []*driver.PerFlowData, txns []driver.HttpTransactionType) map[uint64][]driver.HttpTransactionType {
+	joined := make(map[uint64][]driver.HttpTransactionType)
+	if len(flows) == 0 || len(txns) == 0 {
+		return joined
+	}
+
+	handles := make(map[flowTupleKey]uint64, len(flows))
+	for _, flow := range flows {
+		if flow == nil || flow.Protocol != syscall.IPPROTO_TCP {
+			continue
+		}
+		key := newFlowTupleKey(flow.AddressFamily, flow.LocalAddress, flow.RemoteAddress, flow.LocalPort, flow.RemotePort)
+		handles[key] = flow.FlowHandle
+	}
+
+	for _, txn := range txns {
+		tup := txn.Tup
+		// outgoing request: the client is local
+		handle, ok := handles[newFlowTupleKey(tup.Family, tup.CliAddr, tup.SrvAddr, tup.CliPort, tup.SrvPort)]
+		if !ok {
+			// incoming request: the server is local
+			handle, ok = handles[newFlowTupleKey(tup.Family, tup.SrvAddr, tup.CliAddr, tup.SrvPort, tup.CliPort)]
+		}
+		if ok {
+			joined[handle] = append(joined[handle], txn)
+		}
+	}
+	return joined
+}
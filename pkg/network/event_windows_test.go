@@ -6,8 +6,11 @@
 package network
 
 import (
+	"encoding/binary"
+	"syscall"
 	"testing"
 
+	"github.com/DataDog/datadog-agent/pkg/network/driver"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -39,3 +42,132 @@ func TestNetshParse(t *testing.T) {
 		assert.Equal(t, uint16(65535), hi)
 	})
 }
+
+func TestFlowToConnStatDirection(t *testing.T) {
+	tests := []struct {
+		name      string
+		flags     uint32
+		direction ConnectionDirection
+	}{
+		{name: "inbound", flags: driver.FlowDirectionInbound << driver.FlowDirectionBits, direction: INCOMING},
+		{name: "outbound", flags: driver.FlowDirectionOutbound << driver.FlowDirectionBits, direction: OUTGOING},
+		{name: "inbound closed", flags: driver.FlowDirectionInbound<<driver.FlowDirectionBits | driver.FlowClosedMask, direction: INCOMING},
+		{name: "no direction", flags: 0, direction: OUTGOING},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow := &driver.PerFlowData{
+				AddressFamily: syscall.AF_INET,
+				Protocol:      syscall.IPPROTO_UDP,
+				Flags:         tt.flags,
+				LocalPort:     8080,
+				RemotePort:    50000,
+			}
+
+			var cs ConnectionStats
+			FlowToConnStat(&cs, flow, false)
+			assert.Equal(t, tt.direction, cs.Direction)
+		})
+	}
+}
+
+func TestFlowToConnStatRTT(t *testing.T) {
+	flow := &driver.PerFlowData{
+		AddressFamily: syscall.AF_INET,
+		Protocol:      syscall.IPPROTO_TCP,
+	}
+	binary.LittleEndian.PutUint64(flow.U[8:], 1500)
+	binary.LittleEndian.PutUint64(flow.U[16:], 250)
+	binary.LittleEndian.PutUint64(flow.U[24:], 3)
+
+	var cs ConnectionStats
+	FlowToConnStat(&cs, flow, false)
+	assert.Equal(t, uint32(1500), cs.RTT)
+	assert.Equal(t, uint32(250), cs.RTTVar)
+	assert.Equal(t, uint32(3), cs.Monotonic.Retransmits)
+}
+
+func TestJoinHTTPToFlows(t *testing.T) {
+	v4Local := [16]uint8{10, 0, 0, 1}
+	v4Remote := [16]uint8{10, 0, 0, 2}
+	v6Local := [16]uint8{0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	v6Remote := [16]uint8{0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}
+
+	flows := []*driver.PerFlowData{
+		{
+			FlowHandle:    1,
+			AddressFamily: syscall.AF_INET,
+			Protocol:      syscall.IPPROTO_TCP,
+			LocalAddress:  v4Local,
+			RemoteAddress: v4Remote,
+			LocalPort:     50000,
+			RemotePort:    80,
+		},
+		{
+			FlowHandle:    2,
+			AddressFamily: syscall.AF_INET6,
+			Protocol:      syscall.IPPROTO_TCP,
+			LocalAddress:  v6Local,
+			RemoteAddress: v6Remote,
+			LocalPort:     8080,
+			RemotePort:    60000,
+		},
+		{
+			FlowHandle:    3,
+			AddressFamily: syscall.AF_INET,
+			Protocol:      syscall.IPPROTO_UDP,
+			LocalAddress:  v4Local,
+			RemoteAddress: v4Remote,
+			LocalPort:     50001,
+			RemotePort:    80,
+		},
+	}
+
+	// v4 addresses with garbage past the first 4 bytes must still match
+	v4Cli := v4Local
+	v4Cli[8] = 0xff
+	outgoingV4 := driver.HttpTransactionType{
+		ResponseStatusCode: 200,
+		Tup: driver.ConnTupleType{
+			CliAddr: v4Cli,
+			SrvAddr: v4Remote,
+			CliPort: 50000,
+			SrvPort: 80,
+			Family:  syscall.AF_INET,
+		},
+	}
+	incomingV6 := driver.HttpTransactionType{
+		ResponseStatusCode: 404,
+		Tup: driver.ConnTupleType{
+			CliAddr: v6Remote,
+			SrvAddr: v6Local,
+			CliPort: 60000,
+			SrvPort: 8080,
+			Family:  syscall.AF_INET6,
+		},
+	}
+
+	t.Run("matching", func(t *testing.T) {
+		joined := JoinHTTPToFlows(flows, []driver.HttpTransactionType{outgoingV4, incomingV6, outgoingV4})
+		require.Len(t, joined, 2)
+		assert.Equal(t, []driver.HttpTransactionType{outgoingV4, outgoingV4}, joined[1])
+		assert.Equal(t, []driver.HttpTransactionType{incomingV6}, joined[2])
+	})
+
+	t.Run("non matching", func(t *testing.T) {
+		wrongPort := outgoingV4
+		wrongPort.Tup.SrvPort = 443
+		wrongFamily := outgoingV4
+		wrongFamily.Tup.Family = syscall.AF_INET6
+		udp := outgoingV4
+		udp.Tup.CliPort = 50001
+
+		joined := JoinHTTPToFlows(flows, []driver.HttpTransactionType{wrongPort, wrongFamily, udp})
+		assert.Empty(t, joined)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Empty(t, JoinHTTPToFlows(nil, []driver.HttpTransactionType{outgoingV4}))
+		assert.Empty(t, JoinHTTPToFlows(flows, nil))
+	})
+}
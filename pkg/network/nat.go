@@ -34,3 +34,56 @@ func GetNATRemoteAddress(c ConnectionStats) (util.Address, uint16) {
 	}
 	return remoteIP, remotePort
 }
+
+// NATType describes the kind of address translation applied to a connection
+type NATType uint8
+
+const (
+	// NoNAT represents connections that are not translated
+	NoNAT NATType = 0
+
+	// SNAT represents connections with a translated source address
+	SNAT NATType = 1
+
+	// DNAT represents connections with a translated destination address
+	DNAT NATType = 2
+
+	// SNATAndDNAT represents connections with both their source and destination addresses translated
+	SNATAndDNAT NATType = 3
+)
+
+func (n NATType) String() string {
+	switch n {
+	case SNAT:
+		return "snat"
+	case DNAT:
+		return "dnat"
+	case SNATAndDNAT:
+		return "snat+dnat"
+	default:
+		return "none"
+	}
+}
+
+// ClassifyNAT returns the kind of address translation applied to a connection,
+// by comparing its original addresses against the reply addresses of its translation
+func ClassifyNAT(orig ConnectionStats, t *IPTranslation) NATType {
+	if t == nil {
+		return NoNAT
+	}
+
+	// Fields are flipped: the reply source is the original destination, and vice versa
+	dnat := !t.ReplSrcIP.IsZero() && (t.ReplSrcIP != orig.Dest || t.ReplSrcPort != orig.DPort)
+	snat := !t.ReplDstIP.IsZero() && (t.ReplDstIP != orig.Source || t.ReplDstPort != orig.SPort)
+
+	switch {
+	case snat && dnat:
+		return SNATAndDNAT
+	case snat:
+		return SNAT
+	case dnat:
+		return DNAT
+	default:
+		return NoNAT
+	}
+}
@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package network
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/process/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyNAT(t *testing.T) {
+	conn := ConnectionStats{
+		Source: util.AddressFromString("10.0.0.1"),
+		Dest:   util.AddressFromString("10.0.0.2"),
+		SPort:  34567,
+		DPort:  80,
+		Type:   TCP,
+		Family: AFINET,
+	}
+
+	tests := []struct {
+		name        string
+		translation *IPTranslation
+		expected    NATType
+	}{
+		{
+			name:        "no translation",
+			translation: nil,
+			expected:    NoNAT,
+		},
+		{
+			name: "no nat",
+			translation: &IPTranslation{
+				ReplSrcIP:   util.AddressFromString("10.0.0.2"),
+				ReplDstIP:   util.AddressFromString("10.0.0.1"),
+				ReplSrcPort: 80,
+				ReplDstPort: 34567,
+			},
+			expected: NoNAT,
+		},
+		{
+			name: "snat",
+			translation: &IPTranslation{
+				ReplSrcIP:   util.AddressFromString("10.0.0.2"),
+				ReplDstIP:   util.AddressFromString("192.168.1.1"),
+				ReplSrcPort: 80,
+				ReplDstPort: 34567,
+			},
+			expected: SNAT,
+		},
+		{
+			name: "snat port only",
+			translation: &IPTranslation{
+				ReplSrcIP:   util.AddressFromString("10.0.0.2"),
+				ReplDstIP:   util.AddressFromString("10.0.0.1"),
+				ReplSrcPort: 80,
+				ReplDstPort: 40000,
+			},
+			expected: SNAT,
+		},
+		{
+			name: "dnat",
+			translation: &IPTranslation{
+				ReplSrcIP:   util.AddressFromString("172.17.0.2"),
+				ReplDstIP:   util.AddressFromString("10.0.0.1"),
+				ReplSrcPort: 8080,
+				ReplDstPort: 34567,
+			},
+			expected: DNAT,
+		},
+		{
+			name: "snat and dnat",
+			translation: &IPTranslation{
+				ReplSrcIP:   util.AddressFromString("172.17.0.2"),
+				ReplDstIP:   util.AddressFromString("192.168.1.1"),
+				ReplSrcPort: 8080,
+				ReplDstPort: 40000,
+			},
+			expected: SNATAndDNAT,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ClassifyNAT(conn, tt.translation))
+		})
+	}
+}
@@ -27,7 +27,7 @@ const (
 // and remain open until Reset() is called.
 type CircuitBreaker struct {
 	// The maximum rate of events allowed to pass
-	maxEventsPerSec int64
+	maxEventsPerSec *atomic.Int64
 
 	// The number of events elapsed since the last tick
 	eventCount *atomic.Int64
@@ -48,19 +48,15 @@ type CircuitBreaker struct {
 // NewCircuitBreaker instantiates a new CircuitBreaker that only allows
 // a maxEventsPerSec to pass. The rate of events is calculated using an EWMA.
 func NewCircuitBreaker(maxEventsPerSec int64) *CircuitBreaker {
-	// -1 will virtually disable the circuit breaker
-	if maxEventsPerSec == -1 {
-		maxEventsPerSec = math.MaxInt64
-	}
-
 	c := &CircuitBreaker{
 		eventCount:      atomic.NewInt64(0),
 		eventRate:       atomic.NewInt64(0),
 		isOpen:          atomic.NewBool(false),
 		lastUpdate:      atomic.NewInt64(0),
-		maxEventsPerSec: maxEventsPerSec,
+		maxEventsPerSec: atomic.NewInt64(0),
 		done:            make(chan struct{}),
 	}
+	c.SetMaxEventsPerSec(maxEventsPerSec)
 	c.Reset()
 
 	go func() {
@@ -94,6 +90,17 @@ func (c *CircuitBreaker) Rate() int64 {
 	return c.eventRate.Load()
 }
 
+// SetMaxEventsPerSec updates the maximum rate of events allowed to pass.
+// It is safe to call while the circuit breaker is running; the new limit
+// is taken into account on the next rate update.
+func (c *CircuitBreaker) SetMaxEventsPerSec(maxEventsPerSec int64) {
+	// -1 will virtually disable the circuit breaker
+	if maxEventsPerSec == -1 {
+		maxEventsPerSec = math.MaxInt64
+	}
+	c.maxEventsPerSec.Store(maxEventsPerSec)
+}
+
 // Reset closes the circuit breaker and its state.
 func (c *CircuitBreaker) Reset() {
 	c.eventCount.Store(0)
@@ -136,10 +143,11 @@ func (c *CircuitBreaker) update(now time.Time) {
 	c.eventRate.Store(int64(newEventRate))
 
 	// Update circuit breaker status accordingly
-	if int64(newEventRate) > c.maxEventsPerSec {
+	maxEventsPerSec := c.maxEventsPerSec.Load()
+	if int64(newEventRate) > maxEventsPerSec {
 		log.Warnf(
 			"exceeded maximum number of netlink messages per second. expected=%d actual=%d",
-			c.maxEventsPerSec,
+			maxEventsPerSec,
 			int(newEventRate),
 		)
 		c.isOpen.Store(true)
@@ -135,13 +135,39 @@ func TestCircuitBreakerRateCalculation(t *testing.T) {
 	assert.Equal(t, int64(0), breaker.Rate())
 }
 
+func TestCircuitBreakerSetMaxEventsPerSec(t *testing.T) {
+	const maxEventRate = 100
+	breaker := newTestBreaker(maxEventRate)
+
+	// Raising the limit should keep the circuit closed for a rate above the original limit
+	breaker.SetMaxEventsPerSec(maxEventRate * 2)
+	now := time.Now()
+	breaker.Tick(maxEventRate + 50)
+	breaker.update(now)
+	assert.False(t, breaker.IsOpen())
+
+	// Lowering it back should trip the circuit for the same rate
+	breaker.SetMaxEventsPerSec(maxEventRate)
+	now = now.Add(time.Second)
+	breaker.Tick(maxEventRate + 50)
+	breaker.update(now)
+	assert.True(t, breaker.IsOpen())
+
+	// -1 disables the circuit breaker
+	breaker.Reset()
+	breaker.SetMaxEventsPerSec(-1)
+	breaker.Tick(maxEventRate * 1000)
+	breaker.update(time.Now())
+	assert.False(t, breaker.IsOpen())
+}
+
 func newTestBreaker(maxEventRate int) *CircuitBreaker {
 	c := &CircuitBreaker{
 		eventCount:      atomic.NewInt64(0),
 		eventRate:       atomic.NewInt64(0),
 		isOpen:          atomic.NewBool(false),
 		lastUpdate:      atomic.NewInt64(0),
-		maxEventsPerSec: int64(maxEventRate),
+		maxEventsPerSec: atomic.NewInt64(int64(maxEventRate)),
 	}
 	c.Reset()
 	return c
@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux && !android
+// +build linux,!android
+
+package netlink
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+type conntrackDump struct {
+	Header     conntrackDumpHeader      `json:"header"`
+	Namespaces []conntrackDumpNamespace `json:"namespaces"`
+}
+
+type conntrackDumpHeader struct {
+	Total  int                  `json:"total"`
+	Counts []conntrackDumpCount `json:"counts"`
+}
+
+type conntrackDumpCount struct {
+	NetNS uint32 `json:"netns"`
+	Count int    `json:"count"`
+}
+
+type conntrackDumpNamespace struct {
+	NetNS   uint32                `json:"netns"`
+	Entries []DebugConntrackEntry `json:"entries"`
+}
+
+// MarshalConntrackDump encodes a conntrack table dump to JSON. Namespaces are sorted by inode and the entries of each
+// namespace are sorted, so that two dumps of the same table always produce the same output and can be diffed.
+// A header object holds the number of entries of each namespace.
+func MarshalConntrackDump(entries map[uint32][]DebugConntrackEntry) ([]byte, error) {
+	dump := conntrackDump{
+		Header: conntrackDumpHeader{
+			Counts: make([]conntrackDumpCount, 0, len(entries)),
+		},
+		Namespaces: make([]conntrackDumpNamespace, 0, len(entries)),
+	}
+
+	namespaces := make([]uint32, 0, len(entries))
+	for ns := range entries {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i] < namespaces[j] })
+
+	for _, ns := range namespaces {
+		sorted := make([]DebugConntrackEntry, len(entries[ns]))
+		copy(sorted, entries[ns])
+		sort.SliceStable(sorted, func(i, j int) bool { return lessDebugEntry(sorted[i], sorted[j]) })
+
+		dump.Header.Total += len(sorted)
+		dump.Header.Counts = append(dump.Header.Counts, conntrackDumpCount{NetNS: ns, Count: len(sorted)})
+		dump.Namespaces = append(dump.Namespaces, conntrackDumpNamespace{NetNS: ns, Entries: sorted})
+	}
+
+	return json.Marshal(dump)
+}
+
+func lessDebugEntry(a, b DebugConntrackEntry) bool {
+	if a.Proto != b.Proto {
+		return a.Proto < b.Proto
+	}
+	if a.Family != b.Family {
+		return a.Family < b.Family
+	}
+	if a.Origin != b.Origin {
+		return lessDebugTuple(a.Origin, b.Origin)
+	}
+	return lessDebugTuple(a.Reply, b.Reply)
+}
+
+func lessDebugTuple(a, b DebugConntrackTuple) bool {
+	if a.Src != b.Src {
+		return lessDebugAddress(a.Src, b.Src)
+	}
+	return lessDebugAddress(a.Dst, b.Dst)
+}
+
+func lessDebugAddress(a, b DebugConntrackAddress) bool {
+	if a.IP != b.IP {
+		return a.IP < b.IP
+	}
+	return a.Port < b.Port
+}
@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux && !android
+// +build linux,!android
+
+package netlink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalConntrackDump(t *testing.T) {
+	entries := map[uint32][]DebugConntrackEntry{
+		10: {
+			newDebugEntry("v4", "10.0.0.2", 5000, "1.1.1.1", 443),
+			newDebugEntry("v4", "10.0.0.1", 5000, "1.1.1.1", 443),
+		},
+		9: {
+			newDebugEntry("v6", "fd00::1", 6000, "fd00::2", 80),
+		},
+		11: {},
+	}
+
+	expected := `{"header":{"total":3,"counts":[{"netns":9,"count":1},{"netns":10,"count":2},{"netns":11,"count":0}]},` +
+		`"namespaces":[` +
+		`{"netns":9,"entries":[` +
+		`{"Proto":"TCP","Family":"v6","Origin":{"Src":{"IP":"fd00::1","Port":6000},"Dst":{"IP":"fd00::2","Port":80}},"Reply":{"Src":{"IP":"fd00::2","Port":80},"Dst":{"IP":"fd00::1","Port":6000}}}]},` +
+		`{"netns":10,"entries":[` +
+		`{"Proto":"TCP","Family":"v4","Origin":{"Src":{"IP":"10.0.0.1","Port":5000},"Dst":{"IP":"1.1.1.1","Port":443}},"Reply":{"Src":{"IP":"1.1.1.1","Port":443},"Dst":{"IP":"10.0.0.1","Port":5000}}},` +
+		`{"Proto":"TCP","Family":"v4","Origin":{"Src":{"IP":"10.0.0.2","Port":5000},"Dst":{"IP":"1.1.1.1","Port":443}},"Reply":{"Src":{"IP":"1.1.1.1","Port":443},"Dst":{"IP":"10.0.0.2","Port":5000}}}]},` +
+		`{"netns":11,"entries":[]}]}`
+
+	for i := 0; i < 10; i++ {
+		out, err := MarshalConntrackDump(entries)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(out))
+	}
+
+	// the input is left untouched
+	assert.Equal(t, "10.0.0.2", entries[10][0].Origin.Src.IP)
+
+	t.Run("empty", func(t *testing.T) {
+		out, err := MarshalConntrackDump(nil)
+		require.NoError(t, err)
+		assert.Equal(t, `{"header":{"total":0,"counts":[]},"namespaces":[]}`, string(out))
+	})
+}
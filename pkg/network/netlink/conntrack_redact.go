@@ -0,0 +1,120 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux && !android
+// +build linux,!android
+
+package netlink
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+const (
+	// number of leading bytes of an address kept as-is when redacting
+	redactedPrefixLenV4 = 2 // /16
+	redactedPrefixLenV6 = 8 // /64
+
+	redactionKeyLen = 32
+)
+
+// NewRedactionKey returns a random key to redact conntrack entries with. Pseudonyms are only
+// consistent between entries redacted with the same key, so a new key should be generated for
+// every dump to prevent correlating dumps and recovering addresses by hashing candidates.
+func NewRedactionKey() ([]byte, error) {
+	key := make([]byte, redactionKeyLen)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("could not generate redaction key: %w", err)
+	}
+	return key, nil
+}
+
+// RedactEntry returns a copy of the entry where the host bits of every IP address are replaced
+// with a pseudonym keyed by key. The network prefix and the address family are preserved so
+// that the redacted entry can still be analyzed, and the same address always yields the same
+// pseudonym under the same key. Ports are left untouched.
+func RedactEntry(e DebugConntrackEntry, key []byte) DebugConntrackEntry {
+	return redactEntry(e, key, false)
+}
+
+// RedactEntryWithPorts is like RedactEntry, but also replaces ports with keyed pseudonyms.
+func RedactEntryWithPorts(e DebugConntrackEntry, key []byte) DebugConntrackEntry {
+	return redactEntry(e, key, true)
+}
+
+// RedactTable redacts every entry of a conntrack table dump with the same key (see RedactEntry),
+// optionally redacting ports as well.
+func RedactTable(table map[uint32][]DebugConntrackEntry, key []byte, redactPorts bool) map[uint32][]DebugConntrackEntry {
+	redacted := make(map[uint32][]DebugConntrackEntry, len(table))
+	for ns, entries := range table {
+		redactedEntries := make([]DebugConntrackEntry, 0, len(entries))
+		for _, e := range entries {
+			redactedEntries = append(redactedEntries, redactEntry(e, key, redactPorts))
+		}
+		redacted[ns] = redactedEntries
+	}
+	return redacted
+}
+
+func redactEntry(e DebugConntrackEntry, key []byte, redactPorts bool) DebugConntrackEntry {
+	e.Origin = redactTuple(e.Origin, key, redactPorts)
+	e.Reply = redactTuple(e.Reply, key, redactPorts)
+	return e
+}
+
+func redactTuple(t DebugConntrackTuple, key []byte, redactPorts bool) DebugConntrackTuple {
+	t.Src = redactAddress(t.Src, key, redactPorts)
+	t.Dst = redactAddress(t.Dst, key, redactPorts)
+	return t
+}
+
+func redactAddress(a DebugConntrackAddress, key []byte, redactPorts bool) DebugConntrackAddress {
+	a.IP = redactIP(a.IP, key)
+	if redactPorts && a.Port != 0 {
+		a.Port = redactPort(a.Port, key)
+	}
+	return a
+}
+
+// keyedSum returns the HMAC-SHA256 of b under key
+func keyedSum(key []byte, b []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return mac.Sum(nil)
+}
+
+// redactIP replaces the host bits of ip with a keyed hash of the whole address.
+// Strings that cannot be parsed as an IP address are returned unchanged.
+func redactIP(ip string, key []byte) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+
+	prefixLen := redactedPrefixLenV6
+	if v4 := parsed.To4(); v4 != nil {
+		parsed = v4
+		prefixLen = redactedPrefixLenV4
+	}
+
+	sum := keyedSum(key, parsed)
+	redacted := make(net.IP, len(parsed))
+	copy(redacted, parsed[:prefixLen])
+	copy(redacted[prefixLen:], sum)
+	return redacted.String()
+}
+
+func redactPort(port uint16, key []byte) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], port)
+	sum := keyedSum(key, b[:])
+	// never map a port to 0, which stands for an unset port
+	return uint16(binary.BigEndian.Uint32(sum)%0xffff) + 1
+}
@@ -0,0 +1,129 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux && !android
+// +build linux,!android
+
+package netlink
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDebugEntry(family, src string, sport uint16, dst string, dport uint16) DebugConntrackEntry {
+	return DebugConntrackEntry{
+		Proto:  "TCP",
+		Family: family,
+		Origin: DebugConntrackTuple{
+			Src: DebugConntrackAddress{IP: src, Port: sport},
+			Dst: DebugConntrackAddress{IP: dst, Port: dport},
+		},
+		Reply: DebugConntrackTuple{
+			Src: DebugConntrackAddress{IP: dst, Port: dport},
+			Dst: DebugConntrackAddress{IP: src, Port: sport},
+		},
+	}
+}
+
+func newTestRedactionKey(t *testing.T) []byte {
+	key, err := NewRedactionKey()
+	require.NoError(t, err)
+	return key
+}
+
+func TestRedactEntryIPv4(t *testing.T) {
+	key := newTestRedactionKey(t)
+	e := newDebugEntry("v4", "10.0.12.34", 34567, "192.168.1.20", 80)
+	redacted := RedactEntry(e, key)
+
+	src := net.ParseIP(redacted.Origin.Src.IP)
+	dst := net.ParseIP(redacted.Origin.Dst.IP)
+	require.NotNil(t, src)
+	require.NotNil(t, dst)
+	assert.NotNil(t, src.To4())
+	assert.NotNil(t, dst.To4())
+
+	assert.NotEqual(t, e.Origin.Src.IP, redacted.Origin.Src.IP)
+	assert.NotEqual(t, e.Origin.Dst.IP, redacted.Origin.Dst.IP)
+	assert.True(t, strings.HasPrefix(redacted.Origin.Src.IP, "10.0."))
+	assert.True(t, strings.HasPrefix(redacted.Origin.Dst.IP, "192.168."))
+
+	// same input and key, same pseudonym
+	assert.Equal(t, redacted.Origin.Src.IP, redacted.Reply.Dst.IP)
+	assert.Equal(t, redacted.Origin.Dst.IP, redacted.Reply.Src.IP)
+	assert.Equal(t, redacted, RedactEntry(e, key))
+
+	// ports and other fields are left untouched
+	assert.Equal(t, e.Origin.Src.Port, redacted.Origin.Src.Port)
+	assert.Equal(t, e.Origin.Dst.Port, redacted.Origin.Dst.Port)
+	assert.Equal(t, e.Family, redacted.Family)
+	assert.Equal(t, e.Proto, redacted.Proto)
+}
+
+func TestRedactEntryIPv6(t *testing.T) {
+	key := newTestRedactionKey(t)
+	e := newDebugEntry("v6", "2001:db8:abcd:12::10", 34567, "fd00::1", 443)
+	redacted := RedactEntry(e, key)
+
+	src := net.ParseIP(redacted.Origin.Src.IP)
+	require.NotNil(t, src)
+	assert.Nil(t, src.To4())
+	assert.NotEqual(t, e.Origin.Src.IP, redacted.Origin.Src.IP)
+
+	_, prefix, err := net.ParseCIDR("2001:db8:abcd:12::/64")
+	require.NoError(t, err)
+	assert.True(t, prefix.Contains(src))
+
+	assert.Equal(t, redacted.Origin.Src.IP, redacted.Reply.Dst.IP)
+	assert.Equal(t, redacted, RedactEntry(e, key))
+}
+
+func TestRedactEntryKeys(t *testing.T) {
+	e := newDebugEntry("v4", "10.0.12.34", 34567, "192.168.1.20", 80)
+	redacted := RedactEntryWithPorts(e, newTestRedactionKey(t))
+	other := RedactEntryWithPorts(e, newTestRedactionKey(t))
+
+	// pseudonyms can't be correlated between keys
+	assert.NotEqual(t, redacted, other)
+}
+
+func TestRedactEntryWithPorts(t *testing.T) {
+	key := newTestRedactionKey(t)
+	e := newDebugEntry("v4", "10.0.12.34", 34567, "192.168.1.20", 80)
+	redacted := RedactEntryWithPorts(e, key)
+
+	assert.NotEqual(t, e.Origin.Src.Port, redacted.Origin.Src.Port)
+	assert.NotEqual(t, uint16(0), redacted.Origin.Src.Port)
+	assert.Equal(t, redacted.Origin.Src.Port, redacted.Reply.Dst.Port)
+	assert.Equal(t, redacted.Origin.Dst.Port, redacted.Reply.Src.Port)
+	assert.Equal(t, RedactEntry(e, key).Origin.Src.IP, redacted.Origin.Src.IP)
+}
+
+func TestRedactTable(t *testing.T) {
+	e1 := newDebugEntry("v4", "10.0.12.34", 34567, "192.168.1.20", 80)
+	e2 := newDebugEntry("v4", "10.0.12.35", 34568, "192.168.1.20", 80)
+	table := map[uint32][]DebugConntrackEntry{
+		1: {e1, e2},
+		2: {e1},
+	}
+
+	redacted := RedactTable(table, newTestRedactionKey(t), false)
+	require.Len(t, redacted, 2)
+	require.Len(t, redacted[1], 2)
+	require.Len(t, redacted[2], 1)
+
+	// consistent pseudonyms across namespaces
+	assert.Equal(t, redacted[1][0], redacted[2][0])
+	assert.NotEqual(t, redacted[1][0].Origin.Src.IP, redacted[1][1].Origin.Src.IP)
+	assert.Equal(t, redacted[1][0].Origin.Dst.IP, redacted[1][1].Origin.Dst.IP)
+
+	// the original table is left untouched
+	assert.Equal(t, "10.0.12.34", table[1][0].Origin.Src.IP)
+}
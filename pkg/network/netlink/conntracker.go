@@ -31,6 +31,15 @@ const (
 	defaultOrphanTimeout = 2 * time.Minute
 )
 
+// getLatencyBounds are the upper bounds of the buckets of the GetTranslationForConn latency histogram
+var getLatencyBounds = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+}
+
 // Conntracker is a wrapper around go-conntracker that keeps a record of all connections in user space
 type Conntracker interface {
 	GetTranslationForConn(network.ConnectionStats) *network.IPTranslation
@@ -38,6 +47,9 @@ type Conntracker interface {
 	IsSampling() bool
 	GetStats() map[string]int64
 	DumpCachedTable(context.Context) (map[uint32][]DebugConntrackEntry, error)
+	// SetConntrackRateLimit updates the rate limit (in netlink messages per second) of the conntrack events
+	// consumed by the conntracker. It is safe to call concurrently with the other methods.
+	SetConntrackRateLimit(n int)
 	Close()
 }
 
@@ -68,6 +80,7 @@ type stats struct {
 	unregisters          *atomic.Int64
 	unregistersTotalTime *atomic.Int64
 	evicts               *atomic.Int64
+	getLatencies         *LatencyHistogram
 }
 
 type realConntracker struct {
@@ -81,6 +94,9 @@ type realConntracker struct {
 
 	compactTicker *time.Ticker
 	stats         stats
+
+	// now returns the current time, it is used to measure the latency of lookups
+	now func() time.Time
 }
 
 // NewConntracker creates a new conntracker with a short term buffer capped at the given size
@@ -115,6 +131,7 @@ func newStats() stats {
 		unregisters:          atomic.NewInt64(0),
 		unregistersTotalTime: atomic.NewInt64(0),
 		evicts:               atomic.NewInt64(0),
+		getLatencies:         NewLatencyHistogram(getLatencyBounds...),
 	}
 }
 
@@ -127,6 +144,7 @@ func newConntrackerOnce(procRoot string, maxStateSize, targetRateLimit int, list
 		compactTicker: time.NewTicker(compactInterval),
 		decoder:       NewDecoder(),
 		stats:         newStats(),
+		now:           time.Now,
 	}
 
 	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
@@ -146,10 +164,12 @@ func newConntrackerOnce(procRoot string, maxStateSize, targetRateLimit int, list
 }
 
 func (ctr *realConntracker) GetTranslationForConn(c network.ConnectionStats) *network.IPTranslation {
-	then := time.Now().UnixNano()
+	then := ctr.now()
 	defer func() {
+		elapsed := ctr.now().Sub(then)
 		ctr.stats.gets.Inc()
-		ctr.stats.getTimeTotal.Add(time.Now().UnixNano() - then)
+		ctr.stats.getTimeTotal.Add(elapsed.Nanoseconds())
+		ctr.stats.getLatencies.Record(elapsed)
 	}()
 
 	ctr.Lock()
@@ -212,6 +232,17 @@ func (ctr *realConntracker) GetStats() map[string]int64 {
 	return m
 }
 
+// GetLatencyHistogram returns the number of GetTranslationForConn calls per latency bucket, indexed by bucket label
+func (ctr *realConntracker) GetLatencyHistogram() map[string]int64 {
+	return ctr.stats.getLatencies.Counts()
+}
+
+// SetConntrackRateLimit updates the rate limit of the consumer streaming the conntrack events,
+// which applies from its next throttle check
+func (ctr *realConntracker) SetConntrackRateLimit(n int) {
+	ctr.consumer.SetTargetRateLimit(n)
+}
+
 func (ctr *realConntracker) DeleteTranslation(c network.ConnectionStats) {
 	then := time.Now().UnixNano()
 	defer func() {
@@ -426,11 +426,65 @@ func crossCheckCacheOrphans(t *testing.T, cc *conntrackCache) {
 	}
 }
 
+func TestSetConntrackRateLimit(t *testing.T) {
+	rt := newConntracker(10)
+	rt.consumer = NewConsumer("/proc", 100, false)
+	defer rt.consumer.Stop()
+
+	var ctr Conntracker = rt
+	ctr.SetConntrackRateLimit(500)
+	assert.Equal(t, int64(500), rt.consumer.targetRateLimit.Load())
+	assert.Equal(t, int64(500), rt.consumer.breaker.maxEventsPerSec.Load())
+}
+
+func TestGetLatencyHistogram(t *testing.T) {
+	rt := newConntracker(10)
+
+	// each lookup reads the clock twice, the latency being the difference between the two readings
+	var durations []time.Duration
+	current := time.Now()
+	rt.now = func() time.Time {
+		current = current.Add(durations[0])
+		durations = durations[1:]
+		return current
+	}
+
+	c := network.ConnectionStats{
+		Source: util.AddressFromString("10.0.0.1"),
+		Dest:   util.AddressFromString("10.0.0.2"),
+		SPort:  12345,
+		DPort:  80,
+		Type:   network.TCP,
+	}
+	for _, d := range []time.Duration{
+		500 * time.Nanosecond,
+		time.Microsecond,
+		50 * time.Microsecond,
+		2 * time.Millisecond,
+		2 * time.Millisecond,
+		time.Second,
+	} {
+		durations = append(durations, 0, d)
+		rt.GetTranslationForConn(c)
+	}
+
+	assert.Equal(t, map[string]int64{
+		"le_1us":   2,
+		"le_10us":  0,
+		"le_100us": 1,
+		"le_1ms":   0,
+		"le_10ms":  2,
+		"gt_10ms":  1,
+	}, rt.GetLatencyHistogram())
+	assert.Equal(t, int64(6), rt.stats.gets.Load())
+}
+
 func newConntracker(maxSize int) *realConntracker {
 	rt := &realConntracker{
 		maxStateSize: maxSize,
 		cache:        newConntrackCache(maxSize, defaultOrphanTimeout),
 		stats:        newStats(),
+		now:          time.Now,
 	}
 
 	return rt
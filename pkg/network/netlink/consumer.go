@@ -70,7 +70,7 @@ type Consumer struct {
 
 	// targetRateLimit represents the maximum number of netlink messages per second
 	// that can be read off the netlink socket. Setting it to -1 disables the limit.
-	targetRateLimit int
+	targetRateLimit *atomic.Int64
 
 	// samplingRate must be a value between 0 and 1 (inclusive) which is adjusted dynamically.
 	// this represents the amount of sampling we apply to the netlink socket via a BPF filter
@@ -107,6 +107,11 @@ type Event struct {
 	pool   *sync.Pool
 }
 
+// NewEvent returns an Event holding the given netlink messages, received from the network namespace netns
+func NewEvent(msgs []netlink.Message, netns uint32) Event {
+	return Event{msgs: msgs, netns: netns}
+}
+
 // Messages returned from the socket read
 func (e *Event) Messages() []netlink.Message {
 	return e.msgs
@@ -125,7 +130,7 @@ func NewConsumer(procRoot string, targetRateLimit int, listenAllNamespaces bool)
 	c := &Consumer{
 		procRoot:            procRoot,
 		pool:                newBufferPool(),
-		targetRateLimit:     targetRateLimit,
+		targetRateLimit:     atomic.NewInt64(int64(targetRateLimit)),
 		breaker:             NewCircuitBreaker(int64(targetRateLimit)),
 		netlinkSeqNumber:    1,
 		listenAllNamespaces: listenAllNamespaces,
@@ -140,6 +145,13 @@ func NewConsumer(procRoot string, targetRateLimit int, listenAllNamespaces bool)
 	return c
 }
 
+// SetTargetRateLimit updates the maximum number of netlink messages per second that can be read off the socket.
+// It is safe to call concurrently with the receive loop; the new limit applies from the next throttle check.
+func (c *Consumer) SetTargetRateLimit(targetRateLimit int) {
+	c.targetRateLimit.Store(int64(targetRateLimit))
+	c.breaker.SetMaxEventsPerSec(int64(targetRateLimit))
+}
+
 // Events returns a channel of Event objects (wrapping netlink messages) which receives
 // all new connections added to the Conntrack table.
 func (c *Consumer) Events() (<-chan Event, error) {
@@ -475,7 +487,8 @@ func (c *Consumer) eventFor(msgs []netlink.Message, netns uint32, buffer *[]byte
 func (c *Consumer) throttle(numMessages int) error {
 	// We don't throttle the socket during initialization
 	// (when we dump the whole Conntrack table)
-	if !c.streaming || c.targetRateLimit == -1 {
+	targetRateLimit := c.targetRateLimit.Load()
+	if !c.streaming || targetRateLimit == -1 {
 		return nil
 	}
 
@@ -492,13 +505,13 @@ func (c *Consumer) throttle(numMessages int) error {
 	if pre315Kernel {
 		// we cannot recreate the socket and set a bpf filter on
 		// kernels before 3.15, so we bail here
-		log.Errorf("conntrack sampling not supported on kernel versions < 3.15. Please adjust system_probe_config.conntrack_rate_limit (currently set to %d) to accommodate higher conntrack update rate detected", c.targetRateLimit)
+		log.Errorf("conntrack sampling not supported on kernel versions < 3.15. Please adjust system_probe_config.conntrack_rate_limit (currently set to %d) to accommodate higher conntrack update rate detected", targetRateLimit)
 		return fmt.Errorf("conntrack sampling rate not supported")
 	}
 
 	// Create new socket with the desired sampling rate
 	// We calculate the required sampling rate to reach the target maxMessagesPersecond
-	samplingRate := (float64(c.targetRateLimit) / float64(c.breaker.Rate())) * c.samplingRate * overshootFactor
+	samplingRate := (float64(targetRateLimit) / float64(c.breaker.Rate())) * c.samplingRate * overshootFactor
 	err := c.initNetlinkSocket(samplingRate)
 	if err != nil {
 		log.Errorf("failed to re-create netlink socket. exiting conntrack: %s", err)
@@ -9,6 +9,7 @@
 package netlink
 
 import (
+	"math"
 	"net"
 	"testing"
 	"time"
@@ -79,3 +80,18 @@ func TestConsumerKeepsRunningAfterCircuitBreakerTrip(t *testing.T) {
 	}
 
 }
+
+func TestConsumerSetTargetRateLimit(t *testing.T) {
+	c := NewConsumer("/proc", 100, false)
+	require.NotNil(t, c)
+	defer c.Stop()
+
+	c.SetTargetRateLimit(500)
+	require.Equal(t, int64(500), c.targetRateLimit.Load())
+	require.Equal(t, int64(500), c.breaker.maxEventsPerSec.Load())
+
+	// -1 disables the limit
+	c.SetTargetRateLimit(-1)
+	require.Equal(t, int64(-1), c.targetRateLimit.Load())
+	require.Equal(t, int64(math.MaxInt64), c.breaker.maxEventsPerSec.Load())
+}
@@ -0,0 +1,122 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux && !android
+// +build linux,!android
+
+package netlink
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// LatencyHistogram is a lock free histogram of latencies with fixed buckets,
+// latencies above the last bound being counted in an additional overflow bucket
+type LatencyHistogram struct {
+	bounds []time.Duration
+	counts []*atomic.Int64
+}
+
+// NewLatencyHistogram creates a histogram whose buckets have the given increasing upper bounds
+func NewLatencyHistogram(bounds ...time.Duration) *LatencyHistogram {
+	counts := make([]*atomic.Int64, len(bounds)+1)
+	for i := range counts {
+		counts[i] = atomic.NewInt64(0)
+	}
+	return &LatencyHistogram{bounds: bounds, counts: counts}
+}
+
+// Record counts a latency in the bucket of the smallest bound above it, or in the overflow bucket
+func (h *LatencyHistogram) Record(d time.Duration) {
+	for i, bound := range h.bounds {
+		if d <= bound {
+			h.counts[i].Inc()
+			return
+		}
+	}
+	h.counts[len(h.bounds)].Inc()
+}
+
+// Counts returns the number of latencies recorded per bucket, labelled le_<bound>, and
+// in the overflow bucket, labelled gt_<last bound>
+func (h *LatencyHistogram) Counts() map[string]int64 {
+	counts := make(map[string]int64, len(h.counts))
+	for i, bound := range h.bounds {
+		counts["le_"+boundLabel(bound)] = h.counts[i].Load()
+	}
+	counts[h.overflowLabel()] = h.counts[len(h.bounds)].Load()
+	return counts
+}
+
+// Percentile returns the upper bound of the bucket holding the p-th percentile of the recorded latencies.
+// It returns false if no latency was recorded, or if the percentile falls in the overflow bucket, which
+// has no upper bound.
+func (h *LatencyHistogram) Percentile(p float64) (time.Duration, bool) {
+	counts := make([]int64, len(h.counts))
+	var total int64
+	for i, c := range h.counts {
+		counts[i] = c.Load()
+		total += counts[i]
+	}
+	if total == 0 {
+		return 0, false
+	}
+
+	// rank of the percentile, between 1 and total
+	rank := int64(p*float64(total)/100 + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, bound := range h.bounds {
+		seen += counts[i]
+		if seen >= rank {
+			return bound, true
+		}
+	}
+	return 0, false
+}
+
+// AddPercentiles adds the 50th, 95th and 99th percentiles in nanoseconds of the histogram to stats, as
+// <prefix>_p50, <prefix>_p95 and <prefix>_p99, along with the number of latencies above the last bound as
+// <prefix>_gt_<last bound>. The percentiles falling in the overflow bucket are left out.
+func (h *LatencyHistogram) AddPercentiles(stats map[string]int64, prefix string) {
+	for _, p := range []struct {
+		suffix string
+		value  float64
+	}{
+		{"_p50", 50},
+		{"_p95", 95},
+		{"_p99", 99},
+	} {
+		if d, ok := h.Percentile(p.value); ok {
+			stats[prefix+p.suffix] = d.Nanoseconds()
+		}
+	}
+	stats[prefix+"_"+h.overflowLabel()] = h.counts[len(h.bounds)].Load()
+}
+
+func (h *LatencyHistogram) overflowLabel() string {
+	if len(h.bounds) == 0 {
+		return "gt_0ns"
+	}
+	return "gt_" + boundLabel(h.bounds[len(h.bounds)-1])
+}
+
+// boundLabel formats a bucket bound in the largest unit dividing it, among ms, us and ns
+func boundLabel(d time.Duration) string {
+	switch {
+	case d%time.Millisecond == 0:
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	case d%time.Microsecond == 0:
+		return fmt.Sprintf("%dus", d/time.Microsecond)
+	default:
+		return fmt.Sprintf("%dns", d.Nanoseconds())
+	}
+}
@@ -0,0 +1,86 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux && !android
+// +build linux,!android
+
+package netlink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testLatencyBounds = []time.Duration{
+	time.Microsecond,
+	2 * time.Microsecond,
+	5 * time.Microsecond,
+	200 * time.Microsecond,
+	10 * time.Millisecond,
+}
+
+func TestLatencyHistogramPercentiles(t *testing.T) {
+	h := NewLatencyHistogram(testLatencyBounds...)
+	_, ok := h.Percentile(50)
+	assert.False(t, ok)
+
+	// 90 fast lookups, 8 slower ones and 2 outliers above the last bucket
+	for i := 0; i < 90; i++ {
+		h.Record(800 * time.Nanosecond)
+	}
+	for i := 0; i < 8; i++ {
+		h.Record(150 * time.Microsecond)
+	}
+	for i := 0; i < 2; i++ {
+		h.Record(time.Second)
+	}
+
+	stats := make(map[string]int64)
+	h.AddPercentiles(stats, "nanoseconds_per_get")
+	assert.Equal(t, map[string]int64{
+		"nanoseconds_per_get_p50":     time.Microsecond.Nanoseconds(),
+		"nanoseconds_per_get_p95":     (200 * time.Microsecond).Nanoseconds(),
+		"nanoseconds_per_get_gt_10ms": 2,
+	}, stats)
+
+	// the 99th percentile is above the last bound
+	_, ok = h.Percentile(99)
+	assert.False(t, ok)
+}
+
+func TestLatencyHistogramBucketBounds(t *testing.T) {
+	h := NewLatencyHistogram(testLatencyBounds...)
+	h.Record(2 * time.Microsecond)
+
+	d, ok := h.Percentile(50)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Microsecond, d)
+
+	h = NewLatencyHistogram(testLatencyBounds...)
+	h.Record(2*time.Microsecond + 1)
+	d, _ = h.Percentile(50)
+	assert.Equal(t, 5*time.Microsecond, d)
+}
+
+func TestLatencyHistogramCounts(t *testing.T) {
+	h := NewLatencyHistogram(500*time.Nanosecond, 100*time.Microsecond, 10*time.Millisecond)
+	for _, d := range []time.Duration{
+		100 * time.Nanosecond,
+		50 * time.Microsecond,
+		time.Second,
+		time.Second,
+	} {
+		h.Record(d)
+	}
+
+	assert.Equal(t, map[string]int64{
+		"le_500ns": 1,
+		"le_100us": 1,
+		"le_10ms":  0,
+		"gt_10ms":  2,
+	}, h.Counts())
+}
@@ -33,6 +33,8 @@ func (*noOpConntracker) IsSampling() bool {
 	return false
 }
 
+func (*noOpConntracker) SetConntrackRateLimit(n int) {}
+
 func (*noOpConntracker) Close() {}
 
 func (*noOpConntracker) GetStats() map[string]int64 {
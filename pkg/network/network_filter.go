@@ -7,6 +7,7 @@ package network
 
 import (
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -198,3 +199,95 @@ func findMatchingFilter(cf []*ConnectionFilter, ip netaddr.IP, addrPort uint16,
 	}
 	return false
 }
+
+// ParseConnectionFilter builds a connection predicate from query parameters.
+// The supported parameters are `proto` (tcp or udp), `port` (matching either the source or destination port),
+// `addr` (an IP or CIDR matching either the source or destination address) and `family` (v4 or v6).
+// A connection must match all the given parameters, and any of the values of a repeated parameter.
+func ParseConnectionFilter(params url.Values) (func(*ConnectionStats) bool, error) {
+	var predicates []func(*ConnectionStats) bool
+	for param, values := range params {
+		var matchers []func(*ConnectionStats) bool
+		for _, value := range values {
+			matcher, err := parseConnectionFilterValue(param, strings.TrimSpace(value))
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, matcher)
+		}
+		if len(matchers) > 0 {
+			predicates = append(predicates, anyConnectionMatcher(matchers))
+		}
+	}
+
+	return func(c *ConnectionStats) bool {
+		for _, predicate := range predicates {
+			if !predicate(c) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func anyConnectionMatcher(matchers []func(*ConnectionStats) bool) func(*ConnectionStats) bool {
+	return func(c *ConnectionStats) bool {
+		for _, matcher := range matchers {
+			if matcher(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func parseConnectionFilterValue(param, value string) (func(*ConnectionStats) bool, error) {
+	switch param {
+	case "proto":
+		var connType ConnectionType
+		switch strings.ToLower(value) {
+		case "tcp":
+			connType = TCP
+		case "udp":
+			connType = UDP
+		default:
+			return nil, fmt.Errorf("invalid proto filter: %q", value)
+		}
+		return func(c *ConnectionStats) bool { return c.Type == connType }, nil
+	case "port":
+		port, err := parsePortString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port filter %q: %s", value, err)
+		}
+		return func(c *ConnectionStats) bool { return c.SPort == uint16(port) || c.DPort == uint16(port) }, nil
+	case "addr":
+		var prefix netaddr.IPPrefix
+		if strings.ContainsRune(value, '/') {
+			p, err := netaddr.ParseIPPrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid addr filter %q: %s", value, err)
+			}
+			prefix = p.Masked()
+		} else {
+			ip, err := netaddr.ParseIP(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid addr filter %q: %s", value, err)
+			}
+			prefix = netaddr.IPPrefixFrom(ip, ip.BitLen())
+		}
+		return func(c *ConnectionStats) bool { return prefix.Contains(c.Source.IP) || prefix.Contains(c.Dest.IP) }, nil
+	case "family":
+		var family ConnectionFamily
+		switch strings.ToLower(value) {
+		case "v4":
+			family = AFINET
+		case "v6":
+			family = AFINET6
+		default:
+			return nil, fmt.Errorf("invalid family filter: %q", value)
+		}
+		return func(c *ConnectionStats) bool { return c.Family == family }, nil
+	default:
+		return nil, fmt.Errorf("unrecognized connection filter: %q", param)
+	}
+}
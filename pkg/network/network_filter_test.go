@@ -7,10 +7,12 @@ package network
 
 import (
 	"math/rand"
+	"net/url"
 	"testing"
 
 	"github.com/DataDog/datadog-agent/pkg/process/util"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testSourceFilters = map[string][]string{
@@ -132,3 +134,88 @@ func randIPv6(count int) (addrs []util.Address) {
 	}
 	return addrs
 }
+
+func TestParseConnectionFilter(t *testing.T) {
+	tcpV4 := &ConnectionStats{
+		Source: util.AddressFromString("10.0.0.1"),
+		Dest:   util.AddressFromString("10.0.1.5"),
+		SPort:  53361,
+		DPort:  443,
+		Type:   TCP,
+		Family: AFINET,
+	}
+	udpV4 := &ConnectionStats{
+		Source: util.AddressFromString("192.168.1.1"),
+		Dest:   util.AddressFromString("8.8.8.8"),
+		SPort:  40000,
+		DPort:  53,
+		Type:   UDP,
+		Family: AFINET,
+	}
+	tcpV6 := &ConnectionStats{
+		Source: util.AddressFromString("2001:db8::1"),
+		Dest:   util.AddressFromString("2001:db8::2"),
+		SPort:  8080,
+		DPort:  443,
+		Type:   TCP,
+		Family: AFINET6,
+	}
+	conns := []*ConnectionStats{tcpV4, udpV4, tcpV6}
+
+	tests := []struct {
+		query    string
+		expected []*ConnectionStats
+	}{
+		{"", conns},
+		{"proto=tcp", []*ConnectionStats{tcpV4, tcpV6}},
+		{"proto=UDP", []*ConnectionStats{udpV4}},
+		{"port=443", []*ConnectionStats{tcpV4, tcpV6}},
+		{"port=53361", []*ConnectionStats{tcpV4}},
+		{"addr=8.8.8.8", []*ConnectionStats{udpV4}},
+		{"addr=10.0.0.0/16", []*ConnectionStats{tcpV4}},
+		{"addr=2001:db8::/64", []*ConnectionStats{tcpV6}},
+		{"family=v6", []*ConnectionStats{tcpV6}},
+		{"proto=tcp&family=v4", []*ConnectionStats{tcpV4}},
+		{"proto=tcp&port=53", nil},
+		{"port=53&port=8080", []*ConnectionStats{udpV4, tcpV6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			filter, err := ParseConnectionFilter(params)
+			require.NoError(t, err)
+
+			var matched []*ConnectionStats
+			for _, c := range conns {
+				if filter(c) {
+					matched = append(matched, c)
+				}
+			}
+			assert.Equal(t, tt.expected, matched)
+		})
+	}
+}
+
+func TestParseConnectionFilterInvalid(t *testing.T) {
+	for _, query := range []string{
+		"unknown=1",
+		"proto=icmp",
+		"port=65536",
+		"port=abc",
+		"addr=10.0.0",
+		"addr=10.0.0.0/33",
+		"family=v5",
+		"proto=tcp&direction=outgoing",
+	} {
+		t.Run(query, func(t *testing.T) {
+			params, err := url.ParseQuery(query)
+			require.NoError(t, err)
+
+			_, err = ParseConnectionFilter(params)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -259,6 +259,31 @@ func TestTelemetryDiffing(t *testing.T) {
 	})
 }
 
+func TestDriverFlowTelemetryDeltaPerClient(t *testing.T) {
+	state := newDefaultState()
+	state.RegisterClient("check")
+	state.RegisterClient("debug")
+
+	driverTelemetry := func(collisions, dropped int64) map[ConnTelemetryType]int64 {
+		return map[ConnTelemetryType]int64{
+			MonotonicDriverFlowCollisions: collisions,
+			MonotonicDriverFlowsDropped:   dropped,
+		}
+	}
+
+	_ = state.GetTelemetryDelta("check", driverTelemetry(100, 10))
+	_ = state.GetTelemetryDelta("debug", driverTelemetry(110, 20))
+
+	// each client gets the flows since its own previous call, however small their number
+	delta := state.GetTelemetryDelta("check", driverTelemetry(130, 35))
+	assert.Equal(t, int64(30), delta[MonotonicDriverFlowCollisions])
+	assert.Equal(t, int64(25), delta[MonotonicDriverFlowsDropped])
+
+	delta = state.GetTelemetryDelta("debug", driverTelemetry(131, 35))
+	assert.Equal(t, int64(21), delta[MonotonicDriverFlowCollisions])
+	assert.Equal(t, int64(15), delta[MonotonicDriverFlowsDropped])
+}
+
 func TestNoPriorRegistrationActiveConnections(t *testing.T) {
 	clientID := "1"
 	state := newDefaultState()
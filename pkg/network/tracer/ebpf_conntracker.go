@@ -33,6 +33,26 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+// initialDumpProgressInterval is the number of entries of the initial conntrack dump between two progress reports
+var initialDumpProgressInterval = 10000
+
+// latencyBounds are the upper bounds of the buckets of the get and unregister latency histograms
+var latencyBounds = []time.Duration{
+	time.Microsecond,
+	2 * time.Microsecond,
+	5 * time.Microsecond,
+	10 * time.Microsecond,
+	20 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	200 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+}
+
 var tuplePool = sync.Pool{
 	New: func() interface{} {
 		return new(netebpf.ConntrackTuple)
@@ -44,6 +64,8 @@ type ebpfConntrackerStats struct {
 	getTotalTime         *atomic.Int64
 	unregisters          *atomic.Int64
 	unregistersTotalTime *atomic.Int64
+	getLatencies         *netlink.LatencyHistogram
+	unregisterLatencies  *netlink.LatencyHistogram
 }
 
 func newEbpfConntrackerStats() ebpfConntrackerStats {
@@ -52,10 +74,21 @@ func newEbpfConntrackerStats() ebpfConntrackerStats {
 		getTotalTime:         atomic.NewInt64(0),
 		unregisters:          atomic.NewInt64(0),
 		unregistersTotalTime: atomic.NewInt64(0),
+		getLatencies:         netlink.NewLatencyHistogram(latencyBounds...),
+		unregisterLatencies:  netlink.NewLatencyHistogram(latencyBounds...),
 	}
 }
 
+// ConntrackMismatch describes an entry of the eBPF conntrack map disagreeing with the kernel conntrack table
+type ConntrackMismatch struct {
+	Key    netebpf.ConntrackTuple
+	Cached netebpf.ConntrackTuple
+	// Kernel is nil if the entry is absent from the kernel conntrack table
+	Kernel *netebpf.ConntrackTuple
+}
+
 type ebpfConntracker struct {
+	cfg          *config.Config
 	m            *manager.Manager
 	ctMap        *ebpf.Map
 	telemetryMap *ebpf.Map
@@ -64,6 +97,9 @@ type ebpfConntracker struct {
 	consumer *netlink.Consumer
 	decoder  *netlink.Decoder
 
+	// dumpKernelTable returns the NAT entries of the kernel conntrack table, overridden in tests
+	dumpKernelTable func(ctx context.Context) ([]netlink.Con, error)
+
 	stats ebpfConntrackerStats
 }
 
@@ -110,12 +146,14 @@ func NewEBPFConntracker(cfg *config.Config) (netlink.Conntracker, error) {
 	}
 
 	e := &ebpfConntracker{
+		cfg:          cfg,
 		m:            m,
 		ctMap:        ctMap,
 		telemetryMap: telemetryMap,
 		rootNS:       rootNS,
 		stats:        newEbpfConntrackerStats(),
 	}
+	e.dumpKernelTable = e.dumpNetlinkTable
 
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConntrackInitTimeout)
 	defer cancel()
@@ -136,19 +174,68 @@ func (e *ebpfConntracker) dumpInitialTables(ctx context.Context, cfg *config.Con
 	e.decoder = netlink.NewDecoder()
 	defer e.consumer.Stop()
 
+	progress := func(processed int) {
+		log.Infof("ebpf conntrack initial dump: %d entries processed", processed)
+	}
+
+	processed := 0
 	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
 		events, err := e.consumer.DumpTable(family)
 		if err != nil {
 			return err
 		}
-		if err := e.loadInitialState(ctx, events); err != nil {
+		if processed, err = e.loadInitialState(ctx, events, processed, progress); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (e *ebpfConntracker) loadInitialState(ctx context.Context, events <-chan netlink.Event) error {
+// EstimateConntrackSize returns the number of entries the initial dump of the eBPF conntracker would add to its map
+// for IPv4 and IPv6, without loading any eBPF program: two per NAT entry of the conntrack table, one for each direction,
+// and none for the families disabled in cfg. Their sum is the ConntrackMaxStateSize needed to hold the current table.
+func EstimateConntrackSize(ctx context.Context, cfg *config.Config) (v4 int, v6 int, err error) {
+	consumer := netlink.NewConsumer(cfg.ProcRoot, cfg.ConntrackRateLimit, true)
+	defer consumer.Stop()
+
+	return countMapEntries(ctx, cfg, consumer.DumpTable)
+}
+
+// countMapEntries dumps the conntrack table of each family with dumpTable and counts the entries that loading it
+// would add to the eBPF map, until ctx is done
+func countMapEntries(ctx context.Context, cfg *config.Config, dumpTable func(family uint8) (<-chan netlink.Event, error)) (v4 int, v6 int, err error) {
+	decoder := netlink.NewDecoder()
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		events, err := dumpTable(family)
+		if err != nil {
+			return v4, v6, err
+		}
+
+		count := 0
+		err = drainEvents(ctx, events, func(ev netlink.Event) {
+			for _, c := range decoder.DecodeAndReleaseEvent(ev) {
+				if familyEnabled(cfg, conTupleFamily(&c.Origin)) && netlink.IsNAT(c) {
+					// processEvent adds a translation for each direction
+					count += 2
+				}
+			}
+		})
+		if err != nil {
+			return v4, v6, err
+		}
+
+		if family == unix.AF_INET {
+			v4 = count
+		} else {
+			v6 = count
+		}
+	}
+	return v4, v6, nil
+}
+
+// drainEvents calls handle with each of the events until the channel is closed. It returns the error of ctx if
+// ctx is done first.
+func drainEvents(ctx context.Context, events <-chan netlink.Event, handle func(ev netlink.Event)) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -157,14 +244,39 @@ func (e *ebpfConntracker) loadInitialState(ctx context.Context, events <-chan ne
 			if !ok {
 				return nil
 			}
-			e.processEvent(ev)
+			handle(ev)
 		}
 	}
 }
 
-func (e *ebpfConntracker) processEvent(ev netlink.Event) {
+// loadInitialState adds the NAT entries of the dumped conntrack table to the eBPF map, until the events channel is
+// closed or ctx is done. It returns the number of entries processed, starting at processed. If not nil, progress
+// is called with this number every initialDumpProgressInterval entries, and once all the events are processed.
+func (e *ebpfConntracker) loadInitialState(ctx context.Context, events <-chan netlink.Event, processed int, progress func(processed int)) (int, error) {
+	nextProgress := processed + initialDumpProgressInterval
+	err := drainEvents(ctx, events, func(ev netlink.Event) {
+		processed += e.processEvent(ev)
+		if progress != nil && processed >= nextProgress {
+			progress(processed)
+			nextProgress = processed + initialDumpProgressInterval
+		}
+	})
+	if err != nil {
+		return processed, err
+	}
+	if progress != nil {
+		progress(processed)
+	}
+	return processed, nil
+}
+
+// processEvent adds the NAT entries of an event to the eBPF map and returns the number of entries it holds
+func (e *ebpfConntracker) processEvent(ev netlink.Event) int {
 	conns := e.decoder.DecodeAndReleaseEvent(ev)
 	for _, c := range conns {
+		if !familyEnabled(e.cfg, conTupleFamily(&c.Origin)) {
+			continue
+		}
 		if netlink.IsNAT(c) {
 			log.Tracef("initial conntrack %s", c)
 			src := formatKey(c.NetNS, &c.Origin)
@@ -179,6 +291,25 @@ func (e *ebpfConntracker) processEvent(ev netlink.Event) {
 			}
 		}
 	}
+	return len(conns)
+}
+
+// familyEnabled returns whether the translations of the connections of the given family are tracked with cfg
+func familyEnabled(cfg *config.Config, family network.ConnectionFamily) bool {
+	if cfg == nil {
+		return true
+	}
+	if family == network.AFINET6 {
+		return cfg.CollectIPv6Conns
+	}
+	return !cfg.ConntrackDisableIPv4
+}
+
+func conTupleFamily(tuple *netlink.ConTuple) network.ConnectionFamily {
+	if tuple.Src.IP().Is4() {
+		return network.AFINET
+	}
+	return network.AFINET6
 }
 
 func (e *ebpfConntracker) addTranslation(src *netebpf.ConntrackTuple, dst *netebpf.ConntrackTuple) error {
@@ -236,6 +367,10 @@ func toConntrackTupleFromStats(src *netebpf.ConntrackTuple, stats *network.Conne
 }
 
 func (e *ebpfConntracker) GetTranslationForConn(stats network.ConnectionStats) *network.IPTranslation {
+	if !familyEnabled(e.cfg, stats.Family) {
+		return nil
+	}
+
 	start := time.Now()
 	src := tuplePool.Get().(*netebpf.ConntrackTuple)
 	defer tuplePool.Put(src)
@@ -266,8 +401,10 @@ func (e *ebpfConntracker) GetTranslationForConn(stats network.ConnectionStats) *
 	}
 	defer tuplePool.Put(dst)
 
+	elapsed := time.Now().Sub(start)
 	e.stats.gets.Inc()
-	e.stats.getTotalTime.Add(time.Now().Sub(start).Nanoseconds())
+	e.stats.getTotalTime.Add(elapsed.Nanoseconds())
+	e.stats.getLatencies.Record(elapsed)
 	return &network.IPTranslation{
 		ReplSrcIP:   dst.SourceAddress(),
 		ReplDstIP:   dst.DestAddress(),
@@ -276,20 +413,83 @@ func (e *ebpfConntracker) GetTranslationForConn(stats network.ConnectionStats) *
 	}
 }
 
+// GetTranslationsForConns returns the translations of a batch of connections, as a slice parallel to conns
+// holding nil for the connections without translation. It behaves as calling GetTranslationForConn on
+// each connection, but reuses the same tuples across the batch.
+func (e *ebpfConntracker) GetTranslationsForConns(conns []network.ConnectionStats) []*network.IPTranslation {
+	start := time.Now()
+	src := tuplePool.Get().(*netebpf.ConntrackTuple)
+	defer tuplePool.Put(src)
+	dst := tuplePool.Get().(*netebpf.ConntrackTuple)
+	defer tuplePool.Put(dst)
+
+	var found int64
+	translations := make([]*network.IPTranslation, len(conns))
+	for i := range conns {
+		stats := &conns[i]
+		if !familyEnabled(e.cfg, stats.Family) {
+			continue
+		}
+		toConntrackTupleFromStats(src, stats)
+
+		// Try the lookup in the root namespace first, then in the connection namespace
+		src.Netns = e.rootNS
+		ok := e.lookup(src, dst)
+		if !ok && stats.NetNS != e.rootNS {
+			src.Netns = stats.NetNS
+			ok = e.lookup(src, dst)
+		}
+		if !ok {
+			continue
+		}
+
+		found++
+		translations[i] = &network.IPTranslation{
+			ReplSrcIP:   dst.SourceAddress(),
+			ReplDstIP:   dst.DestAddress(),
+			ReplSrcPort: dst.Sport,
+			ReplDstPort: dst.Dport,
+		}
+	}
+
+	if found > 0 {
+		elapsed := time.Now().Sub(start)
+		e.stats.gets.Add(found)
+		e.stats.getTotalTime.Add(elapsed.Nanoseconds())
+		// individual lookups are not timed, each of them is accounted for with the average latency of the batch
+		for i := int64(0); i < found; i++ {
+			e.stats.getLatencies.Record(elapsed / time.Duration(found))
+		}
+	}
+	return translations
+}
+
+// SetConntrackRateLimit is a no-op: the eBPF conntracker doesn't stream conntrack events from netlink, its
+// consumer only dumping the conntrack tables at startup, which isn't rate limited.
+func (*ebpfConntracker) SetConntrackRateLimit(n int) {}
+
 func (*ebpfConntracker) IsSampling() bool {
 	return false
 }
 
 func (e *ebpfConntracker) get(src *netebpf.ConntrackTuple) *netebpf.ConntrackTuple {
 	dst := tuplePool.Get().(*netebpf.ConntrackTuple)
+	if !e.lookup(src, dst) {
+		tuplePool.Put(dst)
+		return nil
+	}
+	return dst
+}
+
+// lookup reads the entry of src in the conntrack map into dst, returning whether it was found
+func (e *ebpfConntracker) lookup(src, dst *netebpf.ConntrackTuple) bool {
 	if err := e.ctMap.Lookup(unsafe.Pointer(src), unsafe.Pointer(dst)); err != nil {
 		if !errors.Is(err, ebpf.ErrKeyNotExist) {
 			log.Warnf("error looking up connection in ebpf conntrack map: %s", err)
 		}
-		tuplePool.Put(dst)
-		return nil
+		return false
 	}
-	return dst
+	return true
 }
 
 func (e *ebpfConntracker) delete(key *netebpf.ConntrackTuple) {
@@ -315,14 +515,21 @@ func (e *ebpfConntracker) DeleteTranslation(stats network.ConnectionStats) {
 		e.delete(dst)
 		tuplePool.Put(dst)
 	}
+	elapsed := time.Now().Sub(start)
 	e.stats.unregisters.Inc()
-	e.stats.unregistersTotalTime.Add(time.Now().Sub(start).Nanoseconds())
+	e.stats.unregistersTotalTime.Add(elapsed.Nanoseconds())
+	e.stats.unregisterLatencies.Record(elapsed)
 }
 
 func (e *ebpfConntracker) GetStats() map[string]int64 {
 	m := map[string]int64{
 		"state_size": 0,
 	}
+	if e.cfg != nil {
+		for k, v := range e.stateSizeStats() {
+			m[k] = v
+		}
+	}
 	telemetry := &netebpf.ConntrackTelemetry{}
 	if err := e.telemetryMap.Lookup(unsafe.Pointer(&zero), unsafe.Pointer(telemetry)); err != nil {
 		log.Tracef("error retrieving the telemetry struct: %s", err)
@@ -337,6 +544,7 @@ func (e *ebpfConntracker) GetStats() map[string]int64 {
 	if gets > 0 {
 		m["nanoseconds_per_get"] = getTimeTotal / gets
 	}
+	e.stats.getLatencies.AddPercentiles(m, "nanoseconds_per_get")
 
 	unregisters := e.stats.unregisters.Load()
 	unregistersTimeTotal := e.stats.unregistersTotalTime.Load()
@@ -344,6 +552,7 @@ func (e *ebpfConntracker) GetStats() map[string]int64 {
 	if unregisters > 0 {
 		m["nanoseconds_per_unregister"] = unregistersTimeTotal / unregisters
 	}
+	e.stats.unregisterLatencies.AddPercentiles(m, "nanoseconds_per_unregister")
 
 	// Merge telemetry from the consumer
 	for k, v := range e.consumer.GetStats() {
@@ -360,20 +569,122 @@ func (e *ebpfConntracker) Close() {
 	}
 }
 
+// stateSizeStats returns the maximum size of the conntrack map, along with its live size and saturation
+// percentage if enabled in the configuration, computing them requiring to iterate over the whole map
+func (e *ebpfConntracker) stateSizeStats() map[string]int64 {
+	maxSize := int64(e.cfg.ConntrackMaxStateSize)
+	m := map[string]int64{
+		"max_state_size": maxSize,
+	}
+	if !e.cfg.EnableConntrackStateSizeStats {
+		return m
+	}
+
+	var size int64
+	for _, count := range e.GetNamespaceStats() {
+		size += int64(count)
+	}
+	m["state_size"] = size
+	m["saturation_pct"] = saturationPct(size, maxSize)
+	return m
+}
+
+// saturationPct returns the percentage of a map of maxSize entries filled by size entries
+func saturationPct(size, maxSize int64) int64 {
+	if maxSize <= 0 {
+		return 0
+	}
+	return size * 100 / maxSize
+}
+
+// GetNamespaceStats returns the number of cached conntrack entries per network namespace.
+// It iterates over the whole conntrack map, so unlike GetStats it is expensive.
+func (e *ebpfConntracker) GetNamespaceStats() map[uint32]int {
+	src := tuplePool.Get().(*netebpf.ConntrackTuple)
+	defer tuplePool.Put(src)
+	dst := tuplePool.Get().(*netebpf.ConntrackTuple)
+	defer tuplePool.Put(dst)
+
+	counts := make(map[uint32]int)
+	it := e.ctMap.Iterate()
+	for it.Next(unsafe.Pointer(src), unsafe.Pointer(dst)) {
+		counts[src.Netns]++
+	}
+	if err := it.Err(); err != nil {
+		log.Warnf("unable to iterate over the conntrack map: %s", err)
+	}
+	return counts
+}
+
 // DumpCachedTable dumps the cached conntrack NAT entries grouped by network namespace
 func (e *ebpfConntracker) DumpCachedTable(ctx context.Context) (map[uint32][]netlink.DebugConntrackEntry, error) {
+	return e.DumpCachedTableFiltered(ctx, DumpFilter{})
+}
+
+// DumpFilter restricts the entries returned by DumpCachedTableFiltered, its zero value matching all of them
+type DumpFilter struct {
+	// NetNS restricts the entries to a network namespace, 0 matching all of them
+	NetNS uint32
+	// SourceIP restricts the entries to a source address, the zero Address matching all of them
+	SourceIP util.Address
+	// Type restricts the entries to a transport protocol, nil matching all of them
+	Type *network.ConnectionType
+}
+
+// matcher returns a function matching the conntrack tuples against the filter
+func (f DumpFilter) matcher() func(*netebpf.ConntrackTuple) bool {
+	checkSource := !f.SourceIP.IsZero()
+	var sourceFamily netebpf.ConnFamily
+	var sourceLow, sourceHigh uint64
+	if checkSource {
+		sourceLow, sourceHigh = util.ToLowHigh(f.SourceIP)
+		sourceFamily = netebpf.IPv6
+		if f.SourceIP.Is4() {
+			sourceFamily = netebpf.IPv4
+		}
+	}
+
+	return func(t *netebpf.ConntrackTuple) bool {
+		if f.NetNS != 0 && t.Netns != f.NetNS {
+			return false
+		}
+		if f.Type != nil && connectionType(t) != *f.Type {
+			return false
+		}
+		if checkSource && (t.Family() != sourceFamily || t.Saddr_l != sourceLow || t.Saddr_h != sourceHigh) {
+			return false
+		}
+		return true
+	}
+}
+
+// connectionType returns the transport protocol of a conntrack tuple, whose values differ
+// between the eBPF ConnType and network.ConnectionType
+func connectionType(t *netebpf.ConntrackTuple) network.ConnectionType {
+	if t.Type() == netebpf.TCP {
+		return network.TCP
+	}
+	return network.UDP
+}
+
+// DumpCachedTableFiltered is like DumpCachedTable, only returning the entries matching the filter
+func (e *ebpfConntracker) DumpCachedTableFiltered(ctx context.Context, filter DumpFilter) (map[uint32][]netlink.DebugConntrackEntry, error) {
 	src := tuplePool.Get().(*netebpf.ConntrackTuple)
 	defer tuplePool.Put(src)
 	dst := tuplePool.Get().(*netebpf.ConntrackTuple)
 	defer tuplePool.Put(dst)
 
 	entries := make(map[uint32][]netlink.DebugConntrackEntry)
+	match := filter.matcher()
 
 	it := e.ctMap.Iterate()
 	for it.Next(unsafe.Pointer(src), unsafe.Pointer(dst)) {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
+		if !match(src) {
+			continue
+		}
 
 		_, ok := entries[src.Netns]
 		if !ok {
@@ -381,7 +692,7 @@ func (e *ebpfConntracker) DumpCachedTable(ctx context.Context) (map[uint32][]net
 		}
 		entries[src.Netns] = append(entries[src.Netns], netlink.DebugConntrackEntry{
 			Family: src.Family().String(),
-			Proto:  network.ConnectionType(src.Type()).String(),
+			Proto:  connectionType(src).String(),
 			Origin: netlink.DebugConntrackTuple{
 				Src: netlink.DebugConntrackAddress{
 					IP:   src.SourceAddress().String(),
@@ -410,6 +721,83 @@ func (e *ebpfConntracker) DumpCachedTable(ctx context.Context) (map[uint32][]net
 	return entries, nil
 }
 
+// AuditAgainstNetlink compares the entries of the eBPF conntrack map against a fresh netlink dump of the
+// kernel conntrack table, and returns the entries which are absent from the kernel table or have a different
+// translation there. It is meant for diagnostic purposes only, as dumping the conntrack table is expensive.
+func (e *ebpfConntracker) AuditAgainstNetlink(ctx context.Context) ([]ConntrackMismatch, error) {
+	conns, err := e.dumpKernelTable(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not dump kernel conntrack table: %w", err)
+	}
+
+	kernel := make(map[netebpf.ConntrackTuple]netebpf.ConntrackTuple, 2*len(conns))
+	for _, c := range conns {
+		src := formatKey(c.NetNS, &c.Origin)
+		dst := formatKey(c.NetNS, &c.Reply)
+		if src != nil && dst != nil {
+			kernel[*src] = *dst
+			kernel[*dst] = *src
+		}
+	}
+
+	var mismatches []ConntrackMismatch
+	src := tuplePool.Get().(*netebpf.ConntrackTuple)
+	defer tuplePool.Put(src)
+	dst := tuplePool.Get().(*netebpf.ConntrackTuple)
+	defer tuplePool.Put(dst)
+
+	it := e.ctMap.Iterate()
+	for it.Next(unsafe.Pointer(src), unsafe.Pointer(dst)) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		key, cached := *src, *dst
+		key.X_pad, cached.X_pad = 0, 0
+		kernelDst, ok := kernel[key]
+		if ok && kernelDst == cached {
+			continue
+		}
+
+		mismatch := ConntrackMismatch{Key: key, Cached: cached}
+		if ok {
+			mismatch.Kernel = &kernelDst
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+	return mismatches, nil
+}
+
+// dumpNetlinkTable dumps the NAT entries of the kernel conntrack table using a new netlink consumer
+func (e *ebpfConntracker) dumpNetlinkTable(ctx context.Context) ([]netlink.Con, error) {
+	consumer := netlink.NewConsumer(e.cfg.ProcRoot, e.cfg.ConntrackRateLimit, true)
+	defer consumer.Stop()
+	decoder := netlink.NewDecoder()
+
+	var conns []netlink.Con
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		events, err := consumer.DumpTable(family)
+		if err != nil {
+			return nil, err
+		}
+
+		err = drainEvents(ctx, events, func(ev netlink.Event) {
+			for _, c := range decoder.DecodeAndReleaseEvent(ev) {
+				if netlink.IsNAT(c) {
+					conns = append(conns, c)
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return conns, nil
+}
+
 func getManager(buf io.ReaderAt, maxStateSize int) (*manager.Manager, error) {
 	mgr := &manager.Manager{
 		Maps: []*manager.Map{
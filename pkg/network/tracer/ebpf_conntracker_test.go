@@ -0,0 +1,506 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux_bpf
+// +build linux_bpf
+
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/DataDog/datadog-agent/pkg/network"
+	"github.com/DataDog/datadog-agent/pkg/network/config"
+	netebpf "github.com/DataDog/datadog-agent/pkg/network/ebpf"
+	"github.com/DataDog/datadog-agent/pkg/network/netlink"
+	"github.com/DataDog/datadog-agent/pkg/process/util"
+	"github.com/cilium/ebpf"
+	mdlnetlink "github.com/mdlayher/netlink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+	"inet.af/netaddr"
+)
+
+func newConTuple(srcIP, dstIP string, srcPort, dstPort uint16) netlink.ConTuple {
+	return netlink.ConTuple{
+		Src:   netaddr.IPPortFrom(netaddr.MustParseIP(srcIP), srcPort),
+		Dst:   netaddr.IPPortFrom(netaddr.MustParseIP(dstIP), dstPort),
+		Proto: unix.IPPROTO_TCP,
+	}
+}
+
+// newTestConntrackMap returns an in-memory hash map with the layout of the conntrack map
+func newTestConntrackMap(t testing.TB, maxEntries int) *ebpf.Map {
+	ctMap, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Hash,
+		KeySize:    uint32(unsafe.Sizeof(netebpf.ConntrackTuple{})),
+		ValueSize:  uint32(unsafe.Sizeof(netebpf.ConntrackTuple{})),
+		MaxEntries: uint32(maxEntries),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { ctMap.Close() })
+	return ctMap
+}
+
+func TestAuditAgainstNetlink(t *testing.T) {
+	ctMap := newTestConntrackMap(t, 10)
+
+	// in sync with the kernel
+	synced := netlink.Con{
+		Origin: newConTuple("10.0.0.1", "2.2.2.2", 50000, 80),
+		Reply:  newConTuple("1.1.1.1", "10.0.0.1", 80, 50000),
+		NetNS:  1,
+	}
+	// translation changed in the kernel
+	stale := netlink.Con{
+		Origin: newConTuple("10.0.0.1", "2.2.2.2", 50001, 80),
+		Reply:  newConTuple("1.1.1.1", "10.0.0.1", 80, 50001),
+		NetNS:  1,
+	}
+	staleKernel := netlink.Con{
+		Origin: stale.Origin,
+		Reply:  newConTuple("3.3.3.3", "10.0.0.1", 80, 50001),
+		NetNS:  1,
+	}
+	// absent from the kernel
+	absent := netlink.Con{
+		Origin: newConTuple("10.0.0.1", "2.2.2.2", 50002, 80),
+		Reply:  newConTuple("1.1.1.1", "10.0.0.1", 80, 50002),
+		NetNS:  1,
+	}
+
+	e := &ebpfConntracker{
+		ctMap: ctMap,
+		dumpKernelTable: func(context.Context) ([]netlink.Con, error) {
+			return []netlink.Con{synced, staleKernel}, nil
+		},
+	}
+	for _, c := range []netlink.Con{synced, stale, absent} {
+		require.NoError(t, e.addTranslation(formatKey(c.NetNS, &c.Origin), formatKey(c.NetNS, &c.Reply)))
+	}
+
+	mismatches, err := e.AuditAgainstNetlink(context.Background())
+	require.NoError(t, err)
+	require.Len(t, mismatches, 2)
+
+	byKey := make(map[netebpf.ConntrackTuple]ConntrackMismatch)
+	for _, m := range mismatches {
+		byKey[m.Key] = m
+	}
+
+	m, ok := byKey[*formatKey(stale.NetNS, &stale.Origin)]
+	require.True(t, ok)
+	assert.Equal(t, *formatKey(stale.NetNS, &stale.Reply), m.Cached)
+	require.NotNil(t, m.Kernel)
+	assert.Equal(t, *formatKey(staleKernel.NetNS, &staleKernel.Reply), *m.Kernel)
+
+	m, ok = byKey[*formatKey(absent.NetNS, &absent.Origin)]
+	require.True(t, ok)
+	assert.Equal(t, *formatKey(absent.NetNS, &absent.Reply), m.Cached)
+	assert.Nil(t, m.Kernel)
+}
+
+func TestGetNamespaceStats(t *testing.T) {
+	e := &ebpfConntracker{ctMap: newTestConntrackMap(t, 10)}
+	assert.Empty(t, e.GetNamespaceStats())
+
+	for i, netns := range []uint32{1, 1, 1, 2} {
+		port := uint16(50000 + i)
+		c := netlink.Con{
+			Origin: newConTuple("10.0.0.1", "2.2.2.2", port, 80),
+			Reply:  newConTuple("1.1.1.1", "10.0.0.1", 80, port),
+			NetNS:  netns,
+		}
+		require.NoError(t, e.addTranslation(formatKey(c.NetNS, &c.Origin), formatKey(c.NetNS, &c.Reply)))
+	}
+
+	assert.Equal(t, map[uint32]int{1: 3, 2: 1}, e.GetNamespaceStats())
+}
+
+func TestStateSizeStats(t *testing.T) {
+	cfg := &config.Config{ConntrackMaxStateSize: 4}
+	e := &ebpfConntracker{cfg: cfg, ctMap: newTestConntrackMap(t, 10)}
+
+	// disabled, the map is not iterated
+	assert.Equal(t, map[string]int64{"max_state_size": 4}, e.stateSizeStats())
+
+	cfg.EnableConntrackStateSizeStats = true
+	addEntries := func(n int) {
+		for i := 0; i < n; i++ {
+			port := uint16(50000 + e.GetNamespaceStats()[1])
+			c := netlink.Con{
+				Origin: newConTuple("10.0.0.1", "2.2.2.2", port, 80),
+				Reply:  newConTuple("1.1.1.1", "10.0.0.1", 80, port),
+				NetNS:  1,
+			}
+			require.NoError(t, e.addTranslation(formatKey(c.NetNS, &c.Origin), formatKey(c.NetNS, &c.Reply)))
+		}
+	}
+
+	for _, tt := range []struct {
+		added         int
+		expectedSize  int64
+		expectedRatio int64
+	}{
+		{added: 0, expectedSize: 0, expectedRatio: 0},
+		{added: 2, expectedSize: 2, expectedRatio: 50},
+		{added: 2, expectedSize: 4, expectedRatio: 100},
+	} {
+		addEntries(tt.added)
+		assert.Equal(t, map[string]int64{
+			"max_state_size": 4,
+			"state_size":     tt.expectedSize,
+			"saturation_pct": tt.expectedRatio,
+		}, e.stateSizeStats())
+	}
+}
+
+func TestSaturationPct(t *testing.T) {
+	assert.Equal(t, int64(0), saturationPct(0, 100))
+	assert.Equal(t, int64(50), saturationPct(50, 100))
+	assert.Equal(t, int64(100), saturationPct(100, 100))
+	assert.Equal(t, int64(0), saturationPct(10, 0))
+}
+
+// newTestTranslatedConntracker returns a conntracker holding a translation for half of n connections,
+// alternately registered in the root namespace and in the namespace of the connection
+func newTestTranslatedConntracker(t testing.TB, n int) (*ebpfConntracker, []network.ConnectionStats) {
+	e := &ebpfConntracker{ctMap: newTestConntrackMap(t, n), rootNS: 1, stats: newEbpfConntrackerStats()}
+	conns := make([]network.ConnectionStats, n)
+	for i := range conns {
+		port := uint16(10000 + i)
+		conns[i] = network.ConnectionStats{
+			Source: util.AddressFromString("10.0.0.1"),
+			Dest:   util.AddressFromString("2.2.2.2"),
+			SPort:  port,
+			DPort:  80,
+			Type:   network.TCP,
+			Family: network.AFINET,
+			NetNS:  2,
+		}
+		if i%2 == 1 {
+			continue
+		}
+
+		netns := uint32(1)
+		if i%4 == 0 {
+			netns = 2
+		}
+		c := netlink.Con{
+			Origin: newConTuple("10.0.0.1", "2.2.2.2", port, 80),
+			Reply:  newConTuple("1.1.1.1", "10.0.0.1", 80, port),
+			NetNS:  netns,
+		}
+		require.NoError(t, e.addTranslation(formatKey(c.NetNS, &c.Origin), formatKey(c.NetNS, &c.Reply)))
+	}
+	return e, conns
+}
+
+func TestGetTranslationsForConns(t *testing.T) {
+	e, conns := newTestTranslatedConntracker(t, 8)
+
+	translations := e.GetTranslationsForConns(conns)
+	require.Len(t, translations, len(conns))
+	for i, c := range conns {
+		assert.Equal(t, e.GetTranslationForConn(c), translations[i], fmt.Sprintf("connection %d", i))
+		if i%2 == 0 {
+			require.NotNil(t, translations[i])
+			assert.Equal(t, c.SPort, translations[i].ReplDstPort)
+		} else {
+			assert.Nil(t, translations[i])
+		}
+	}
+	assert.Equal(t, int64(8), e.stats.gets.Load())
+}
+
+func BenchmarkGetTranslations(b *testing.B) {
+	e, conns := newTestTranslatedConntracker(b, 1000)
+
+	b.Run("scalar", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, c := range conns {
+				e.GetTranslationForConn(c)
+			}
+		}
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			e.GetTranslationsForConns(conns)
+		}
+	})
+}
+
+func TestConntrackerFamilyFiltering(t *testing.T) {
+	e := &ebpfConntracker{ctMap: newTestConntrackMap(t, 10), rootNS: 1, stats: newEbpfConntrackerStats()}
+
+	v4 := netlink.Con{
+		Origin: newConTuple("10.0.0.1", "2.2.2.2", 50000, 80),
+		Reply:  newConTuple("1.1.1.1", "10.0.0.1", 80, 50000),
+		NetNS:  1,
+	}
+	v6 := netlink.Con{
+		Origin: newConTuple("fd00::1", "fd00::2", 50000, 80),
+		Reply:  newConTuple("fd00::3", "fd00::1", 80, 50000),
+		NetNS:  1,
+	}
+	// the entries are in the map, a lookup for a disabled family would find them
+	for _, c := range []netlink.Con{v4, v6} {
+		require.NoError(t, e.addTranslation(formatKey(c.NetNS, &c.Origin), formatKey(c.NetNS, &c.Reply)))
+	}
+
+	conns := []network.ConnectionStats{
+		{
+			Source: util.AddressFromString("10.0.0.1"),
+			Dest:   util.AddressFromString("2.2.2.2"),
+			SPort:  50000,
+			DPort:  80,
+			Type:   network.TCP,
+			Family: network.AFINET,
+			NetNS:  1,
+		},
+		{
+			Source: util.AddressFromString("fd00::1"),
+			Dest:   util.AddressFromString("fd00::2"),
+			SPort:  50000,
+			DPort:  80,
+			Type:   network.TCP,
+			Family: network.AFINET6,
+			NetNS:  1,
+		},
+	}
+
+	e.cfg = &config.Config{CollectIPv6Conns: true}
+	assert.NotNil(t, e.GetTranslationForConn(conns[0]))
+	assert.NotNil(t, e.GetTranslationForConn(conns[1]))
+
+	t.Run("ipv6 disabled", func(t *testing.T) {
+		e.cfg = &config.Config{CollectIPv6Conns: false}
+		assert.NotNil(t, e.GetTranslationForConn(conns[0]))
+		assert.Nil(t, e.GetTranslationForConn(conns[1]))
+
+		translations := e.GetTranslationsForConns(conns)
+		assert.NotNil(t, translations[0])
+		assert.Nil(t, translations[1])
+	})
+
+	t.Run("ipv4 disabled", func(t *testing.T) {
+		e.cfg = &config.Config{CollectIPv6Conns: true, ConntrackDisableIPv4: true}
+		assert.Nil(t, e.GetTranslationForConn(conns[0]))
+		assert.NotNil(t, e.GetTranslationForConn(conns[1]))
+
+		translations := e.GetTranslationsForConns(conns)
+		assert.Nil(t, translations[0])
+		assert.NotNil(t, translations[1])
+	})
+}
+
+func TestEBPFConntrackerLatencyStats(t *testing.T) {
+	e, conns := newTestTranslatedConntracker(t, 4)
+
+	stats := make(map[string]int64)
+	e.stats.getLatencies.AddPercentiles(stats, "nanoseconds_per_get")
+	assert.NotContains(t, stats, "nanoseconds_per_get_p50")
+	assert.Equal(t, int64(0), stats["nanoseconds_per_get_gt_10ms"])
+
+	e.GetTranslationForConn(conns[0])
+	e.stats.getLatencies.AddPercentiles(stats, "nanoseconds_per_get")
+	assert.Contains(t, stats, "nanoseconds_per_get_p50")
+	assert.Contains(t, stats, "nanoseconds_per_get_p95")
+	assert.Contains(t, stats, "nanoseconds_per_get_p99")
+}
+
+func newTestConntrackEvent(t *testing.T, n int) netlink.Event {
+	conns := make([]netlink.Con, n)
+	for i := range conns {
+		port := uint16(50000 + i)
+		conns[i] = netlink.Con{
+			Origin: newConTuple("10.0.0.1", "2.2.2.2", port, 80),
+			Reply:  newConTuple("2.2.2.2", "10.0.0.1", 80, port),
+		}
+	}
+	return newTestConntrackEventFromConns(t, conns...)
+}
+
+func newTestConntrackEventFromConns(t *testing.T, conns ...netlink.Con) netlink.Event {
+	msgs := make([]mdlnetlink.Message, len(conns))
+	for i := range conns {
+		data, err := netlink.EncodeConn(&conns[i])
+		require.NoError(t, err)
+		msgs[i] = mdlnetlink.Message{Data: data}
+	}
+	return netlink.NewEvent(msgs, 1)
+}
+
+func TestLoadInitialStateProgress(t *testing.T) {
+	prevInterval := initialDumpProgressInterval
+	initialDumpProgressInterval = 3
+	t.Cleanup(func() { initialDumpProgressInterval = prevInterval })
+
+	e := &ebpfConntracker{decoder: netlink.NewDecoder()}
+
+	events := make(chan netlink.Event, 3)
+	events <- newTestConntrackEvent(t, 2)
+	events <- newTestConntrackEvent(t, 2)
+	events <- newTestConntrackEvent(t, 1)
+	close(events)
+
+	var reports []int
+	processed, err := e.loadInitialState(context.Background(), events, 10, func(processed int) {
+		reports = append(reports, processed)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 15, processed)
+	assert.Equal(t, []int{14, 15}, reports)
+
+	t.Run("nil progress", func(t *testing.T) {
+		events := make(chan netlink.Event, 1)
+		events <- newTestConntrackEvent(t, 4)
+		close(events)
+
+		processed, err := e.loadInitialState(context.Background(), events, 0, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 4, processed)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// the events channel is never closed
+		_, err := e.loadInitialState(ctx, make(chan netlink.Event), 0, func(int) {
+			t.Error("progress reported after cancellation")
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestCountMapEntries(t *testing.T) {
+	natV4 := netlink.Con{
+		Origin: newConTuple("10.0.0.1", "2.2.2.2", 50000, 80),
+		Reply:  newConTuple("1.1.1.1", "10.0.0.1", 80, 50000),
+	}
+	natV6 := netlink.Con{
+		Origin: newConTuple("fd00::1", "fd00::2", 50000, 80),
+		Reply:  newConTuple("fd00::3", "fd00::1", 80, 50000),
+	}
+	dumpTable := func(family uint8) (<-chan netlink.Event, error) {
+		events := make(chan netlink.Event, 2)
+		if family == unix.AF_INET {
+			events <- newTestConntrackEventFromConns(t, natV4, natV4)
+			// entries without NAT are not added to the map
+			events <- newTestConntrackEvent(t, 3)
+		} else {
+			events <- newTestConntrackEventFromConns(t, natV6)
+		}
+		close(events)
+		return events, nil
+	}
+
+	cfg := &config.Config{CollectIPv6Conns: true}
+	v4, v6, err := countMapEntries(context.Background(), cfg, dumpTable)
+	require.NoError(t, err)
+	assert.Equal(t, 4, v4)
+	assert.Equal(t, 2, v6)
+
+	t.Run("family disabled", func(t *testing.T) {
+		v4, v6, err := countMapEntries(context.Background(), &config.Config{CollectIPv6Conns: false}, dumpTable)
+		require.NoError(t, err)
+		assert.Equal(t, 4, v4)
+		assert.Equal(t, 0, v6)
+	})
+
+	t.Run("dump error", func(t *testing.T) {
+		dumpErr := fmt.Errorf("dump failed")
+		_, _, err := countMapEntries(context.Background(), cfg, func(family uint8) (<-chan netlink.Event, error) {
+			if family == unix.AF_INET6 {
+				return nil, dumpErr
+			}
+			return dumpTable(family)
+		})
+		assert.ErrorIs(t, err, dumpErr)
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		// the events channel is never closed
+		_, _, err := countMapEntries(ctx, cfg, func(family uint8) (<-chan netlink.Event, error) {
+			return make(chan netlink.Event), nil
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestDumpCachedTableFiltered(t *testing.T) {
+	e := &ebpfConntracker{ctMap: newTestConntrackMap(t, 10)}
+
+	conns := []netlink.Con{
+		{
+			Origin: newConTuple("10.0.0.1", "2.2.2.2", 50000, 80),
+			Reply:  newConTuple("1.1.1.1", "10.0.0.1", 80, 50000),
+			NetNS:  1,
+		},
+		{
+			Origin: newConTuple("10.0.0.2", "2.2.2.2", 50001, 80),
+			Reply:  newConTuple("1.1.1.1", "10.0.0.2", 80, 50001),
+			NetNS:  1,
+		},
+		{
+			Origin: newConTuple("10.0.0.1", "2.2.2.2", 50002, 80),
+			Reply:  newConTuple("1.1.1.1", "10.0.0.1", 80, 50002),
+			NetNS:  2,
+		},
+	}
+	for _, c := range conns {
+		require.NoError(t, e.addTranslation(formatKey(c.NetNS, &c.Origin), formatKey(c.NetNS, &c.Reply)))
+	}
+
+	countEntries := func(entries map[uint32][]netlink.DebugConntrackEntry) map[uint32]int {
+		counts := make(map[uint32]int)
+		for netns, nsEntries := range entries {
+			counts[netns] = len(nsEntries)
+		}
+		return counts
+	}
+	udp := network.UDP
+	tcp := network.TCP
+
+	tests := []struct {
+		name     string
+		filter   DumpFilter
+		expected map[uint32]int
+	}{
+		{name: "no filter", filter: DumpFilter{}, expected: map[uint32]int{1: 2, 2: 1}},
+		{name: "namespace", filter: DumpFilter{NetNS: 2}, expected: map[uint32]int{2: 1}},
+		{name: "source ip", filter: DumpFilter{SourceIP: util.AddressFromString("10.0.0.1")}, expected: map[uint32]int{1: 1, 2: 1}},
+		{name: "namespace and source ip", filter: DumpFilter{NetNS: 1, SourceIP: util.AddressFromString("10.0.0.2")}, expected: map[uint32]int{1: 1}},
+		{name: "ipv6 source ip", filter: DumpFilter{SourceIP: util.AddressFromString("::ffff:10.0.0.1")}, expected: map[uint32]int{}},
+		{name: "tcp", filter: DumpFilter{Type: &tcp}, expected: map[uint32]int{1: 2, 2: 1}},
+		{name: "udp", filter: DumpFilter{Type: &udp}, expected: map[uint32]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := e.DumpCachedTableFiltered(context.Background(), tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, countEntries(entries))
+		})
+	}
+
+	entries, err := e.DumpCachedTableFiltered(context.Background(), DumpFilter{NetNS: 1, SourceIP: util.AddressFromString("10.0.0.2")})
+	require.NoError(t, err)
+	require.Len(t, entries[1], 1)
+	assert.Equal(t, "TCP", entries[1][0].Proto)
+	assert.Equal(t, "10.0.0.2", entries[1][0].Origin.Src.IP)
+	assert.Equal(t, uint16(50001), entries[1][0].Origin.Src.Port)
+}
@@ -162,6 +162,12 @@ func (t *Tracer) getConnTelemetry() map[network.ConnTelemetryType]int64 {
 		if flowStats, ok := allstats["driver_flow_handle_stats"].(map[string]int64); ok {
 			if fme, ok := flowStats["num_flows_missed_max_exceeded"]; ok {
 				tm[network.NPMDriverFlowsMissedMaxExceeded] = fme
+				// the connections check gets the flows dropped since its previous call, like the collisions,
+				// so that it can alert on the driver pressure
+				tm[network.MonotonicDriverFlowsDropped] = fme
+			}
+			if fc, ok := flowStats["num_flow_collisions"]; ok {
+				tm[network.MonotonicDriverFlowCollisions] = fc
 			}
 		}
 	}
@@ -33,7 +33,7 @@ func (r *RemoteSysProbeUtil) GetCheck(module sysconfig.ModuleName) (interface{},
 	if err != nil {
 		return nil, err
 	} else if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("conn request failed: socket %s, url %s, status code: %d", r.path, fmt.Sprintf(checksURL, module), resp.StatusCode)
+		return nil, fmt.Errorf("conn request failed: socket %s, url %s, status code: %d", r.SocketPath(), fmt.Sprintf(checksURL, module), resp.StatusCode)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
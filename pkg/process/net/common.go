@@ -10,12 +10,19 @@ package net
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,8 +33,19 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/proto/pbgo"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 	"github.com/DataDog/datadog-agent/pkg/util/retry"
+	"go.uber.org/atomic"
 )
 
+// GetConnectionsStats describes a retrieval of connections from the system probe
+type GetConnectionsStats struct {
+	// BytesRead is the size of the response body, before decompression
+	BytesRead int
+	// DecodeDuration is the time spent decompressing and decoding the response body
+	DecodeDuration time.Duration
+	// Connections is the number of connections decoded
+	Connections int
+}
+
 // Conn is a wrapper over some net.Listener
 type Conn interface {
 	// GetListener returns the underlying net.Listener
@@ -39,27 +57,85 @@ type Conn interface {
 
 const (
 	contentTypeProtobuf = "application/protobuf"
+	contentEncodingGzip = "gzip"
+
+	// continuationTokenParam is the query parameter used to request a given page of connections
+	continuationTokenParam = "continuation_token"
+	// continuationTokenHeader is the response header holding the token of the next page of connections
+	continuationTokenHeader = "X-Continuation-Token"
+	// limitParam and offsetParam are the query parameters used to request a window of connections
+	limitParam  = "limit"
+	offsetParam = "offset"
+	// pageOffsetHeader is the response header echoing the offset applied by a system probe supporting pagination
+	pageOffsetHeader = "X-Page-Offset"
+
+	// pingTimeout is the timeout of the single request sent by Ping
+	pingTimeout = 2 * time.Second
+
+	// tcpScheme and httpsScheme prefix the paths of a system probe listening on a TCP port, in plain text or with TLS
+	tcpScheme   = "tcp://"
+	httpsScheme = "https://"
 )
 
 var (
-	globalUtil       *RemoteSysProbeUtil
-	globalUtilOnce   sync.Once
-	globalSocketPath string
+	globalUtil         *RemoteSysProbeUtil
+	globalUtilLock     sync.RWMutex
+	globalSocketPaths  []string
+	globalClientConfig = DefaultClientConfig()
+
+	lastGetConnectionsStats atomic.Value
 )
 
+// ClientConfig holds the timeouts of the HTTP client used to query the system probe
+type ClientConfig struct {
+	// Timeout is the overall timeout of a request, including reading the response body
+	Timeout time.Duration
+	// ResponseHeaderTimeout is the time to wait for the response headers once the request is written
+	ResponseHeaderTimeout time.Duration
+	// TLSConfig is used to connect to a system probe listening on an https:// address. The system roots are
+	// trusted if nil.
+	TLSConfig *tls.Config
+}
+
+// DefaultClientConfig returns the default configuration of the system probe HTTP client
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		Timeout:               10 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	}
+}
+
 // RemoteSysProbeUtil wraps interactions with a remote system probe service
 type RemoteSysProbeUtil struct {
 	// Retrier used to setup system probe
 	initRetry retry.Retrier
 
-	path       string
+	// paths are the candidate paths the system probe may be listening on, by order of preference
+	paths []string
+	// path is the path currently used to connect to the system probe
+	path       *atomic.String
+	tlsConfig  *tls.Config
 	httpClient http.Client
 }
 
-// SetSystemProbePath sets where the System probe is listening for connections
+// SetSystemProbePath sets where the System probe is listening for connections, either a socket path or a
+// tcp://host:port or https://host:port address for a System probe exposed over TCP.
 // This needs to be called before GetRemoteSystemProbeUtil.
 func SetSystemProbePath(path string) {
-	globalSocketPath = path
+	SetSystemProbePaths(path)
+}
+
+// SetSystemProbePaths sets the ordered list of candidate paths where the System probe may be listening
+// for connections, the first one responding being used. This is useful when the path changes during upgrades.
+// This needs to be called before GetRemoteSystemProbeUtil.
+func SetSystemProbePaths(paths ...string) {
+	globalSocketPaths = paths
+}
+
+// SetSystemProbeClientConfig sets the configuration of the HTTP client used to query the System probe.
+// This needs to be called before GetRemoteSystemProbeUtil.
+func SetSystemProbeClientConfig(cfg ClientConfig) {
+	globalClientConfig = cfg
 }
 
 // GetRemoteSystemProbeUtil returns a ready to use RemoteSysProbeUtil. It is backed by a shared singleton.
@@ -69,7 +145,8 @@ func GetRemoteSystemProbeUtil() (*RemoteSysProbeUtil, error) {
 		return nil, fmt.Errorf("error setting up remote system probe util, %v", err)
 	}
 
-	globalUtilOnce.Do(func() {
+	globalUtilLock.Lock()
+	if globalUtil == nil {
 		globalUtil = newSystemProbe()
 		globalUtil.initRetry.SetupRetrier(&retry.Config{ //nolint:errcheck
 			Name:          "system-probe-util",
@@ -79,14 +156,45 @@ func GetRemoteSystemProbeUtil() (*RemoteSysProbeUtil, error) {
 			RetryCount: 10,
 			RetryDelay: 30 * time.Second,
 		})
-	})
+	}
+	util := globalUtil
+	globalUtilLock.Unlock()
 
-	if err := globalUtil.initRetry.TriggerRetry(); err != nil {
+	if err := util.initRetry.TriggerRetry(); err != nil {
 		log.Debugf("system probe init error: %s", err)
 		return nil, err
 	}
 
-	return globalUtil, nil
+	return util, nil
+}
+
+// ResetGlobalSystemProbeUtil drops the shared RemoteSysProbeUtil, so that the next call to
+// GetRemoteSystemProbeUtil builds a new one using the current socket paths and client configuration.
+func ResetGlobalSystemProbeUtil() {
+	globalUtilLock.Lock()
+	defer globalUtilLock.Unlock()
+
+	if globalUtil != nil {
+		globalUtil.httpClient.CloseIdleConnections()
+		globalUtil = nil
+	}
+}
+
+// GetRemoteSystemProbeUtilStatus returns the initialization status of the shared RemoteSysProbeUtil,
+// without triggering a new initialization attempt. It is retry.NeedSetup until GetRemoteSystemProbeUtil is called.
+func GetRemoteSystemProbeUtilStatus() retry.Status {
+	globalUtilLock.RLock()
+	defer globalUtilLock.RUnlock()
+
+	if globalUtil == nil {
+		return retry.NeedSetup
+	}
+	return globalUtil.Status()
+}
+
+// Status returns the initialization status of the connection to the system probe
+func (r *RemoteSysProbeUtil) Status() retry.Status {
+	return r.initRetry.RetryStatus()
 }
 
 // GetProcStats returns a set of process stats by querying system-probe
@@ -114,7 +222,7 @@ func (r *RemoteSysProbeUtil) GetProcStats(pids []int32) (*model.ProcStatsWithPer
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("proc_stats request failed: Probe Path %s, url: %s, status code: %d", r.path, procStatsURL, resp.StatusCode)
+		return nil, fmt.Errorf("proc_stats request failed: Probe Path %s, url: %s, status code: %d", r.SocketPath(), procStatsURL, resp.StatusCode)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -133,35 +241,153 @@ func (r *RemoteSysProbeUtil) GetProcStats(pids []int32) (*model.ProcStatsWithPer
 
 // GetConnections returns a set of active network connections, retrieved from the system probe service
 func (r *RemoteSysProbeUtil) GetConnections(clientID string) (*model.Connections, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s?client_id=%s", connectionsURL, clientID), nil)
+	return r.GetConnectionsWithContext(context.Background(), clientID)
+}
+
+// GetConnectionsWithContext returns a set of active network connections, retrieved from the system probe service.
+// The request is aborted when ctx is cancelled.
+func (r *RemoteSysProbeUtil) GetConnectionsWithContext(ctx context.Context, clientID string) (*model.Connections, error) {
+	conns, _, err := r.getConnections(ctx, clientID, nil)
+	return conns, err
+}
+
+// GetConnectionsPaged returns a page of active network connections, retrieved from the system probe service.
+// An empty token requests the first page. The returned continuation token must be passed to the next call
+// to retrieve the following page, and is empty once all pages have been read.
+func (r *RemoteSysProbeUtil) GetConnectionsPaged(clientID, token string) (*model.Connections, string, error) {
+	var params url.Values
+	if token != "" {
+		params = url.Values{continuationTokenParam: []string{token}}
+	}
+
+	conns, header, err := r.getConnections(context.Background(), clientID, params)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	return conns, header.Get(continuationTokenHeader), nil
+}
+
+// GetConnectionsInPages retrieves the active network connections from the system probe service by pages of
+// at most pageSize connections, passing each page to fn. It stops at the first error returned by fn.
+// Following pages are only requested if the system probe acknowledges the pagination by echoing the offset
+// in the response headers: one ignoring the limit and offset parameters returns all the connections at once,
+// which are then passed to fn in a single call.
+func (r *RemoteSysProbeUtil) GetConnectionsInPages(clientID string, pageSize int, fn func(*model.Connections) error) error {
+	if pageSize <= 0 {
+		return fmt.Errorf("invalid page size %d", pageSize)
+	}
+
+	for offset := 0; ; offset += pageSize {
+		params := url.Values{
+			limitParam:  []string{strconv.Itoa(pageSize)},
+			offsetParam: []string{strconv.Itoa(offset)},
+		}
+		conns, header, err := r.getConnections(context.Background(), clientID, params)
+		if err != nil {
+			return err
+		}
+
+		paginated := header.Get(pageOffsetHeader) == strconv.Itoa(offset)
+		if offset > 0 && !paginated {
+			return fmt.Errorf("system probe stopped paginating connections at offset %d", offset)
+		}
+
+		// an empty page past the first one means the previous page was the last one
+		if offset > 0 && len(conns.Conns) == 0 {
+			return nil
+		}
+		if err := fn(conns); err != nil {
+			return err
+		}
+		// a short page is the last one
+		if !paginated || len(conns.Conns) < pageSize {
+			return nil
+		}
+	}
+}
+
+func (r *RemoteSysProbeUtil) getConnections(ctx context.Context, clientID string, params url.Values) (*model.Connections, http.Header, error) {
+	reqURL := fmt.Sprintf("%s?client_id=%s", connectionsURL, clientID)
+	if len(params) > 0 {
+		reqURL += "&" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	req.Header.Set("Accept", contentTypeProtobuf)
+	req.Header.Set("Accept-Encoding", contentEncodingGzip)
 	resp, err := r.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("conn request failed: Probe Path %s, url: %s, status code: %d", r.path, connectionsURL, resp.StatusCode)
+		return nil, nil, fmt.Errorf("conn request failed: Probe Path %s, url: %s, status code: %d", r.SocketPath(), connectionsURL, resp.StatusCode)
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := readBody(resp)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	contentType := resp.Header.Get("Content-type")
-	conns, err := netEncoding.GetUnmarshaler(contentType).Unmarshal(body)
+	start := time.Now()
+	conns, err := decodeConnections(resp.Header, body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	lastGetConnectionsStats.Store(GetConnectionsStats{
+		BytesRead:      len(body),
+		DecodeDuration: time.Since(start),
+		Connections:    len(conns.Conns),
+	})
+
+	return conns, resp.Header, nil
+}
+
+// LastGetConnectionsStats returns the stats of the last successful retrieval of connections from the system probe,
+// the zero value if there was none
+func LastGetConnectionsStats() GetConnectionsStats {
+	stats, _ := lastGetConnectionsStats.Load().(GetConnectionsStats)
+	return stats
+}
+
+// decodeConnections decodes the body of a connections response, decompressing it if it is gzip encoded
+func decodeConnections(header http.Header, body []byte) (*model.Connections, error) {
+	if header.Get("Content-Encoding") == contentEncodingGzip {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress gzip response: %w", err)
+		}
+		defer reader.Close()
+
+		if body, err = ioutil.ReadAll(reader); err != nil {
+			return nil, fmt.Errorf("could not decompress gzip response: %w", err)
+		}
 	}
 
-	return conns, nil
+	contentType := header.Get("Content-type")
+	return netEncoding.GetUnmarshaler(contentType).Unmarshal(body)
+}
+
+// readBody reads the body of a response. It returns an error if the body doesn't match the advertised
+// Content-Length, as a truncated response could still be decoded.
+func readBody(resp *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) && resp.ContentLength >= 0 {
+			return nil, fmt.Errorf("truncated response: read %d bytes out of the %d advertised by Content-Length: %w", len(body), resp.ContentLength, err)
+		}
+		return nil, err
+	}
+	if resp.ContentLength >= 0 && int64(len(body)) != resp.ContentLength {
+		return nil, fmt.Errorf("invalid response: read %d bytes instead of the %d advertised by Content-Length", len(body), resp.ContentLength)
+	}
+	return body, nil
 }
 
 // GetStats returns the expvar stats of the system probe
@@ -174,12 +400,15 @@ func (r *RemoteSysProbeUtil) GetStats() (map[string]interface{}, error) {
 	resp, err := r.httpClient.Do(req)
 	if err != nil {
 		return nil, err
-	} else if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("conn request failed: Path %s, url: %s, status code: %d", r.path, statsURL, resp.StatusCode)
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
 	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("stats request failed: Probe Path %s, url: %s, status code: %d", r.SocketPath(), statsURL, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -204,36 +433,146 @@ func (r *RemoteSysProbeUtil) Register(clientID string) error {
 	if err != nil {
 		return err
 	} else if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("conn request failed: Path %s, url: %s, status code: %d", r.path, statsURL, resp.StatusCode)
+		return fmt.Errorf("conn request failed: Path %s, url: %s, status code: %d", r.SocketPath(), statsURL, resp.StatusCode)
 	}
 
 	return nil
 }
 
+// SocketPath returns the path currently used to connect to the system probe
+func (r *RemoteSysProbeUtil) SocketPath() string {
+	return r.path.Load()
+}
+
 func newSystemProbe() *RemoteSysProbeUtil {
-	return &RemoteSysProbeUtil{
-		path: globalSocketPath,
-		httpClient: http.Client{
-			Timeout: 10 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:    2,
-				IdleConnTimeout: 30 * time.Second,
-				DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-					return net.Dial(netType, globalSocketPath)
-				},
-				TLSHandshakeTimeout:   1 * time.Second,
-				ResponseHeaderTimeout: 5 * time.Second,
-				ExpectContinueTimeout: 50 * time.Millisecond,
+	paths := append([]string(nil), globalSocketPaths...)
+	var path string
+	if len(paths) > 0 {
+		path = paths[0]
+	}
+
+	r := &RemoteSysProbeUtil{
+		paths:     paths,
+		path:      atomic.NewString(path),
+		tlsConfig: globalClientConfig.TLSConfig,
+	}
+	r.httpClient = http.Client{
+		Timeout: globalClientConfig.Timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:    2,
+			IdleConnTimeout: 30 * time.Second,
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return r.dial(ctx)
 			},
+			TLSHandshakeTimeout:   1 * time.Second,
+			ResponseHeaderTimeout: globalClientConfig.ResponseHeaderTimeout,
+			ExpectContinueTimeout: 50 * time.Millisecond,
 		},
 	}
+	return r
+}
+
+// systemProbeAddress describes how to connect to the system probe
+type systemProbeAddress struct {
+	network string
+	address string
+	tls     bool
+}
+
+// parseSystemProbeAddress parses a system probe path, which is a socket path unless it starts with tcp:// or https://
+func parseSystemProbeAddress(path string) (systemProbeAddress, error) {
+	var useTLS bool
+	switch {
+	case strings.HasPrefix(path, tcpScheme):
+	case strings.HasPrefix(path, httpsScheme):
+		useTLS = true
+	default:
+		return systemProbeAddress{network: netType, address: path}, nil
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return systemProbeAddress{}, fmt.Errorf("invalid system probe address `%s`: %w", path, err)
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return systemProbeAddress{}, fmt.Errorf("invalid system probe address `%s`: %w", path, err)
+	}
+	return systemProbeAddress{network: "tcp", address: u.Host, tls: useTLS}, nil
+}
+
+// dial connects to the system probe on the current path
+func (r *RemoteSysProbeUtil) dial(ctx context.Context) (net.Conn, error) {
+	addr, err := parseSystemProbeAddress(r.SocketPath())
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, addr.network, addr.address)
+	if err != nil || !addr.tls {
+		return conn, err
+	}
+
+	tlsConfig := &tls.Config{}
+	if r.tlsConfig != nil {
+		tlsConfig = r.tlsConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(addr.address)
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
 }
 
+// init looks for the first candidate path the system probe responds on, and keeps using it
 func (r *RemoteSysProbeUtil) init() error {
-	if resp, err := r.httpClient.Get(statsURL); err != nil {
+	if len(r.paths) == 0 {
+		return fmt.Errorf("remote tracer has no path defined")
+	}
+
+	var err error
+	for _, path := range r.paths {
+		if path != r.SocketPath() {
+			r.path.Store(path)
+			r.httpClient.CloseIdleConnections()
+		}
+		if err = r.checkStatus(context.Background()); err == nil {
+			return nil
+		}
+		log.Debugf("system probe not available on %s: %s", path, err)
+	}
+	return err
+}
+
+// Ping checks that the system probe responds on the current path, with a single short request. Unlike the
+// initialization done by GetRemoteSystemProbeUtil, it is never retried and doesn't change the initialization
+// status, which makes it suitable for readiness probes.
+func (r *RemoteSysProbeUtil) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	return r.checkStatus(ctx)
+}
+
+func (r *RemoteSysProbeUtil) checkStatus(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", statsURL, nil)
+	if err != nil {
 		return err
-	} else if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("remote tracer status check failed: socket %s, url: %s, status code: %d", r.path, statsURL, resp.StatusCode)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("remote tracer status check failed: socket %s, url: %s, status code: %d", r.SocketPath(), statsURL, resp.StatusCode)
 	}
 	return nil
 }
@@ -11,6 +11,7 @@ package net
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	sysconfig "github.com/DataDog/datadog-agent/cmd/system-probe/config"
 )
@@ -24,14 +25,21 @@ const (
 )
 
 // CheckPath is used in conjunction with calling the stats endpoint, since we are calling this
-// From the main agent and want to ensure the socket exists
+// From the main agent and want to ensure at least one of the candidate sockets exists
 func CheckPath() error {
-	if globalSocketPath == "" {
+	if len(globalSocketPaths) == 0 {
 		return fmt.Errorf("remote tracer has no path defined")
 	}
 
-	if _, err := os.Stat(globalSocketPath); err != nil {
-		return fmt.Errorf("socket path does not exist: %v", err)
+	var err error
+	for _, path := range globalSocketPaths {
+		// system probes exposed over TCP can only be checked by connecting to them
+		if strings.HasPrefix(path, tcpScheme) || strings.HasPrefix(path, httpsScheme) {
+			return nil
+		}
+		if _, err = os.Stat(path); err == nil {
+			return nil
+		}
 	}
-	return nil
+	return fmt.Errorf("socket path does not exist: %v", err)
 }
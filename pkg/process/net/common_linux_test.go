@@ -0,0 +1,624 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package net
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	model "github.com/DataDog/agent-payload/v5/process"
+	"github.com/DataDog/datadog-agent/pkg/util/retry"
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// startTestServer starts an HTTP server listening on a unix socket and returns the socket path
+func startTestServer(t *testing.T, handler http.Handler) string {
+	socketPath := filepath.Join(t.TempDir(), "sysprobe.sock")
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(l) //nolint:errcheck
+	t.Cleanup(func() { srv.Close() })
+
+	return socketPath
+}
+
+// newTestSystemProbe returns a RemoteSysProbeUtil connected to the given socket path
+func newTestSystemProbe(t *testing.T, socketPath string) *RemoteSysProbeUtil {
+	prevPaths := globalSocketPaths
+	t.Cleanup(func() { SetSystemProbePaths(prevPaths...) })
+
+	SetSystemProbePath(socketPath)
+	return newSystemProbe()
+}
+
+func writeTestConnections(t *testing.T, w http.ResponseWriter, conns *model.Connections) {
+	buf, err := proto.Marshal(conns)
+	require.NoError(t, err)
+	w.Header().Set("Content-type", contentTypeProtobuf)
+	_, _ = w.Write(buf)
+}
+
+func TestGetConnectionsPaged(t *testing.T) {
+	pages := map[string]*model.Connections{
+		"":      {Conns: []*model.Connection{{Pid: 1}, {Pid: 2}}},
+		"page2": {Conns: []*model.Connection{{Pid: 3}}},
+	}
+	nextTokens := map[string]string{
+		"":      "page2",
+		"page2": "",
+	}
+
+	socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "test-client", req.URL.Query().Get("client_id"))
+
+		token := req.URL.Query().Get(continuationTokenParam)
+		conns, ok := pages[token]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if next := nextTokens[token]; next != "" {
+			w.Header().Set(continuationTokenHeader, next)
+		}
+		writeTestConnections(t, w, conns)
+	}))
+	r := newTestSystemProbe(t, socketPath)
+
+	var pids []int32
+	token := ""
+	for i := 0; ; i++ {
+		require.Less(t, i, len(pages), "too many pages returned")
+
+		conns, next, err := r.GetConnectionsPaged("test-client", token)
+		require.NoError(t, err)
+		for _, c := range conns.Conns {
+			pids = append(pids, c.Pid)
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.Equal(t, []int32{1, 2, 3}, pids)
+
+	_, _, err := r.GetConnectionsPaged("test-client", "invalid")
+	assert.Error(t, err)
+}
+
+func TestGetStats(t *testing.T) {
+	var status int
+	socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/debug/stats", req.URL.Path)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"tracer": {"conn_valid_skipped": 2}}`))
+	}))
+	r := newTestSystemProbe(t, socketPath)
+
+	status = http.StatusOK
+	stats, err := r.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"tracer": map[string]interface{}{"conn_valid_skipped": float64(2)}}, stats)
+
+	status = http.StatusInternalServerError
+	_, err = r.GetStats()
+	assert.ErrorContains(t, err, "status code: 500")
+}
+
+func TestGetConnectionsWithCancelledContext(t *testing.T) {
+	unblock := make(chan struct{})
+	socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-unblock:
+		case <-req.Context().Done():
+		}
+	}))
+	defer close(unblock)
+	r := newTestSystemProbe(t, socketPath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := r.GetConnectionsWithContext(ctx, "test-client")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	_, err = r.GetConnectionsWithContext(ctx, "test-client")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSystemProbePathFailover(t *testing.T) {
+	staleSocketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/debug/stats" {
+			_, _ = w.Write([]byte("{}"))
+			return
+		}
+		writeTestConnections(t, w, &model.Connections{Conns: []*model.Connection{{Pid: 1}}})
+	}))
+	missingSocketPath := filepath.Join(t.TempDir(), "missing.sock")
+
+	prevPaths := globalSocketPaths
+	t.Cleanup(func() { SetSystemProbePaths(prevPaths...) })
+	SetSystemProbePaths(missingSocketPath, staleSocketPath, socketPath)
+	require.NoError(t, CheckPath())
+
+	r := newSystemProbe()
+	assert.Equal(t, missingSocketPath, r.SocketPath())
+	require.NoError(t, r.init())
+	assert.Equal(t, socketPath, r.SocketPath())
+
+	conns, err := r.GetConnections("test-client")
+	require.NoError(t, err)
+	assert.Len(t, conns.Conns, 1)
+
+	SetSystemProbePaths(missingSocketPath, staleSocketPath)
+	assert.Error(t, newSystemProbe().init())
+}
+
+func TestGetConnectionsContentEncoding(t *testing.T) {
+	conns := &model.Connections{Conns: []*model.Connection{{Pid: 1}, {Pid: 2}}}
+	buf, err := proto.Marshal(conns)
+	require.NoError(t, err)
+
+	t.Run("gzip", func(t *testing.T) {
+		socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "gzip", req.Header.Get("Accept-Encoding"))
+			w.Header().Set("Content-type", contentTypeProtobuf)
+			w.Header().Set("Content-Encoding", "gzip")
+			gw := gzip.NewWriter(w)
+			_, _ = gw.Write(buf)
+			_ = gw.Close()
+		}))
+		r := newTestSystemProbe(t, socketPath)
+
+		res, err := r.GetConnections("test-client")
+		require.NoError(t, err)
+		assert.Len(t, res.Conns, 2)
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			writeTestConnections(t, w, conns)
+		}))
+		r := newTestSystemProbe(t, socketPath)
+
+		res, err := r.GetConnections("test-client")
+		require.NoError(t, err)
+		assert.Len(t, res.Conns, 2)
+	})
+}
+
+func TestLastGetConnectionsStats(t *testing.T) {
+	conns := &model.Connections{Conns: []*model.Connection{{Pid: 1}, {Pid: 2}, {Pid: 3}}}
+	buf, err := proto.Marshal(conns)
+	require.NoError(t, err)
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, _ = gw.Write(buf)
+	require.NoError(t, gw.Close())
+
+	socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-type", contentTypeProtobuf)
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(gzipped.Bytes())
+	}))
+	r := newTestSystemProbe(t, socketPath)
+
+	res, err := r.GetConnections("test-client")
+	require.NoError(t, err)
+	assert.Len(t, res.Conns, 3)
+
+	stats := LastGetConnectionsStats()
+	assert.Equal(t, gzipped.Len(), stats.BytesRead)
+	assert.Equal(t, 3, stats.Connections)
+	assert.Greater(t, int64(stats.DecodeDuration), int64(0))
+}
+
+func TestGetConnectionsContentLength(t *testing.T) {
+	conns := &model.Connections{Conns: []*model.Connection{{Pid: 1}, {Pid: 2}}}
+	buf, err := proto.Marshal(conns)
+	require.NoError(t, err)
+
+	t.Run("truncated", func(t *testing.T) {
+		socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-type", contentTypeProtobuf)
+			w.Header().Set("Content-Length", strconv.Itoa(len(buf)+100))
+			_, _ = w.Write(buf)
+		}))
+		r := newTestSystemProbe(t, socketPath)
+
+		res, err := r.GetConnections("test-client")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Content-Length")
+		assert.Nil(t, res)
+	})
+
+	t.Run("truncated gzip", func(t *testing.T) {
+		socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var gzipped bytes.Buffer
+			gw := gzip.NewWriter(&gzipped)
+			_, _ = gw.Write(buf)
+			_ = gw.Close()
+
+			w.Header().Set("Content-type", contentTypeProtobuf)
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set("Content-Length", strconv.Itoa(gzipped.Len()+1))
+			_, _ = w.Write(gzipped.Bytes())
+		}))
+		r := newTestSystemProbe(t, socketPath)
+
+		_, err := r.GetConnections("test-client")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Content-Length")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-type", contentTypeProtobuf)
+			w.Header().Set("Content-Length", strconv.Itoa(len(buf)))
+			_, _ = w.Write(buf)
+		}))
+		r := newTestSystemProbe(t, socketPath)
+
+		res, err := r.GetConnections("test-client")
+		require.NoError(t, err)
+		assert.Len(t, res.Conns, 2)
+	})
+}
+
+func TestGetConnectionsContentType(t *testing.T) {
+	conns := &model.Connections{Conns: []*model.Connection{{Pid: 1}, {Pid: 2}}}
+	protoBody, err := proto.Marshal(conns)
+	require.NoError(t, err)
+	jsonBody, err := (&jsonpb.Marshaler{}).MarshalToString(conns)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		expectError bool
+	}{
+		{name: "protobuf", contentType: contentTypeProtobuf, body: protoBody},
+		{name: "json", contentType: "application/json", body: []byte(jsonBody)},
+		{name: "no content type defaults to json", body: []byte(jsonBody)},
+		{name: "json body announced as protobuf", contentType: contentTypeProtobuf, body: []byte(jsonBody), expectError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				assert.Equal(t, contentTypeProtobuf, req.Header.Get("Accept"))
+				w.Header().Set("Content-type", tt.contentType)
+				_, _ = w.Write(tt.body)
+			}))
+			r := newTestSystemProbe(t, socketPath)
+
+			res, err := r.GetConnections("test-client")
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, res.Conns, 2)
+		})
+	}
+}
+
+func TestRemoteSystemProbeUtilStatus(t *testing.T) {
+	globalUtilLock.Lock()
+	prevUtil := globalUtil
+	globalUtil = nil
+	globalUtilLock.Unlock()
+	t.Cleanup(func() {
+		globalUtilLock.Lock()
+		globalUtil = prevUtil
+		globalUtilLock.Unlock()
+	})
+
+	assert.Equal(t, retry.NeedSetup, GetRemoteSystemProbeUtilStatus())
+
+	socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	r := newTestSystemProbe(t, socketPath)
+	require.NoError(t, r.initRetry.SetupRetrier(&retry.Config{
+		Name:          "system-probe-util",
+		AttemptMethod: r.init,
+		Strategy:      retry.OneTry,
+	}))
+	assert.Equal(t, retry.Idle, r.Status())
+
+	globalUtilLock.Lock()
+	globalUtil = r
+	globalUtilLock.Unlock()
+	assert.Equal(t, retry.Idle, GetRemoteSystemProbeUtilStatus())
+
+	require.Nil(t, r.initRetry.TriggerRetry())
+	assert.Equal(t, retry.OK, r.Status())
+	assert.Equal(t, retry.OK, GetRemoteSystemProbeUtilStatus())
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		expectError bool
+	}{
+		{name: "healthy", statusCode: http.StatusOK},
+		{name: "unhealthy", statusCode: http.StatusInternalServerError, expectError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				requests.Inc()
+				assert.Equal(t, "/debug/stats", req.URL.Path)
+				w.WriteHeader(tt.statusCode)
+			}))
+			r := newTestSystemProbe(t, socketPath)
+			require.NoError(t, r.initRetry.SetupRetrier(&retry.Config{
+				Name:          "system-probe-util",
+				AttemptMethod: r.init,
+				Strategy:      retry.OneTry,
+			}))
+
+			err := r.Ping()
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, int32(1), requests.Load())
+			assert.Equal(t, retry.Idle, r.Status())
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		r := newTestSystemProbe(t, filepath.Join(t.TempDir(), "missing.sock"))
+		assert.Error(t, r.Ping())
+	})
+}
+
+func TestParseSystemProbeAddress(t *testing.T) {
+	tests := []struct {
+		path        string
+		expected    systemProbeAddress
+		expectError bool
+	}{
+		{path: "/opt/datadog-agent/run/sysprobe.sock", expected: systemProbeAddress{network: "unix", address: "/opt/datadog-agent/run/sysprobe.sock"}},
+		{path: "tcp://sysprobe:3333", expected: systemProbeAddress{network: "tcp", address: "sysprobe:3333"}},
+		{path: "tcp://127.0.0.1:3333/", expected: systemProbeAddress{network: "tcp", address: "127.0.0.1:3333"}},
+		{path: "https://sysprobe.local:3334", expected: systemProbeAddress{network: "tcp", address: "sysprobe.local:3334", tls: true}},
+		{path: "https://[::1]:3334", expected: systemProbeAddress{network: "tcp", address: "[::1]:3334", tls: true}},
+		{path: "tcp://sysprobe", expectError: true},
+		{path: "https://sysprobe", expectError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			addr, err := parseSystemProbeAddress(tt.path)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, addr)
+		})
+	}
+}
+
+func TestSystemProbeOverTCP(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(`{"uptime":42}`))
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		path := "tcp://" + srv.Listener.Addr().String()
+		r := newTestSystemProbe(t, path)
+		SetSystemProbePath(path)
+		require.NoError(t, CheckPath())
+
+		stats, err := r.GetStats()
+		require.NoError(t, err)
+		assert.Equal(t, 42.0, stats["uptime"])
+	})
+
+	t.Run("https", func(t *testing.T) {
+		srv := httptest.NewTLSServer(handler)
+		defer srv.Close()
+
+		prevConfig := globalClientConfig
+		t.Cleanup(func() { SetSystemProbeClientConfig(prevConfig) })
+
+		path := "https://" + srv.Listener.Addr().String()
+
+		// the certificate of the test server is not trusted by default
+		r := newTestSystemProbe(t, path)
+		_, err := r.GetStats()
+		assert.Error(t, err)
+
+		cfg := DefaultClientConfig()
+		cfg.TLSConfig = &tls.Config{RootCAs: x509.NewCertPool()}
+		cfg.TLSConfig.RootCAs.AddCert(srv.Certificate())
+		SetSystemProbeClientConfig(cfg)
+
+		r = newTestSystemProbe(t, path)
+		stats, err := r.GetStats()
+		require.NoError(t, err)
+		assert.Equal(t, 42.0, stats["uptime"])
+	})
+}
+
+func TestSystemProbeClientTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-unblock:
+		case <-req.Context().Done():
+		}
+	}))
+	defer close(unblock)
+
+	t.Cleanup(func() { SetSystemProbeClientConfig(DefaultClientConfig()) })
+	SetSystemProbeClientConfig(ClientConfig{
+		Timeout:               100 * time.Millisecond,
+		ResponseHeaderTimeout: 100 * time.Millisecond,
+	})
+	r := newTestSystemProbe(t, socketPath)
+
+	start := time.Now()
+	_, err := r.GetConnections("test-client")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), DefaultClientConfig().ResponseHeaderTimeout)
+}
+
+func TestResetGlobalSystemProbeUtil(t *testing.T) {
+	statsHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	})
+	firstSocketPath := startTestServer(t, statsHandler)
+	secondSocketPath := startTestServer(t, statsHandler)
+
+	prevPaths := globalSocketPaths
+	t.Cleanup(func() {
+		SetSystemProbePaths(prevPaths...)
+		ResetGlobalSystemProbeUtil()
+	})
+	ResetGlobalSystemProbeUtil()
+
+	SetSystemProbePath(firstSocketPath)
+	r, err := GetRemoteSystemProbeUtil()
+	require.NoError(t, err)
+	assert.Equal(t, firstSocketPath, r.SocketPath())
+
+	// changing the path has no effect on the existing util
+	SetSystemProbePath(secondSocketPath)
+	r, err = GetRemoteSystemProbeUtil()
+	require.NoError(t, err)
+	assert.Equal(t, firstSocketPath, r.SocketPath())
+
+	ResetGlobalSystemProbeUtil()
+	assert.Equal(t, retry.NeedSetup, GetRemoteSystemProbeUtilStatus())
+
+	r, err = GetRemoteSystemProbeUtil()
+	require.NoError(t, err)
+	assert.Equal(t, secondSocketPath, r.SocketPath())
+	_, err = r.GetStats()
+	assert.NoError(t, err)
+}
+
+func TestGetConnectionsInPages(t *testing.T) {
+	var all []*model.Connection
+	for pid := int32(1); pid <= 5; pid++ {
+		all = append(all, &model.Connection{Pid: pid})
+	}
+
+	collectPIDs := func(t *testing.T, r *RemoteSysProbeUtil, pageSize int) ([]int32, int) {
+		var pids []int32
+		pages := 0
+		err := r.GetConnectionsInPages("test-client", pageSize, func(conns *model.Connections) error {
+			pages++
+			for _, c := range conns.Conns {
+				pids = append(pids, c.Pid)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		return pids, pages
+	}
+
+	t.Run("multiple pages", func(t *testing.T) {
+		socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			limit, err := strconv.Atoi(req.URL.Query().Get(limitParam))
+			require.NoError(t, err)
+			offset, err := strconv.Atoi(req.URL.Query().Get(offsetParam))
+			require.NoError(t, err)
+
+			start, end := offset, offset+limit
+			if start > len(all) {
+				start = len(all)
+			}
+			if end > len(all) {
+				end = len(all)
+			}
+			w.Header().Set(pageOffsetHeader, strconv.Itoa(offset))
+			writeTestConnections(t, w, &model.Connections{Conns: all[start:end]})
+		}))
+		r := newTestSystemProbe(t, socketPath)
+
+		pids, pages := collectPIDs(t, r, 2)
+		assert.Equal(t, []int32{1, 2, 3, 4, 5}, pids)
+		assert.Equal(t, 3, pages)
+
+		// the last page being full, an empty page is requested but not passed to the callback
+		pids, pages = collectPIDs(t, r, 5)
+		assert.Equal(t, []int32{1, 2, 3, 4, 5}, pids)
+		assert.Equal(t, 1, pages)
+	})
+
+	t.Run("pagination not supported", func(t *testing.T) {
+		socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			writeTestConnections(t, w, &model.Connections{Conns: all})
+		}))
+		r := newTestSystemProbe(t, socketPath)
+
+		pids, pages := collectPIDs(t, r, 2)
+		assert.Equal(t, []int32{1, 2, 3, 4, 5}, pids)
+		assert.Equal(t, 1, pages)
+	})
+
+	t.Run("pagination not supported with a full page", func(t *testing.T) {
+		requests := 0
+		socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests++
+			writeTestConnections(t, w, &model.Connections{Conns: all})
+		}))
+		r := newTestSystemProbe(t, socketPath)
+
+		// the server returns exactly pageSize connections, without acknowledging the pagination
+		pids, pages := collectPIDs(t, r, len(all))
+		assert.Equal(t, []int32{1, 2, 3, 4, 5}, pids)
+		assert.Equal(t, 1, pages)
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("callback error", func(t *testing.T) {
+		socketPath := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			writeTestConnections(t, w, &model.Connections{Conns: all})
+		}))
+		r := newTestSystemProbe(t, socketPath)
+
+		err := r.GetConnectionsInPages("test-client", 2, func(*model.Connections) error {
+			return assert.AnError
+		})
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
@@ -9,8 +9,13 @@
 package net
 
 import (
+	"context"
+	"crypto/tls"
+	"time"
+
 	model "github.com/DataDog/agent-payload/v5/process"
 	"github.com/DataDog/datadog-agent/pkg/ebpf"
+	"github.com/DataDog/datadog-agent/pkg/util/retry"
 )
 
 // RemoteSysProbeUtil is not supported
@@ -21,6 +26,47 @@ func SetSystemProbePath(_ string) {
 	// no-op
 }
 
+// ClientConfig holds the timeouts of the HTTP client used to query the system probe
+type ClientConfig struct {
+	// Timeout is the overall timeout of a request, including reading the response body
+	Timeout time.Duration
+	// ResponseHeaderTimeout is the time to wait for the response headers once the request is written
+	ResponseHeaderTimeout time.Duration
+	// TLSConfig is used to connect to a system probe listening on an https:// address. The system roots are
+	// trusted if nil.
+	TLSConfig *tls.Config
+}
+
+// GetConnectionsStats describes a retrieval of connections from the system probe
+type GetConnectionsStats struct {
+	// BytesRead is the size of the response body, before decompression
+	BytesRead int
+	// DecodeDuration is the time spent decompressing and decoding the response body
+	DecodeDuration time.Duration
+	// Connections is the number of connections decoded
+	Connections int
+}
+
+// LastGetConnectionsStats is not supported
+func LastGetConnectionsStats() GetConnectionsStats {
+	return GetConnectionsStats{}
+}
+
+// DefaultClientConfig is not supported
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{}
+}
+
+// SetSystemProbeClientConfig is not supported
+func SetSystemProbeClientConfig(_ ClientConfig) {
+	// no-op
+}
+
+// SetSystemProbePaths is not supported
+func SetSystemProbePaths(_ ...string) {
+	// no-op
+}
+
 // CheckPath is not supported
 func CheckPath() error {
 	return ebpf.ErrNotImplemented
@@ -31,11 +77,46 @@ func GetRemoteSystemProbeUtil() (*RemoteSysProbeUtil, error) {
 	return &RemoteSysProbeUtil{}, ebpf.ErrNotImplemented
 }
 
+// ResetGlobalSystemProbeUtil is not supported
+func ResetGlobalSystemProbeUtil() {
+	// no-op
+}
+
+// GetRemoteSystemProbeUtilStatus is not supported
+func GetRemoteSystemProbeUtilStatus() retry.Status {
+	return retry.PermaFail
+}
+
+// Status is not supported
+func (r *RemoteSysProbeUtil) Status() retry.Status {
+	return retry.PermaFail
+}
+
+// Ping is not supported
+func (r *RemoteSysProbeUtil) Ping() error {
+	return ebpf.ErrNotImplemented
+}
+
 // GetConnections is not supported
 func (r *RemoteSysProbeUtil) GetConnections(clientID string) (*model.Connections, error) {
 	return nil, ebpf.ErrNotImplemented
 }
 
+// GetConnectionsWithContext is not supported
+func (r *RemoteSysProbeUtil) GetConnectionsWithContext(ctx context.Context, clientID string) (*model.Connections, error) {
+	return nil, ebpf.ErrNotImplemented
+}
+
+// GetConnectionsPaged is not supported
+func (r *RemoteSysProbeUtil) GetConnectionsPaged(clientID, token string) (*model.Connections, string, error) {
+	return nil, "", ebpf.ErrNotImplemented
+}
+
+// GetConnectionsInPages is not supported
+func (r *RemoteSysProbeUtil) GetConnectionsInPages(clientID string, pageSize int, fn func(*model.Connections) error) error {
+	return ebpf.ErrNotImplemented
+}
+
 // GetStats is not supported
 func (r *RemoteSysProbeUtil) GetStats() (map[string]interface{}, error) {
 	return nil, ebpf.ErrNotImplemented
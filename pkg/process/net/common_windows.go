@@ -24,9 +24,9 @@ const (
 	procStatsURL = "http://localhost:3333/" + string(sysconfig.ProcessModule) + "stats"
 )
 
-// CheckPath is used to make sure the globalSocketPath has been set before attempting to connect
+// CheckPath is used to make sure the globalSocketPaths have been set before attempting to connect
 func CheckPath() error {
-	if globalSocketPath == "" {
+	if len(globalSocketPaths) == 0 {
 		return fmt.Errorf("remote tracer has no path defined")
 	}
 	return nil
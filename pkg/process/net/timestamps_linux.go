@@ -0,0 +1,56 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package net
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/ebpf"
+	"github.com/DataDog/datadog-agent/pkg/network"
+)
+
+// monotonicNanoseconds reads the clock of the connection timestamps
+var monotonicNanoseconds = ebpf.NowNanoseconds
+
+// RelativizeTimestamps returns, for each connection, the time elapsed between its last update and now.
+// The returned slice is parallel to conns, which are left unmodified.
+// The LastUpdateEpoch of the connections aren't wall clock times but readings of the monotonic clock
+// (see ebpf.NowNanoseconds), so now is converted to this clock from its offset to the current time.
+// Connections without a timestamp, or with a timestamp after now, get a zero duration.
+// It is only available on Linux, where the connection timestamps are read from the eBPF clock.
+func RelativizeTimestamps(conns []network.ConnectionStats, now time.Time) ([]time.Duration, error) {
+	current, err := monotonicNanoseconds()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the monotonic clock: %w", err)
+	}
+	return relativizeTimestamps(conns, monotonicAt(current, time.Since(now))), nil
+}
+
+// monotonicAt returns the reading of the monotonic clock elapsed ago, given its current reading
+func monotonicAt(current int64, elapsed time.Duration) uint64 {
+	at := current - elapsed.Nanoseconds()
+	if at < 0 {
+		return 0
+	}
+	return uint64(at)
+}
+
+// relativizeTimestamps is RelativizeTimestamps with now read from the monotonic clock
+func relativizeTimestamps(conns []network.ConnectionStats, now uint64) []time.Duration {
+	durations := make([]time.Duration, len(conns))
+	for i := range conns {
+		lastUpdate := conns[i].LastUpdateEpoch
+		if lastUpdate == 0 || lastUpdate > now {
+			continue
+		}
+		durations[i] = time.Duration(now - lastUpdate)
+	}
+	return durations
+}
@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package net
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelativizeTimestamps(t *testing.T) {
+	prev := monotonicNanoseconds
+	t.Cleanup(func() { monotonicNanoseconds = prev })
+	monotonicNanoseconds = func() (int64, error) {
+		return int64(100 * time.Second), nil
+	}
+
+	conns := []network.ConnectionStats{
+		{Pid: 1, LastUpdateEpoch: uint64(95 * time.Second)},
+		{Pid: 2, LastUpdateEpoch: 0},
+		{Pid: 3, LastUpdateEpoch: uint64(101 * time.Second)},
+		{Pid: 4, LastUpdateEpoch: uint64(40 * time.Second)},
+	}
+
+	// now is about 100s on the monotonic clock, give or take the time elapsed since it was read
+	durations, err := RelativizeTimestamps(conns, time.Now())
+	require.NoError(t, err)
+	require.Len(t, durations, 4)
+	assert.InDelta(t, 5*time.Second, durations[0], float64(time.Second))
+	assert.Zero(t, durations[1])
+	assert.Zero(t, durations[2])
+	assert.InDelta(t, time.Minute, durations[3], float64(time.Second))
+
+	// connections are left untouched
+	assert.Equal(t, uint64(95*time.Second), conns[0].LastUpdateEpoch)
+	assert.Equal(t, uint64(0), conns[1].LastUpdateEpoch)
+
+	durations, err = RelativizeTimestamps(nil, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, durations)
+
+	t.Run("now in the past", func(t *testing.T) {
+		durations, err := RelativizeTimestamps(conns, time.Now().Add(-10*time.Second))
+		require.NoError(t, err)
+		assert.Zero(t, durations[0])
+		assert.InDelta(t, 50*time.Second, durations[3], float64(time.Second))
+	})
+
+	t.Run("clock error", func(t *testing.T) {
+		clockErr := errors.New("no clock")
+		monotonicNanoseconds = func() (int64, error) {
+			return 0, clockErr
+		}
+		_, err := RelativizeTimestamps(conns, time.Now())
+		assert.ErrorIs(t, err, clockErr)
+	})
+}
+
+func TestRelativizeMonotonicTimestamps(t *testing.T) {
+	now := uint64(100 * time.Second)
+	conns := []network.ConnectionStats{
+		{Pid: 1, LastUpdateEpoch: uint64(95 * time.Second)},
+		{Pid: 2, LastUpdateEpoch: 0},
+		{Pid: 3, LastUpdateEpoch: now},
+		{Pid: 4, LastUpdateEpoch: uint64(101 * time.Second)},
+		{Pid: 5, LastUpdateEpoch: uint64(40 * time.Second)},
+	}
+
+	durations := relativizeTimestamps(conns, now)
+	assert.Equal(t, []time.Duration{5 * time.Second, 0, 0, 0, time.Minute}, durations)
+}
+
+func TestMonotonicAt(t *testing.T) {
+	current := int64(100 * time.Second)
+	assert.Equal(t, uint64(90*time.Second), monotonicAt(current, 10*time.Second))
+	// now in the future
+	assert.Equal(t, uint64(110*time.Second), monotonicAt(current, -10*time.Second))
+	// before the clock started
+	assert.Equal(t, uint64(0), monotonicAt(current, 200*time.Second))
+}
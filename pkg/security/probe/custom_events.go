@@ -12,6 +12,8 @@ package probe
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/security/secl/compiler/eval"
@@ -193,6 +195,20 @@ type RuleLoaded struct {
 	Expression string `json:"expression"`
 }
 
+// NewRuleLoaded returns a new RuleLoaded, after checking that its expression is a syntactically valid SECL rule
+func NewRuleLoaded(id, version, expression string) (*RuleLoaded, error) {
+	rule := &eval.Rule{ID: id, Expression: expression}
+	if err := rule.Parse(); err != nil {
+		return nil, fmt.Errorf("invalid expression for rule `%s`: %w", id, err)
+	}
+
+	return &RuleLoaded{
+		ID:         id,
+		Version:    version,
+		Expression: expression,
+	}, nil
+}
+
 // PolicyLoaded is used to report policy was loaded
 // easyjson:json
 type PolicyLoaded struct {
@@ -201,6 +217,31 @@ type PolicyLoaded struct {
 	RulesIgnored []*RuleIgnored `json:"rules_ignored,omitempty"`
 }
 
+// Validate checks the consistency of the policy, returning a warning for each rule ID defined more than once,
+// either within the loaded rules or across the loaded and ignored rules
+func (p *PolicyLoaded) Validate() ([]string, error) {
+	var warnings []string
+
+	loaded := make(map[string]int, len(p.RulesLoaded))
+	for _, rule := range p.RulesLoaded {
+		if rule.ID == "" {
+			return nil, errors.New("loaded rule without ID")
+		}
+		loaded[rule.ID]++
+		if loaded[rule.ID] == 2 {
+			warnings = append(warnings, fmt.Sprintf("rule `%s` is loaded more than once", rule.ID))
+		}
+	}
+
+	for _, rule := range p.RulesIgnored {
+		if loaded[rule.ID] > 0 {
+			warnings = append(warnings, fmt.Sprintf("rule `%s` is both loaded and ignored", rule.ID))
+		}
+	}
+
+	return warnings, nil
+}
+
 // RulesetLoadedEvent is used to report that a new ruleset was loaded
 // easyjson:json
 type RulesetLoadedEvent struct {
@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux && go1.18
+// +build linux,go1.18
+
+package probe
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mailru/easyjson"
+)
+
+type roundTripEvent interface {
+	easyjson.Marshaler
+	easyjson.Unmarshaler
+}
+
+// decodeGenericJSON decodes a JSON document into generic maps and slices, so that documents
+// can be compared regardless of the order of their object keys
+func decodeGenericJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// checkRoundTrip unmarshals arbitrary data into an event and, if it's valid, checks that marshaling it
+// and unmarshaling it again yields the same document, regardless of the order of the map entries.
+// prepare is called on the first decoded event to clear fields which aren't meant to be decoded.
+func checkRoundTrip(t *testing.T, data []byte, newEvent func() roundTripEvent, prepare func(roundTripEvent)) {
+	first := newEvent()
+	if err := easyjson.Unmarshal(data, first); err != nil {
+		return
+	}
+	if prepare != nil {
+		prepare(first)
+	}
+
+	encoded, err := easyjson.Marshal(first)
+	if err != nil {
+		// some decoded values can't be encoded back, like dates outside of the RFC 3339 range
+		return
+	}
+
+	second := newEvent()
+	if err := easyjson.Unmarshal(encoded, second); err != nil {
+		t.Fatalf("failed to unmarshal `%s` marshaled from `%s`: %v", encoded, data, err)
+	}
+	reencoded, err := easyjson.Marshal(second)
+	if err != nil {
+		t.Fatalf("failed to marshal event unmarshaled from `%s`: %v", encoded, err)
+	}
+
+	expected, err := decodeGenericJSON(encoded)
+	if err != nil {
+		t.Fatalf("invalid JSON `%s` marshaled from `%s`: %v", encoded, data, err)
+	}
+	actual, err := decodeGenericJSON(reencoded)
+	if err != nil {
+		t.Fatalf("invalid JSON `%s` marshaled from `%s`: %v", reencoded, encoded, err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("round trip mismatch for `%s`: `%s` != `%s`", data, encoded, reencoded)
+	}
+}
+
+func FuzzRulesetLoadedEventRoundTrip(f *testing.F) {
+	f.Add([]byte(`{"date":"2022-05-04T10:11:12.123456789Z","policies":[{"Version":"1.0","rules_loaded":[{"id":"rule_a","version":"2","expression":"open.file.path == \"/etc/passwd\""}],"rules_ignored":[{"id":"rule_b","expression":"exec.file.name ==","reason":"syntax error"}]}],"macros_loaded":["macro_a"]}`))
+	f.Add([]byte(`{"date":"2022-05-04T10:11:12+02:00","policies":[null,{"rules_loaded":[null]}],"policies_ignored":[{"name":"policy","reason":"error"}],"macros_loaded":[]}`))
+	f.Add([]byte(`{"policies":null,"macros_loaded":null}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkRoundTrip(t, data, func() roundTripEvent {
+			return &RulesetLoadedEvent{}
+		}, func(event roundTripEvent) {
+			// the ignored policies are only reported, their unmarshaler is a no-op
+			event.(*RulesetLoadedEvent).PoliciesIgnored = nil
+		})
+	})
+}
+
+func FuzzNoisyProcessEventRoundTrip(f *testing.F) {
+	f.Add([]byte(`{"date":"2022-05-04T10:11:12Z","pid_count":1000,"threshold":500,"control_period":2000000000,"discarded_until":"2022-05-04T10:11:22Z","pid":42,"comm":"noisy"}`))
+	f.Add([]byte(`{"pid_count":18446744073709551615,"threshold":-1,"control_period":-9223372036854775808,"comm":"noisy\u00e9"}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkRoundTrip(t, data, func() roundTripEvent {
+			return &NoisyProcessEvent{}
+		}, nil)
+	})
+}
+
+func FuzzEventLostWriteRoundTrip(f *testing.F) {
+	f.Add([]byte(`{"date":"2022-05-04T10:11:12Z","map":"events","per_event":{"open":12,"exec":3,"dns":0}}`))
+	f.Add([]byte(`{"map":"","per_event":{}}`))
+	f.Add([]byte(`{"per_event":null}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkRoundTrip(t, data, func() roundTripEvent {
+			return &EventLostWrite{}
+		}, nil)
+	})
+}
@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package probe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyLoadedValidate(t *testing.T) {
+	t.Run("unique", func(t *testing.T) {
+		policy := &PolicyLoaded{
+			RulesLoaded: []*RuleLoaded{
+				{ID: "rule_a", Expression: `open.file.path == "/etc/passwd"`},
+				{ID: "rule_b", Expression: `exec.file.name == "nc"`},
+			},
+			RulesIgnored: []*RuleIgnored{
+				{ID: "rule_c", Reason: "syntax error"},
+			},
+		}
+
+		warnings, err := policy.Validate()
+		assert.NoError(t, err)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		policy := &PolicyLoaded{
+			RulesLoaded: []*RuleLoaded{
+				{ID: "rule_a", Expression: `open.file.path == "/etc/passwd"`},
+				{ID: "rule_b", Expression: `exec.file.name == "nc"`},
+				{ID: "rule_a", Expression: `open.file.path == "/etc/shadow"`},
+				{ID: "rule_a", Expression: `open.file.path == "/etc/group"`},
+			},
+			RulesIgnored: []*RuleIgnored{
+				{ID: "rule_b", Reason: "syntax error"},
+			},
+		}
+
+		warnings, err := policy.Validate()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"rule `rule_a` is loaded more than once",
+			"rule `rule_b` is both loaded and ignored",
+		}, warnings)
+	})
+
+	t.Run("empty id", func(t *testing.T) {
+		policy := &PolicyLoaded{
+			RulesLoaded: []*RuleLoaded{{Expression: `exec.file.name == "nc"`}},
+		}
+
+		_, err := policy.Validate()
+		assert.Error(t, err)
+	})
+}
+
+func TestNewRuleLoaded(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		rule, err := NewRuleLoaded("rule_a", "1.2.3", `open.file.path == "/etc/passwd" && process.uid != 0`)
+		assert.NoError(t, err)
+		assert.Equal(t, &RuleLoaded{
+			ID:         "rule_a",
+			Version:    "1.2.3",
+			Expression: `open.file.path == "/etc/passwd" && process.uid != 0`,
+		}, rule)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, expression := range []string{
+			``,
+			`open.file.path ==`,
+			`open.file.path == "/etc/passwd" &&`,
+			`(exec.file.name == "nc"`,
+		} {
+			rule, err := NewRuleLoaded("rule_a", "", expression)
+			assert.Error(t, err, expression)
+			assert.Nil(t, rule, expression)
+		}
+	})
+}
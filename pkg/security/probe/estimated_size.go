@@ -0,0 +1,255 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package probe
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/security/secl/rules"
+	"github.com/DataDog/datadog-agent/pkg/security/utils"
+)
+
+// jsonTimeSize is the size of a JSON encoded RFC3339 timestamp with a nanosecond precision, quotes included
+const jsonTimeSize = len(`"2006-01-02T15:04:05.999999999-07:00"`)
+
+var (
+	timeType         = reflect.TypeOf(time.Time{})
+	easyjsonTimeType = reflect.TypeOf(utils.EasyjsonTime{})
+)
+
+// jsonStringSize returns the size of a JSON encoded string, quotes and escaping included
+func jsonStringSize(s string) int {
+	size := len(s) + 2
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\' || c == '\n' || c == '\r' || c == '\t':
+			size++
+		case c < 0x20 || c == '<' || c == '>' || c == '&':
+			// \u00XX
+			size += 5
+		}
+	}
+	return size
+}
+
+// jsonFieldSize returns the size of the key of an object field, its quotes, colon and separating comma included
+func jsonFieldSize(name string) int {
+	return len(name) + 4
+}
+
+// EstimatedSize returns an approximation of the size of the JSON encoding of the event, without encoding it
+func (e *RulesetLoadedEvent) EstimatedSize() int {
+	size := 2 + jsonFieldSize("date") + jsonTimeSize
+
+	size += jsonFieldSize("policies") + 2
+	for _, policy := range e.PoliciesLoaded {
+		size += policy.estimatedSize() + 1
+	}
+
+	if e.PoliciesIgnored != nil {
+		size += jsonFieldSize("policies_ignored") + e.PoliciesIgnored.estimatedSize()
+	}
+
+	size += jsonFieldSize("macros_loaded") + 2
+	for _, macro := range e.MacrosLoaded {
+		size += jsonStringSize(macro) + 1
+	}
+
+	return size
+}
+
+func (p *PolicyLoaded) estimatedSize() int {
+	size := 2 + jsonFieldSize("Version") + jsonStringSize(p.Version)
+
+	size += jsonFieldSize("rules_loaded") + 2
+	for _, rule := range p.RulesLoaded {
+		size += rule.estimatedSize() + 1
+	}
+
+	if len(p.RulesIgnored) > 0 {
+		size += jsonFieldSize("rules_ignored") + 2
+		for _, rule := range p.RulesIgnored {
+			size += rule.estimatedSize() + 1
+		}
+	}
+
+	return size
+}
+
+func (r *RuleLoaded) estimatedSize() int {
+	size := 2 + jsonFieldSize("id") + jsonStringSize(r.ID)
+	if r.Version != "" {
+		size += jsonFieldSize("version") + jsonStringSize(r.Version)
+	}
+	return size + jsonFieldSize("expression") + jsonStringSize(r.Expression)
+}
+
+func (r *RuleIgnored) estimatedSize() int {
+	size := 2 + jsonFieldSize("id") + jsonStringSize(r.ID)
+	if r.Version != "" {
+		size += jsonFieldSize("version") + jsonStringSize(r.Version)
+	}
+	size += jsonFieldSize("expression") + jsonStringSize(r.Expression)
+	return size + jsonFieldSize("reason") + jsonStringSize(r.Reason)
+}
+
+func (r *PoliciesIgnored) estimatedSize() int {
+	if r.Errors == nil {
+		return 0
+	}
+
+	size := 2
+	for _, err := range r.Errors.Errors {
+		if perr, ok := err.(*rules.ErrPolicyLoad); ok {
+			size += 2 + jsonFieldSize("name") + jsonStringSize(perr.Name)
+			size += jsonFieldSize("reason") + jsonStringSize(perr.Err.Error()) + 1
+		}
+	}
+	return size
+}
+
+// EstimatedSize returns an approximation of the size of the JSON encoding of the event, without encoding it
+func (e *AbnormalPathEvent) EstimatedSize() int {
+	size := 2 + jsonFieldSize("date") + jsonTimeSize
+	size += jsonFieldSize("triggering_event") + estimatedJSONSize(reflect.ValueOf(e.Event))
+	return size + jsonFieldSize("path_resolution_error") + jsonStringSize(e.PathResolutionError)
+}
+
+// estimatedJSONSize returns an approximation of the size of the JSON encoding of a serializer, following the
+// `json` tags of its fields
+func estimatedJSONSize(v reflect.Value) int {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return len("null")
+		}
+		return estimatedJSONSize(v.Elem())
+	case reflect.String:
+		return jsonStringSize(v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			return len("true")
+		}
+		return len("false")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var buf [20]byte
+		return len(strconv.AppendInt(buf[:0], v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var buf [20]byte
+		return len(strconv.AppendUint(buf[:0], v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		var buf [32]byte
+		return len(strconv.AppendFloat(buf[:0], v.Float(), 'g', -1, 64))
+	case reflect.Slice:
+		if v.IsNil() {
+			return len("null")
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return base64.StdEncoding.EncodedLen(v.Len()) + 2
+		}
+		return estimatedJSONArraySize(v)
+	case reflect.Array:
+		return estimatedJSONArraySize(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return len("null")
+		}
+		size := 2
+		iter := v.MapRange()
+		for iter.Next() {
+			size += estimatedJSONSize(iter.Key()) + 1 + estimatedJSONSize(iter.Value()) + 1
+		}
+		return size
+	case reflect.Struct:
+		if v.Type() == timeType || v.Type() == easyjsonTimeType {
+			return jsonTimeSize
+		}
+		return 2 + estimatedJSONFieldsSize(v)
+	}
+	return 0
+}
+
+func estimatedJSONArraySize(v reflect.Value) int {
+	size := 2
+	for i := 0; i < v.Len(); i++ {
+		size += estimatedJSONSize(v.Index(i)) + 1
+	}
+	return size
+}
+
+// estimatedJSONFieldsSize returns the size of the fields of a struct, the fields of the embedded structs without
+// `json` tag being inlined
+func estimatedJSONFieldsSize(v reflect.Value) int {
+	var size int
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if idx := strings.IndexByte(tag, ','); idx != -1 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+
+		fv := v.Field(i)
+		if field.Anonymous && name == "" {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				size += estimatedJSONFieldsSize(fv)
+			}
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if strings.Contains(opts, "omitempty") && isEmptyJSONValue(fv) {
+			continue
+		}
+
+		size += jsonFieldSize(name) + estimatedJSONSize(fv)
+	}
+
+	return size
+}
+
+// isEmptyJSONValue returns whether a field tagged with `omitempty` is left out of the JSON encoding
+func isEmptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package probe
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/security/secl/rules"
+	"github.com/DataDog/datadog-agent/pkg/security/utils"
+	"github.com/hashicorp/go-multierror"
+	"github.com/mailru/easyjson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type estimatedSizer interface {
+	easyjson.Marshaler
+	EstimatedSize() int
+}
+
+func assertEstimatedSize(t *testing.T, event estimatedSizer) {
+	data, err := easyjson.Marshal(event)
+	require.NoError(t, err)
+
+	estimated := event.EstimatedSize()
+	t.Logf("estimated %d bytes, marshaled %d bytes", estimated, len(data))
+	assert.LessOrEqual(t, math.Abs(float64(estimated-len(data)))/float64(len(data)), 0.1, string(data))
+}
+
+func TestRulesetLoadedEventEstimatedSize(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		assertEstimatedSize(t, &RulesetLoadedEvent{
+			Timestamp:    time.Now(),
+			MacrosLoaded: []rules.MacroID{},
+		})
+	})
+
+	t.Run("policies", func(t *testing.T) {
+		var errs *multierror.Error
+		errs = multierror.Append(errs, &rules.ErrPolicyLoad{Name: "broken.policy", Err: errors.New("yaml: line 3: did not find expected key")})
+
+		assertEstimatedSize(t, &RulesetLoadedEvent{
+			Timestamp: time.Now(),
+			PoliciesLoaded: []*PolicyLoaded{
+				{
+					Version: "1.2.3",
+					RulesLoaded: []*RuleLoaded{
+						{ID: "rule_a", Version: "1", Expression: `open.file.path == "/etc/passwd" && process.uid > 0`},
+						{ID: "rule_b", Expression: `exec.file.name in ["nc", "ncat", "socat"]`},
+						{ID: "rule_c", Expression: `open.file.path =~ "/etc/*" && open.flags & O_CREAT > 0`},
+					},
+					RulesIgnored: []*RuleIgnored{
+						{ID: "rule_d", Expression: `exec.file.nam == "curl"`, Reason: "field `exec.file.nam` not found"},
+					},
+				},
+				{
+					Version: "4.5.6",
+					RulesLoaded: []*RuleLoaded{
+						{ID: "rule_e", Version: "2", Expression: `chmod.file.mode & S_ISUID > 0`},
+					},
+				},
+			},
+			PoliciesIgnored: &PoliciesIgnored{Errors: errs},
+			MacrosLoaded:    []rules.MacroID{"sensitive_files", "network_tools"},
+		})
+	})
+}
+
+func TestAbnormalPathEventEstimatedSize(t *testing.T) {
+	now := utils.NewEasyjsonTime(time.Now())
+	inode, mode, mountID := uint64(1234567), uint32(0o100644), uint32(42)
+
+	process := &ProcessSerializer{
+		Pid:      4242,
+		PPid:     1,
+		Tid:      4242,
+		UID:      1000,
+		GID:      1000,
+		User:     "vagrant",
+		Group:    "vagrant",
+		Comm:     "cat",
+		TTY:      "pts/0",
+		ForkTime: &now,
+		ExecTime: &now,
+		Executable: &FileSerializer{
+			Path:       "/usr/bin/cat",
+			Name:       "cat",
+			Inode:      &inode,
+			Mode:       &mode,
+			MountID:    &mountID,
+			Filesystem: "ext4",
+			User:       "root",
+			Group:      "root",
+			Mtime:      &now,
+			Ctime:      &now,
+		},
+		Args: []string{"/etc/passwd", "--number"},
+		Envs: []string{"HOME", "PATH", "SHELL"},
+	}
+
+	assertEstimatedSize(t, &AbnormalPathEvent{
+		Timestamp: time.Now(),
+		Event: &EventSerializer{
+			EventContextSerializer: EventContextSerializer{
+				Name:     "open",
+				Category: "File Activity",
+				Outcome:  "Success",
+			},
+			FileEventSerializer: &FileEventSerializer{
+				FileSerializer: FileSerializer{
+					Path:                "/etc/passwd",
+					Name:                "passwd",
+					PathResolutionError: "absolute path resolution error",
+					Inode:               &inode,
+					Mode:                &mode,
+					MountID:             &mountID,
+					Filesystem:          "overlay",
+					User:                "root",
+					Group:               "root",
+					Flags:               []string{"O_RDONLY", "O_CLOEXEC"},
+				},
+			},
+			ProcessContextSerializer: &ProcessContextSerializer{
+				ProcessSerializer: process,
+				Parent:            process,
+				Ancestors:         []*ProcessSerializer{process, process},
+			},
+			Date: now,
+		},
+		PathResolutionError: "absolute path resolution error",
+	})
+}
@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package probe
+
+import (
+	"errors"
+
+	"github.com/mailru/easyjson"
+	"github.com/mailru/easyjson/jwriter"
+)
+
+var errBatchClosed = errors.New("event batch already built, it must be reset before writing new events")
+
+// EventBatchWriter encodes a batch of events as a JSON array, writing the events incrementally
+// instead of marshaling each of them separately. The encoding buffer is reused from one batch to the next.
+type EventBatchWriter struct {
+	w      jwriter.Writer
+	buf    []byte
+	count  int
+	closed bool
+}
+
+// NewEventBatchWriter returns a new, empty, EventBatchWriter
+func NewEventBatchWriter() *EventBatchWriter {
+	return &EventBatchWriter{}
+}
+
+// Len returns the number of events written in the current batch
+func (bw *EventBatchWriter) Len() int {
+	return bw.count
+}
+
+func (bw *EventBatchWriter) write(m easyjson.Marshaler) {
+	if bw.closed {
+		if bw.w.Error == nil {
+			bw.w.Error = errBatchClosed
+		}
+		return
+	}
+
+	if bw.count == 0 {
+		bw.w.RawByte('[')
+	} else {
+		bw.w.RawByte(',')
+	}
+	m.MarshalEasyJSON(&bw.w)
+	bw.count++
+}
+
+// WriteCustomEvent appends a custom event to the batch
+func (bw *EventBatchWriter) WriteCustomEvent(ce *CustomEvent) {
+	bw.write(ce.marshaler)
+}
+
+// WriteRulesetLoaded appends a ruleset_loaded event to the batch
+func (bw *EventBatchWriter) WriteRulesetLoaded(ev RulesetLoadedEvent) {
+	bw.write(ev)
+}
+
+// WriteNoisyProcess appends a noisy_process event to the batch
+func (bw *EventBatchWriter) WriteNoisyProcess(ev NoisyProcessEvent) {
+	bw.write(ev)
+}
+
+// WriteEventLostRead appends a lost_events_read event to the batch
+func (bw *EventBatchWriter) WriteEventLostRead(ev EventLostRead) {
+	bw.write(ev)
+}
+
+// WriteEventLostWrite appends a lost_events_write event to the batch
+func (bw *EventBatchWriter) WriteEventLostWrite(ev EventLostWrite) {
+	bw.write(ev)
+}
+
+// WriteAbnormalPath appends an abnormal_path event to the batch
+func (bw *EventBatchWriter) WriteAbnormalPath(ev AbnormalPathEvent) {
+	bw.write(ev)
+}
+
+// WriteSelfTest appends a self_test event to the batch
+func (bw *EventBatchWriter) WriteSelfTest(ev SelfTestEvent) {
+	bw.write(ev)
+}
+
+// Bytes closes the batch and returns its JSON encoding. The returned slice is only valid until the writer is reset,
+// and no event can be written to the batch until then.
+func (bw *EventBatchWriter) Bytes() ([]byte, error) {
+	if bw.w.Error != nil {
+		return nil, bw.w.Error
+	}
+	if bw.closed {
+		return bw.buf, nil
+	}
+
+	if bw.count == 0 {
+		bw.w.RawByte('[')
+	}
+	bw.w.RawByte(']')
+
+	data, err := bw.w.BuildBytes()
+	if err != nil {
+		return nil, err
+	}
+	bw.buf = data
+	bw.closed = true
+	return data, nil
+}
+
+// Reset discards the current batch so that the writer can be used for a new one, reusing its buffer
+func (bw *EventBatchWriter) Reset() {
+	bw.w = jwriter.Writer{}
+	bw.w.Buffer.Buf = bw.buf[:0]
+	bw.count = 0
+	bw.closed = false
+}
@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package probe
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mailru/easyjson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBatchWriter(t *testing.T) {
+	timestamp := time.Date(2022, 5, 4, 10, 11, 12, 0, time.UTC)
+
+	ruleset := RulesetLoadedEvent{
+		Timestamp: timestamp,
+		PoliciesLoaded: []*PolicyLoaded{
+			{
+				Version:     "1.0",
+				RulesLoaded: []*RuleLoaded{{ID: "rule_a", Expression: `open.file.path == "/etc/passwd"`}},
+			},
+		},
+		MacrosLoaded: []string{"macro_a"},
+	}
+	noisy := NoisyProcessEvent{
+		Timestamp:      timestamp,
+		Count:          1000,
+		Threshold:      500,
+		ControlPeriod:  2 * time.Second,
+		DiscardedUntil: timestamp.Add(10 * time.Second),
+		Pid:            42,
+		Comm:           "noisy",
+	}
+	lost := EventLostWrite{
+		Timestamp: timestamp,
+		Name:      "events",
+		Lost:      map[string]uint64{"open": 12, "exec": 3},
+	}
+
+	bw := NewEventBatchWriter()
+	bw.WriteRulesetLoaded(ruleset)
+	bw.WriteNoisyProcess(noisy)
+	bw.WriteEventLostWrite(lost)
+	assert.Equal(t, 3, bw.Len())
+
+	data, err := bw.Bytes()
+	require.NoError(t, err)
+
+	var batch []json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &batch))
+	require.Len(t, batch, 3)
+
+	var decodedRuleset RulesetLoadedEvent
+	require.NoError(t, easyjson.Unmarshal(batch[0], &decodedRuleset))
+	assert.True(t, timestamp.Equal(decodedRuleset.Timestamp))
+	require.Len(t, decodedRuleset.PoliciesLoaded, 1)
+	assert.Equal(t, ruleset.PoliciesLoaded[0], decodedRuleset.PoliciesLoaded[0])
+	assert.Equal(t, ruleset.MacrosLoaded, decodedRuleset.MacrosLoaded)
+
+	var decodedNoisy NoisyProcessEvent
+	require.NoError(t, easyjson.Unmarshal(batch[1], &decodedNoisy))
+	assert.Equal(t, noisy.Count, decodedNoisy.Count)
+	assert.Equal(t, noisy.ControlPeriod, decodedNoisy.ControlPeriod)
+	assert.Equal(t, noisy.Comm, decodedNoisy.Comm)
+	assert.True(t, noisy.DiscardedUntil.Equal(decodedNoisy.DiscardedUntil))
+
+	var decodedLost EventLostWrite
+	require.NoError(t, easyjson.Unmarshal(batch[2], &decodedLost))
+	assert.Equal(t, lost.Name, decodedLost.Name)
+	assert.Equal(t, lost.Lost, decodedLost.Lost)
+
+	t.Run("closed", func(t *testing.T) {
+		again, err := bw.Bytes()
+		require.NoError(t, err)
+		assert.Equal(t, data, again)
+
+		bw.WriteNoisyProcess(noisy)
+		_, err = bw.Bytes()
+		assert.Error(t, err)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		bw.Reset()
+		assert.Equal(t, 0, bw.Len())
+
+		data, err := bw.Bytes()
+		require.NoError(t, err)
+		assert.Equal(t, "[]", string(data))
+
+		bw.Reset()
+		bw.WriteEventLostRead(EventLostRead{Timestamp: timestamp, Name: "events", Lost: 1})
+		data, err = bw.Bytes()
+		require.NoError(t, err)
+		assert.Equal(t, `[{"date":"2022-05-04T10:11:12Z","map":"events","lost":1}]`, string(data))
+	})
+}
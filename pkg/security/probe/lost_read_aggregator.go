@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package probe
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultLostReadSamples is the number of samples kept per map when none is specified
+const defaultLostReadSamples = 60
+
+type lostReadSample struct {
+	timestamp time.Time
+	lost      float64
+}
+
+// lostReadRing is a bounded ring of the most recent samples of a map
+type lostReadRing struct {
+	samples []lostReadSample
+	start   int
+}
+
+func (r *lostReadRing) add(sample lostReadSample, maxSamples int) {
+	if len(r.samples) < maxSamples {
+		r.samples = append(r.samples, sample)
+		return
+	}
+	r.samples[r.start] = sample
+	r.start = (r.start + 1) % len(r.samples)
+}
+
+// at returns the i-th oldest sample of the ring
+func (r *lostReadRing) at(i int) lostReadSample {
+	return r.samples[(r.start+i)%len(r.samples)]
+}
+
+// LostReadAggregator aggregates the EventLostRead events of each map over a sliding window of recent samples, so
+// that sustained loss can be told apart from single spikes
+type LostReadAggregator struct {
+	sync.Mutex
+	maxSamples int
+	rings      map[string]*lostReadRing
+}
+
+// NewLostReadAggregator returns a new LostReadAggregator keeping at most maxSamples samples per map
+func NewLostReadAggregator(maxSamples int) *LostReadAggregator {
+	if maxSamples <= 0 {
+		maxSamples = defaultLostReadSamples
+	}
+
+	return &LostReadAggregator{
+		maxSamples: maxSamples,
+		rings:      make(map[string]*lostReadRing),
+	}
+}
+
+// Add records the number of events lost by a map at the timestamp of the event
+func (a *LostReadAggregator) Add(e EventLostRead) {
+	a.Lock()
+	defer a.Unlock()
+
+	ring, ok := a.rings[e.Name]
+	if !ok {
+		ring = &lostReadRing{}
+		a.rings[e.Name] = ring
+	}
+	ring.add(lostReadSample{timestamp: e.Timestamp, lost: e.Lost}, a.maxSamples)
+}
+
+// RatePerSecond returns the number of events lost per second by the given map over the window of recent samples.
+// The events reported by the oldest sample were lost before the window started, and are not accounted for. It
+// returns 0 if less than two samples spanning a positive duration were recorded.
+func (a *LostReadAggregator) RatePerSecond(name string) float64 {
+	a.Lock()
+	defer a.Unlock()
+
+	ring, ok := a.rings[name]
+	if !ok || len(ring.samples) < 2 {
+		return 0
+	}
+
+	oldest, newest := ring.at(0), ring.at(len(ring.samples)-1)
+	span := newest.timestamp.Sub(oldest.timestamp).Seconds()
+	if span <= 0 {
+		return 0
+	}
+
+	var lost float64
+	for i := 1; i < len(ring.samples); i++ {
+		lost += ring.at(i).lost
+	}
+	return lost / span
+}
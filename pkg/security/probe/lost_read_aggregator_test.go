@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package probe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLostReadAggregator(t *testing.T) {
+	start := time.Unix(1000, 0)
+	lostRead := func(name string, offset time.Duration, lost float64) EventLostRead {
+		return EventLostRead{Timestamp: start.Add(offset), Name: name, Lost: lost}
+	}
+
+	t.Run("rate", func(t *testing.T) {
+		a := NewLostReadAggregator(10)
+		a.Add(lostRead("events", 0, 100))
+		a.Add(lostRead("events", 10*time.Second, 20))
+		a.Add(lostRead("events", 20*time.Second, 40))
+		a.Add(lostRead("other", 0, 5))
+		a.Add(lostRead("other", 5*time.Second, 5))
+
+		assert.Equal(t, 3.0, a.RatePerSecond("events"))
+		assert.Equal(t, 1.0, a.RatePerSecond("other"))
+		assert.Equal(t, 0.0, a.RatePerSecond("unknown"))
+	})
+
+	t.Run("sliding-window", func(t *testing.T) {
+		a := NewLostReadAggregator(3)
+		a.Add(lostRead("events", 0, 1000))
+		a.Add(lostRead("events", 10*time.Second, 1000))
+		for i := 2; i <= 5; i++ {
+			a.Add(lostRead("events", time.Duration(i)*10*time.Second, 10))
+		}
+
+		// only the samples at 30s, 40s and 50s are kept
+		assert.Equal(t, 1.0, a.RatePerSecond("events"))
+	})
+
+	t.Run("not-enough-samples", func(t *testing.T) {
+		a := NewLostReadAggregator(0)
+		a.Add(lostRead("events", 0, 100))
+		assert.Equal(t, 0.0, a.RatePerSecond("events"))
+
+		a.Add(lostRead("events", 0, 100))
+		assert.Equal(t, 0.0, a.RatePerSecond("events"))
+	})
+}
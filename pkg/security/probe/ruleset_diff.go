@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package probe
+
+import (
+	"sort"
+)
+
+// RulesetDiff describes the changes between two loaded rulesets, as sorted lists of rule IDs
+type RulesetDiff struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+	// Ignored lists the rules ignored by the new ruleset that were not ignored by the old one
+	Ignored []string
+}
+
+// IsEmpty returns whether the two rulesets hold the same rules
+func (d RulesetDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0 && len(d.Ignored) == 0
+}
+
+// DiffRulesets compares the rules of two RulesetLoadedEvent by ID, across all their policies. A rule is modified
+// when its expression or its version changed. A nil event is handled as an empty ruleset.
+func DiffRulesets(old, new *RulesetLoadedEvent) RulesetDiff {
+	var diff RulesetDiff
+
+	oldLoaded, oldIgnored := rulesetRules(old)
+	newLoaded, newIgnored := rulesetRules(new)
+
+	for id, rule := range newLoaded {
+		oldRule, exists := oldLoaded[id]
+		if !exists {
+			diff.Added = append(diff.Added, id)
+		} else if oldRule.Expression != rule.Expression || oldRule.Version != rule.Version {
+			diff.Modified = append(diff.Modified, id)
+		}
+	}
+
+	for id := range oldLoaded {
+		if _, exists := newLoaded[id]; !exists {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+
+	for id := range newIgnored {
+		if !oldIgnored[id] {
+			diff.Ignored = append(diff.Ignored, id)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	sort.Strings(diff.Ignored)
+
+	return diff
+}
+
+// rulesetRules returns the loaded rules of a ruleset indexed by ID, and the set of its ignored rule IDs
+func rulesetRules(e *RulesetLoadedEvent) (map[string]*RuleLoaded, map[string]bool) {
+	loaded := make(map[string]*RuleLoaded)
+	ignored := make(map[string]bool)
+	if e == nil {
+		return loaded, ignored
+	}
+
+	for _, policy := range e.PoliciesLoaded {
+		for _, rule := range policy.RulesLoaded {
+			loaded[rule.ID] = rule
+		}
+		for _, rule := range policy.RulesIgnored {
+			ignored[rule.ID] = true
+		}
+	}
+	return loaded, ignored
+}
@@ -0,0 +1,147 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+// +build linux
+
+package probe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRulesets(t *testing.T) {
+	old := &RulesetLoadedEvent{
+		PoliciesLoaded: []*PolicyLoaded{
+			{
+				RulesLoaded: []*RuleLoaded{
+					{ID: "rule_a", Version: "1", Expression: `open.file.path == "/etc/passwd"`},
+					{ID: "rule_b", Version: "1", Expression: `exec.file.name == "nc"`},
+				},
+				RulesIgnored: []*RuleIgnored{
+					{ID: "rule_x", Reason: "syntax error"},
+				},
+			},
+			{
+				RulesLoaded: []*RuleLoaded{
+					{ID: "rule_c", Version: "1", Expression: `open.file.path == "/etc/shadow"`},
+					{ID: "rule_d", Version: "1", Expression: `exec.file.name == "curl"`},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		new      *RulesetLoadedEvent
+		expected RulesetDiff
+	}{
+		{
+			name: "unchanged",
+			new:  old,
+		},
+		{
+			name: "added",
+			new: &RulesetLoadedEvent{
+				PoliciesLoaded: append(old.PoliciesLoaded, &PolicyLoaded{
+					RulesLoaded: []*RuleLoaded{
+						{ID: "rule_e", Expression: `exec.file.name == "wget"`},
+					},
+				}),
+			},
+			expected: RulesetDiff{Added: []string{"rule_e"}},
+		},
+		{
+			name: "removed",
+			new: &RulesetLoadedEvent{
+				PoliciesLoaded: old.PoliciesLoaded[:1],
+			},
+			expected: RulesetDiff{Removed: []string{"rule_c", "rule_d"}},
+		},
+		{
+			name: "expression-change",
+			new: &RulesetLoadedEvent{
+				PoliciesLoaded: []*PolicyLoaded{
+					old.PoliciesLoaded[0],
+					{
+						RulesLoaded: []*RuleLoaded{
+							{ID: "rule_c", Version: "1", Expression: `open.file.path == "/etc/gshadow"`},
+							{ID: "rule_d", Version: "1", Expression: `exec.file.name == "curl"`},
+						},
+					},
+				},
+			},
+			expected: RulesetDiff{Modified: []string{"rule_c"}},
+		},
+		{
+			name: "version-change",
+			new: &RulesetLoadedEvent{
+				PoliciesLoaded: []*PolicyLoaded{
+					old.PoliciesLoaded[0],
+					{
+						RulesLoaded: []*RuleLoaded{
+							{ID: "rule_c", Version: "1", Expression: `open.file.path == "/etc/shadow"`},
+							{ID: "rule_d", Version: "2", Expression: `exec.file.name == "curl"`},
+						},
+					},
+				},
+			},
+			expected: RulesetDiff{Modified: []string{"rule_d"}},
+		},
+		{
+			name: "moved-across-policies",
+			new: &RulesetLoadedEvent{
+				PoliciesLoaded: []*PolicyLoaded{
+					{
+						RulesLoaded: append(old.PoliciesLoaded[0].RulesLoaded, old.PoliciesLoaded[1].RulesLoaded...),
+						RulesIgnored: []*RuleIgnored{
+							{ID: "rule_x", Reason: "syntax error"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "ignored",
+			new: &RulesetLoadedEvent{
+				PoliciesLoaded: []*PolicyLoaded{
+					{
+						RulesLoaded: old.PoliciesLoaded[0].RulesLoaded,
+						RulesIgnored: []*RuleIgnored{
+							{ID: "rule_x", Reason: "syntax error"},
+						},
+					},
+					{
+						RulesLoaded: old.PoliciesLoaded[1].RulesLoaded[:1],
+						RulesIgnored: []*RuleIgnored{
+							{ID: "rule_d", Reason: "unknown field"},
+						},
+					},
+				},
+			},
+			expected: RulesetDiff{Removed: []string{"rule_d"}, Ignored: []string{"rule_d"}},
+		},
+		{
+			name:     "nil",
+			new:      nil,
+			expected: RulesetDiff{Removed: []string{"rule_a", "rule_b", "rule_c", "rule_d"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := DiffRulesets(old, test.new)
+			assert.Equal(t, test.expected, diff)
+			assert.Equal(t, test.expected.IsEmpty(), diff.IsEmpty())
+		})
+	}
+
+	t.Run("from-nil", func(t *testing.T) {
+		diff := DiffRulesets(nil, old)
+		assert.Equal(t, RulesetDiff{Added: []string{"rule_a", "rule_b", "rule_c", "rule_d"}, Ignored: []string{"rule_x"}}, diff)
+	})
+}
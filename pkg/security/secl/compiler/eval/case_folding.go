@@ -0,0 +1,151 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package eval
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// CaseFolding defines the strategy used to fold case during case insensitive comparisons
+type CaseFolding int
+
+const (
+	// ASCIICaseFolding only folds ASCII letters, non ASCII characters have to match exactly
+	ASCIICaseFolding CaseFolding = iota
+	// UnicodeCaseFolding folds all the Unicode letters, at a higher cost
+	UnicodeCaseFolding
+	// UnicodeNFCCaseFolding folds all the Unicode letters once normalized to NFC, so that
+	// precomposed and decomposed accented characters match, at an even higher cost
+	UnicodeNFCCaseFolding
+)
+
+var (
+	caseFoldingsLock sync.RWMutex
+	caseFoldings     = make(map[Field]CaseFolding)
+)
+
+// RegisterCaseFolding selects the case folding strategy used by the case insensitive comparisons of a field
+func RegisterCaseFolding(field Field, folding CaseFolding) {
+	caseFoldingsLock.Lock()
+	defer caseFoldingsLock.Unlock()
+
+	caseFoldings[field] = folding
+}
+
+// GetCaseFolding returns the case folding strategy registered for a field, ASCIICaseFolding if none
+func GetCaseFolding(field Field) CaseFolding {
+	caseFoldingsLock.RLock()
+	defer caseFoldingsLock.RUnlock()
+
+	return caseFoldings[field]
+}
+
+// fieldStringCmpOpts returns the comparison options of a field, with the case folding strategy registered for it
+// unless the options already select a broader one
+func fieldStringCmpOpts(field Field, opts StringCmpOpts) StringCmpOpts {
+	if field != "" {
+		opts.CaseFolding = opts.CaseFolding.broadest(GetCaseFolding(field))
+	}
+	return opts
+}
+
+// fieldsCaseFolding returns the case folding strategy to use when comparing fields together,
+// the broadest of the strategies registered for the fields being selected
+func fieldsCaseFolding(fields ...Field) CaseFolding {
+	var folding CaseFolding
+	for _, field := range fields {
+		folding = folding.broadest(GetCaseFolding(field))
+	}
+	return folding
+}
+
+// broadest returns the strategy folding the most characters among c and others
+func (c CaseFolding) broadest(others ...CaseFolding) CaseFolding {
+	for _, other := range others {
+		if other > c {
+			c = other
+		}
+	}
+	return c
+}
+
+// equalFoldFnc returns the case insensitive equality function of the strategy
+func (c CaseFolding) equalFoldFnc() func(a, b string) bool {
+	switch c {
+	case UnicodeNFCCaseFolding:
+		return equalFoldNFC
+	case UnicodeCaseFolding:
+		return strings.EqualFold
+	}
+	return equalFoldASCII
+}
+
+// toLowerFnc returns the lower case function of the strategy. With UnicodeNFCCaseFolding,
+// the strings are expected to be normalized beforehand, see normalize
+func (c CaseFolding) toLowerFnc() func(s string) string {
+	if c == UnicodeCaseFolding || c == UnicodeNFCCaseFolding {
+		return strings.ToLower
+	}
+	return toLowerASCII
+}
+
+// normalize returns the string in the normal form expected by the strategy
+func (c CaseFolding) normalize(s string) string {
+	if c == UnicodeNFCCaseFolding {
+		return norm.NFC.String(s)
+	}
+	return s
+}
+
+// fold returns the string normalized and lower cased by the strategy
+func (c CaseFolding) fold(s string) string {
+	return c.toLowerFnc()(c.normalize(s))
+}
+
+// foldedCacheKey returns the key of the folded value of a field in the cache of the context
+func foldedCacheKey(field Field, folding CaseFolding) string {
+	return "eval.folded." + field + "." + strconv.Itoa(int(folding))
+}
+
+func equalFoldNFC(a, b string) bool {
+	return strings.EqualFold(norm.NFC.String(a), norm.NFC.String(b))
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func equalFoldASCII(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] && lowerASCII(a[i]) != lowerASCII(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func toLowerASCII(s string) string {
+	for i := 0; i < len(s); i++ {
+		if 'A' <= s[i] && s[i] <= 'Z' {
+			b := []byte(s)
+			for j := i; j < len(b); j++ {
+				b[j] = lowerASCII(b[j])
+			}
+			return string(b)
+		}
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package eval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaseFolding(t *testing.T) {
+	RegisterCaseFolding("process.title", UnicodeCaseFolding)
+	RegisterCaseFolding("file.path", ASCIICaseFolding)
+	t.Cleanup(func() {
+		caseFoldingsLock.Lock()
+		delete(caseFoldings, "process.title")
+		delete(caseFoldings, "file.path")
+		caseFoldingsLock.Unlock()
+	})
+
+	assert.Equal(t, UnicodeCaseFolding, GetCaseFolding("process.title"))
+	assert.Equal(t, ASCIICaseFolding, GetCaseFolding("file.path"))
+	assert.Equal(t, ASCIICaseFolding, GetCaseFolding("unregistered.field"))
+
+	equals := func(t *testing.T, field Field, value string, fieldValue string) bool {
+		a := &StringEvaluator{
+			Value:     value,
+			ValueType: ScalarValueType,
+		}
+
+		b := &StringEvaluator{
+			Field: field,
+			EvalFnc: func(ctx *Context) string {
+				return fieldValue
+			},
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := DNSNameCmp.StringEquals(a, b, state)
+		assert.NoError(t, err)
+		return e.Eval(&ctx).(bool)
+	}
+
+	t.Run("unicode-field", func(t *testing.T) {
+		assert.True(t, equals(t, "process.title", "ÉCOLE", "école"))
+		assert.True(t, equals(t, "process.title", "FOO", "foo"))
+	})
+
+	t.Run("ascii-field", func(t *testing.T) {
+		assert.False(t, equals(t, "file.path", "ÉCOLE", "école"))
+		assert.True(t, equals(t, "file.path", "/ÉCOLE/FOO", "/École/foo"))
+	})
+
+	t.Run("default", func(t *testing.T) {
+		assert.False(t, equals(t, "unregistered.field", "ÉCOLE", "école"))
+		assert.True(t, equals(t, "unregistered.field", "FOO", "foo"))
+	})
+
+	t.Run("pattern", func(t *testing.T) {
+		matcher, err := NewStringMatcher(PatternValueType, "*ÉCOLE*", fieldStringCmpOpts("process.title", StringCmpOpts{PatternCaseInsensitive: true}))
+		assert.NoError(t, err)
+		assert.True(t, matcher.Matches("/école/"))
+
+		matcher, err = NewStringMatcher(PatternValueType, "*ÉCOLE*", fieldStringCmpOpts("file.path", StringCmpOpts{PatternCaseInsensitive: true}))
+		assert.NoError(t, err)
+		assert.False(t, matcher.Matches("/école/"))
+		assert.True(t, matcher.Matches("/ÉcolE/"))
+	})
+}
+
+func TestEqualFoldASCII(t *testing.T) {
+	assert.True(t, equalFoldASCII("FoO", "fOo"))
+	assert.True(t, equalFoldASCII("", ""))
+	assert.False(t, equalFoldASCII("foo", "fooo"))
+	assert.False(t, equalFoldASCII("É", "é"))
+	assert.Equal(t, "/école/foo", toLowerASCII("/école/FOO"))
+}
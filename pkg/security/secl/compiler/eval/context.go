@@ -48,6 +48,29 @@ func (c *Context) Reset() {
 	}
 }
 
+// foldedCacheEntry is the value of a field, with its case folded, cached in a context
+type foldedCacheEntry struct {
+	value  string
+	folded string
+}
+
+// foldedValue returns the value folded with the given strategy, caching it so that the rules comparing the same
+// field case insensitively during the evaluation of an event don't fold it again
+func (c *Context) foldedValue(key string, value string, folding CaseFolding) string {
+	if ptr := c.Cache[key]; ptr != nil {
+		if fv := (*foldedCacheEntry)(ptr); fv.value == value {
+			return fv.folded
+		}
+	}
+
+	fv := &foldedCacheEntry{value: value, folded: folding.fold(value)}
+	if c.Cache == nil {
+		c.Cache = make(map[string]unsafe.Pointer)
+	}
+	c.Cache[key] = unsafe.Pointer(fv)
+	return fv.folded
+}
+
 // NewContext return a new Context
 func NewContext(obj unsafe.Pointer) *Context {
 	return &Context{
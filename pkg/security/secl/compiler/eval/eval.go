@@ -332,6 +332,26 @@ func StringEqualsWrapper(a *StringEvaluator, b *StringEvaluator, state *State) (
 	return evaluator, nil
 }
 
+// StringPrefixWrapper makes use of operator overrides
+func StringPrefixWrapper(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error) {
+	if a.OpOverrides != nil && a.OpOverrides.StringPrefix != nil {
+		return a.OpOverrides.StringPrefix(a, b, state)
+	} else if b.OpOverrides != nil && b.OpOverrides.StringPrefix != nil {
+		return b.OpOverrides.StringPrefix(a, b, state)
+	}
+	return StringPrefix(a, b, state)
+}
+
+// StringSuffixWrapper makes use of operator overrides
+func StringSuffixWrapper(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error) {
+	if a.OpOverrides != nil && a.OpOverrides.StringSuffix != nil {
+		return a.OpOverrides.StringSuffix(a, b, state)
+	} else if b.OpOverrides != nil && b.OpOverrides.StringSuffix != nil {
+		return b.OpOverrides.StringSuffix(a, b, state)
+	}
+	return StringSuffix(a, b, state)
+}
+
 // StringArrayContainsWrapper makes use of operator overrides
 func StringArrayContainsWrapper(a *StringEvaluator, b *StringArrayEvaluator, state *State) (*BoolEvaluator, error) {
 	var evaluator *BoolEvaluator
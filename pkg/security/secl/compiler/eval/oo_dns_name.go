@@ -12,9 +12,11 @@ var (
 			if a.Field != "" {
 				a.StringCmpOpts.ScalarCaseInsensitive = true
 				a.StringCmpOpts.PatternCaseInsensitive = true
+				a.StringCmpOpts.RegexpCaseInsensitive = true
 			} else if b.Field != "" {
 				b.StringCmpOpts.ScalarCaseInsensitive = true
 				b.StringCmpOpts.PatternCaseInsensitive = true
+				b.StringCmpOpts.RegexpCaseInsensitive = true
 			}
 
 			return StringEquals(a, b, state)
@@ -46,5 +48,23 @@ var (
 
 			return StringArrayMatches(a, b, state)
 		},
+		StringPrefix: func(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error) {
+			if a.Field != "" {
+				a.StringCmpOpts.ScalarCaseInsensitive = true
+			} else if b.Field != "" {
+				b.StringCmpOpts.ScalarCaseInsensitive = true
+			}
+
+			return StringPrefix(a, b, state)
+		},
+		StringSuffix: func(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error) {
+			if a.Field != "" {
+				a.StringCmpOpts.ScalarCaseInsensitive = true
+			} else if b.Field != "" {
+				b.StringCmpOpts.ScalarCaseInsensitive = true
+			}
+
+			return StringSuffix(a, b, state)
+		},
 	}
 )
@@ -6,6 +6,7 @@
 package eval
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -105,11 +106,54 @@ func TestLowerCaseEquals(t *testing.T) {
 
 		e, err := DNSNameCmp.StringEquals(a, b, state)
 		assert.Empty(t, err)
-		assert.False(t, e.Eval(&ctx).(bool))
+		assert.True(t, e.Eval(&ctx).(bool))
 
 		e, err = DNSNameCmp.StringEquals(b, a, state)
 		assert.Empty(t, err)
-		assert.False(t, e.Eval(&ctx).(bool))
+		assert.True(t, e.Eval(&ctx).(bool))
+	})
+
+	t.Run("mixed-case", func(t *testing.T) {
+		tests := []struct {
+			value     string
+			valueType FieldValueType
+			expected  bool
+		}{
+			{value: "Fo*", valueType: PatternValueType, expected: true},
+			{value: "fO*", valueType: PatternValueType, expected: true},
+			{value: "Fa*", valueType: PatternValueType, expected: false},
+			{value: "Fo.*", valueType: RegexpValueType, expected: true},
+			{value: "fO.*", valueType: RegexpValueType, expected: true},
+			{value: "fO\\D", valueType: RegexpValueType, expected: true},
+			{value: "fO\\d", valueType: RegexpValueType, expected: false},
+		}
+
+		for _, test := range tests {
+			t.Run(test.value, func(t *testing.T) {
+				a := &StringEvaluator{
+					Value:     test.value,
+					ValueType: test.valueType,
+				}
+
+				b := &StringEvaluator{
+					Field: "field",
+					EvalFnc: func(ctx *Context) string {
+						return "foo"
+					},
+				}
+
+				var ctx Context
+				state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+				e, err := DNSNameCmp.StringEquals(a, b, state)
+				assert.Empty(t, err)
+				assert.Equal(t, test.expected, e.Eval(&ctx).(bool))
+
+				e, err = DNSNameCmp.StringEquals(b, a, state)
+				assert.Empty(t, err)
+				assert.Equal(t, test.expected, e.Eval(&ctx).(bool))
+			})
+		}
 	})
 }
 
@@ -320,6 +364,142 @@ func TestLowerCaseArrayContains(t *testing.T) {
 	})
 }
 
+func TestLowerCaseAffix(t *testing.T) {
+	scalar := func(value string) *StringEvaluator {
+		return &StringEvaluator{
+			Value:     value,
+			ValueType: ScalarValueType,
+		}
+	}
+
+	field := func(value string) *StringEvaluator {
+		return &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return value
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		op       func(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error)
+		a        *StringEvaluator
+		b        *StringEvaluator
+		expected bool
+	}{
+		{name: "prefix-scalar", op: DNSNameCmp.StringPrefix, a: field("WWW.Example.COM"), b: scalar("www.EXAMPLE"), expected: true},
+		{name: "prefix-scalar-no-match", op: DNSNameCmp.StringPrefix, a: field("WWW.Example.COM"), b: scalar("example"), expected: false},
+		{name: "prefix-scalar-field", op: DNSNameCmp.StringPrefix, a: scalar("WWW.Example.COM"), b: field("www"), expected: true},
+		{name: "prefix-eval", op: DNSNameCmp.StringPrefix, a: field("WWW.Example.COM"), b: field("www.example"), expected: true},
+		{name: "prefix-eval-no-match", op: DNSNameCmp.StringPrefix, a: field("WWW.Example.COM"), b: field("www.datadog"), expected: false},
+		{name: "suffix-scalar", op: DNSNameCmp.StringSuffix, a: field("WWW.Example.COM"), b: scalar(".example.com"), expected: true},
+		{name: "suffix-scalar-no-match", op: DNSNameCmp.StringSuffix, a: field("WWW.Example.COM"), b: scalar(".example.org"), expected: false},
+		{name: "suffix-scalar-field", op: DNSNameCmp.StringSuffix, a: scalar("WWW.Example.COM"), b: field(".COM"), expected: true},
+		{name: "suffix-eval", op: DNSNameCmp.StringSuffix, a: field("WWW.Example.COM"), b: field("EXAMPLE.com"), expected: true},
+		{name: "suffix-eval-no-match", op: DNSNameCmp.StringSuffix, a: field("WWW.Example.COM"), b: field("www"), expected: false},
+		{name: "prefix-case-sensitive", op: StringPrefix, a: field("WWW.Example.COM"), b: scalar("www"), expected: false},
+		{name: "suffix-case-sensitive", op: StringSuffix, a: field("WWW.Example.COM"), b: scalar(".COM"), expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ctx Context
+			state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+			e, err := test.op(test.a, test.b, state)
+			assert.Empty(t, err)
+			assert.Equal(t, test.expected, e.Eval(&ctx).(bool))
+		})
+	}
+}
+
+func TestLowerCaseContainsFoldedCache(t *testing.T) {
+	value := "WWW.Example.COM"
+	a := &StringEvaluator{
+		Field: "field",
+		EvalFnc: func(ctx *Context) string {
+			return value
+		},
+	}
+
+	newValues := func(patterns ...string) *StringValuesEvaluator {
+		var values StringValues
+		for _, pattern := range patterns {
+			values.AppendFieldValue(FieldValue{Value: pattern, Type: PatternValueType})
+		}
+		return &StringValuesEvaluator{Values: values}
+	}
+
+	state := NewState(&testModel{}, "", nil, nilReplCtx())
+	noMatch, err := DNSNameCmp.StringValuesContains(a, newValues("*.datadoghq.com", "*.org"), state)
+	assert.NoError(t, err)
+	match, err := DNSNameCmp.StringValuesContains(a, newValues("*.foo.com", "www.example.*"), state)
+	assert.NoError(t, err)
+
+	ctx := NewContext(nil)
+	assert.False(t, noMatch.Eval(ctx).(bool))
+	assert.True(t, match.Eval(ctx).(bool))
+	assert.Len(t, ctx.Cache, 1)
+
+	// the cached value is only used for the value it was folded from
+	value = "api.datadoghq.COM"
+	assert.True(t, noMatch.Eval(ctx).(bool))
+	assert.False(t, match.Eval(ctx).(bool))
+
+	ctx.Reset()
+	assert.Empty(t, ctx.Cache)
+}
+
+func BenchmarkLowerCaseContains(b *testing.B) {
+	a := &StringEvaluator{
+		Field: "field",
+		EvalFnc: func(ctx *Context) string {
+			return "WWW.EXAMPLE.COM"
+		},
+	}
+
+	state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+	var evaluators []*BoolEvaluator
+	var valuesList []*StringValues
+	for i := 0; i < 10; i++ {
+		var values StringValues
+		values.AppendFieldValue(FieldValue{Value: fmt.Sprintf("*.domain%d.com", i), Type: PatternValueType})
+		values.AppendFieldValue(FieldValue{Value: fmt.Sprintf("www.domain%d.*", i), Type: PatternValueType})
+
+		valuesEvaluator := &StringValuesEvaluator{Values: values}
+		evaluator, err := DNSNameCmp.StringValuesContains(a, valuesEvaluator, state)
+		if err != nil {
+			b.Fatal(err)
+		}
+		evaluators = append(evaluators, evaluator)
+		valuesList = append(valuesList, &valuesEvaluator.Values)
+	}
+
+	ctx := NewContext(nil)
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, evaluator := range evaluators {
+				evaluator.Eval(ctx)
+			}
+			ctx.Reset()
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, values := range valuesList {
+				values.Matches(a.EvalFnc(ctx))
+			}
+			ctx.Reset()
+		}
+	})
+}
+
 func nilReplCtx() ReplacementContext {
 	return ReplacementContext{
 		Opts:       nil,
@@ -0,0 +1,70 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package eval
+
+// foldCmpOpts makes the comparisons of a field case insensitive, using Unicode case folding after NFC normalization
+func foldCmpOpts(opts *StringCmpOpts) {
+	opts.ScalarCaseInsensitive = true
+	opts.PatternCaseInsensitive = true
+	opts.CaseFolding = UnicodeNFCCaseFolding
+}
+
+var (
+	// FoldCmp folds the case of values before comparing, like DNSNameCmp, but for all the Unicode letters and
+	// regardless of the normalization form of accented characters. Important : this operator override doesn't support approvers
+	FoldCmp = &OpOverrides{
+		StringEquals: func(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error) {
+			if a.Field != "" {
+				foldCmpOpts(&a.StringCmpOpts)
+			} else if b.Field != "" {
+				foldCmpOpts(&b.StringCmpOpts)
+			}
+
+			return StringEquals(a, b, state)
+		},
+		StringValuesContains: func(a *StringEvaluator, b *StringValuesEvaluator, state *State) (*BoolEvaluator, error) {
+			if a.Field != "" {
+				foldCmpOpts(&a.StringCmpOpts)
+			}
+
+			return StringValuesContains(a, b, state)
+		},
+		StringArrayContains: func(a *StringEvaluator, b *StringArrayEvaluator, state *State) (*BoolEvaluator, error) {
+			if a.Field != "" {
+				foldCmpOpts(&a.StringCmpOpts)
+			} else if b.Field != "" {
+				foldCmpOpts(&b.StringCmpOpts)
+			}
+
+			return StringArrayContains(a, b, state)
+		},
+		StringArrayMatches: func(a *StringArrayEvaluator, b *StringValuesEvaluator, state *State) (*BoolEvaluator, error) {
+			if a.Field != "" {
+				foldCmpOpts(&a.StringCmpOpts)
+			}
+
+			return StringArrayMatches(a, b, state)
+		},
+		StringPrefix: func(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error) {
+			if a.Field != "" {
+				foldCmpOpts(&a.StringCmpOpts)
+			} else if b.Field != "" {
+				foldCmpOpts(&b.StringCmpOpts)
+			}
+
+			return StringPrefix(a, b, state)
+		},
+		StringSuffix: func(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error) {
+			if a.Field != "" {
+				foldCmpOpts(&a.StringCmpOpts)
+			} else if b.Field != "" {
+				foldCmpOpts(&b.StringCmpOpts)
+			}
+
+			return StringSuffix(a, b, state)
+		},
+	}
+)
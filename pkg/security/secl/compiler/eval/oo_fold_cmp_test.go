@@ -0,0 +1,329 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package eval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// precomposed upper case É
+	cafeUpperNFC = "CAFÉ"
+	// e followed by a combining acute accent
+	cafeLowerNFD = "café"
+)
+
+func TestFoldEquals(t *testing.T) {
+	t.Run("no-match", func(t *testing.T) {
+		a := &StringEvaluator{
+			Value:     "CAFE",
+			ValueType: ScalarValueType,
+		}
+
+		b := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return cafeLowerNFD
+			},
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringEquals(a, b, state)
+		assert.Empty(t, err)
+		assert.False(t, e.Eval(&ctx).(bool))
+
+		e, err = FoldCmp.StringEquals(b, a, state)
+		assert.Empty(t, err)
+		assert.False(t, e.Eval(&ctx).(bool))
+	})
+
+	t.Run("scalar", func(t *testing.T) {
+		a := &StringEvaluator{
+			Value:     cafeUpperNFC,
+			ValueType: ScalarValueType,
+		}
+
+		b := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return cafeLowerNFD
+			},
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringEquals(a, b, state)
+		assert.Empty(t, err)
+		assert.True(t, e.Eval(&ctx).(bool))
+
+		e, err = FoldCmp.StringEquals(b, a, state)
+		assert.Empty(t, err)
+		assert.True(t, e.Eval(&ctx).(bool))
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		a := &StringEvaluator{
+			Value:     "CAFÉ*",
+			ValueType: PatternValueType,
+		}
+
+		b := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return cafeLowerNFD + "-bar"
+			},
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringEquals(a, b, state)
+		assert.Empty(t, err)
+		assert.True(t, e.Eval(&ctx).(bool))
+
+		e, err = FoldCmp.StringEquals(b, a, state)
+		assert.Empty(t, err)
+		assert.True(t, e.Eval(&ctx).(bool))
+	})
+
+	t.Run("regex", func(t *testing.T) {
+		a := &StringEvaluator{
+			Value:     "CAF.*",
+			ValueType: RegexpValueType,
+		}
+
+		b := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return cafeLowerNFD
+			},
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringEquals(a, b, state)
+		assert.Empty(t, err)
+		assert.False(t, e.Eval(&ctx).(bool))
+
+		e, err = FoldCmp.StringEquals(b, a, state)
+		assert.Empty(t, err)
+		assert.False(t, e.Eval(&ctx).(bool))
+	})
+}
+
+func TestFoldContains(t *testing.T) {
+	t.Run("no-match", func(t *testing.T) {
+		a := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return "CAFE"
+			},
+		}
+
+		var values StringValues
+		values.AppendFieldValue(FieldValue{Value: "aaa", Type: ScalarValueType})
+		values.AppendFieldValue(FieldValue{Value: cafeLowerNFD, Type: ScalarValueType})
+
+		b := &StringValuesEvaluator{
+			Values: values,
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringValuesContains(a, b, state)
+		assert.Empty(t, err)
+		assert.False(t, e.Eval(&ctx).(bool))
+	})
+
+	t.Run("scalar", func(t *testing.T) {
+		a := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return cafeUpperNFC
+			},
+		}
+
+		var values StringValues
+		values.AppendFieldValue(FieldValue{Value: "aaa", Type: ScalarValueType})
+		values.AppendFieldValue(FieldValue{Value: cafeLowerNFD, Type: ScalarValueType})
+
+		b := &StringValuesEvaluator{
+			Values: values,
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringValuesContains(a, b, state)
+		assert.Empty(t, err)
+		assert.True(t, e.Eval(&ctx).(bool))
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		a := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return cafeLowerNFD + "-bar"
+			},
+		}
+
+		var values StringValues
+		values.AppendFieldValue(FieldValue{Value: "aaa", Type: ScalarValueType})
+		values.AppendFieldValue(FieldValue{Value: cafeUpperNFC + "*", Type: PatternValueType})
+
+		b := &StringValuesEvaluator{
+			Values: values,
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringValuesContains(a, b, state)
+		assert.Empty(t, err)
+		assert.True(t, e.Eval(&ctx).(bool))
+	})
+
+	t.Run("regex", func(t *testing.T) {
+		a := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return "cafe"
+			},
+		}
+
+		var values StringValues
+		values.AppendFieldValue(FieldValue{Value: "aaa", Type: ScalarValueType})
+		values.AppendFieldValue(FieldValue{Value: "CA.*", Type: RegexpValueType})
+
+		b := &StringValuesEvaluator{
+			Values: values,
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringValuesContains(a, b, state)
+		assert.Empty(t, err)
+		assert.False(t, e.Eval(&ctx).(bool))
+
+		values.AppendFieldValue(FieldValue{Value: "[Cc][Aa].*", Type: RegexpValueType})
+
+		b = &StringValuesEvaluator{
+			Values: values,
+		}
+
+		e, err = FoldCmp.StringValuesContains(a, b, state)
+		assert.Empty(t, err)
+		assert.True(t, e.Eval(&ctx).(bool))
+	})
+
+	t.Run("eval", func(t *testing.T) {
+		a := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return cafeUpperNFC
+			},
+		}
+
+		var values StringValues
+		values.AppendFieldValue(FieldValue{Value: "aaa", Type: ScalarValueType})
+		values.AppendFieldValue(FieldValue{Value: "café*", Type: PatternValueType})
+
+		opts := StringCmpOpts{
+			ScalarCaseInsensitive:  true,
+			PatternCaseInsensitive: true,
+			CaseFolding:            UnicodeNFCCaseFolding,
+		}
+
+		if err := values.Compile(opts); err != nil {
+			t.Error(err)
+		}
+
+		b := &StringValuesEvaluator{
+			EvalFnc: func(ctx *Context) *StringValues {
+				return &values
+			},
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringValuesContains(a, b, state)
+		assert.Empty(t, err)
+		assert.True(t, e.Eval(&ctx).(bool))
+	})
+}
+
+func TestFoldArrayContains(t *testing.T) {
+	t.Run("no-match", func(t *testing.T) {
+		a := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return "CAFE"
+			},
+		}
+
+		b := &StringArrayEvaluator{
+			Values: []string{"aaa", cafeLowerNFD},
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringArrayContains(a, b, state)
+		assert.Empty(t, err)
+		assert.False(t, e.Eval(&ctx).(bool))
+	})
+
+	t.Run("scalar", func(t *testing.T) {
+		a := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return cafeUpperNFC
+			},
+		}
+
+		b := &StringArrayEvaluator{
+			Values: []string{"aaa", cafeLowerNFD},
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringArrayContains(a, b, state)
+		assert.Empty(t, err)
+		assert.True(t, e.Eval(&ctx).(bool))
+	})
+
+	t.Run("eval", func(t *testing.T) {
+		a := &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return cafeUpperNFC
+			},
+		}
+		b := &StringArrayEvaluator{
+			Field: "array",
+			EvalFnc: func(ctx *Context) []string {
+				return []string{"aaa", cafeLowerNFD}
+			},
+		}
+
+		var ctx Context
+		state := NewState(&testModel{}, "", nil, nilReplCtx())
+
+		e, err := FoldCmp.StringArrayContains(a, b, state)
+		assert.Empty(t, err)
+		assert.True(t, e.Eval(&ctx).(bool))
+	})
+}
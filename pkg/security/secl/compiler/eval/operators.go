@@ -16,6 +16,8 @@ type OpOverrides struct {
 	StringValuesContains func(a *StringEvaluator, b *StringValuesEvaluator, state *State) (*BoolEvaluator, error)
 	StringArrayContains  func(a *StringEvaluator, b *StringArrayEvaluator, state *State) (*BoolEvaluator, error)
 	StringArrayMatches   func(a *StringArrayEvaluator, b *StringValuesEvaluator, state *State) (*BoolEvaluator, error)
+	StringPrefix         func(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error)
+	StringSuffix         func(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error)
 }
 
 // return whether a arithmetic operation is deterministic
@@ -68,10 +70,10 @@ func StringEquals(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEv
 
 	if a.Field != "" && b.Field != "" {
 		if a.StringCmpOpts.ScalarCaseInsensitive || b.StringCmpOpts.ScalarCaseInsensitive {
-			op = strings.EqualFold
+			op = fieldsCaseFolding(a.Field, b.Field).broadest(a.StringCmpOpts.CaseFolding, b.StringCmpOpts.CaseFolding).equalFoldFnc()
 		}
 	} else if a.Field != "" {
-		matcher, err := b.ToStringMatcher(a.StringCmpOpts)
+		matcher, err := b.ToStringMatcher(fieldStringCmpOpts(a.Field, a.StringCmpOpts))
 		if err != nil {
 			return nil, err
 		}
@@ -82,7 +84,7 @@ func StringEquals(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEv
 			}
 		}
 	} else if b.Field != "" {
-		matcher, err := a.ToStringMatcher(b.StringCmpOpts)
+		matcher, err := a.ToStringMatcher(fieldStringCmpOpts(b.Field, b.StringCmpOpts))
 		if err != nil {
 			return nil, err
 		}
@@ -230,12 +232,12 @@ func StringArrayContains(a *StringEvaluator, b *StringArrayEvaluator, state *Sta
 
 	if a.Field != "" && b.Field != "" {
 		if a.StringCmpOpts.ScalarCaseInsensitive || b.StringCmpOpts.ScalarCaseInsensitive {
-			cmp = strings.EqualFold
+			cmp = fieldsCaseFolding(a.Field, b.Field).broadest(a.StringCmpOpts.CaseFolding, b.StringCmpOpts.CaseFolding).equalFoldFnc()
 		}
 	} else if a.Field != "" && a.StringCmpOpts.ScalarCaseInsensitive {
-		cmp = strings.EqualFold
+		cmp = fieldStringCmpOpts(a.Field, a.StringCmpOpts).CaseFolding.equalFoldFnc()
 	} else if b.Field != "" {
-		matcher, err := a.ToStringMatcher(b.StringCmpOpts)
+		matcher, err := a.ToStringMatcher(fieldStringCmpOpts(b.Field, b.StringCmpOpts))
 		if err != nil {
 			return nil, err
 		}
@@ -316,7 +318,8 @@ func StringArrayContains(a *StringEvaluator, b *StringArrayEvaluator, state *Sta
 func StringValuesContains(a *StringEvaluator, b *StringValuesEvaluator, state *State) (*BoolEvaluator, error) {
 	isDc := isArithmDeterministic(a, b, state)
 
-	if err := b.Compile(a.StringCmpOpts); err != nil {
+	opts := fieldStringCmpOpts(a.Field, a.StringCmpOpts)
+	if err := b.Compile(opts); err != nil {
 		return nil, err
 	}
 
@@ -360,6 +363,14 @@ func StringValuesContains(a *StringEvaluator, b *StringValuesEvaluator, state *S
 			return eb.Matches(ea(ctx))
 		}
 
+		// fold the value of the field once per event for all the rules matching it against case insensitive patterns
+		if a.Field != "" && opts.PatternCaseInsensitive {
+			key := foldedCacheKey(a.Field, opts.CaseFolding)
+			evalFnc = func(ctx *Context) bool {
+				return eb.matchesWithContext(ctx, key, ea(ctx), opts.CaseFolding)
+			}
+		}
+
 		return &BoolEvaluator{
 			EvalFnc:         evalFnc,
 			Weight:          a.Weight + InArrayWeight*len(eb.fieldValues),
@@ -385,7 +396,7 @@ func StringValuesContains(a *StringEvaluator, b *StringValuesEvaluator, state *S
 func StringArrayMatches(a *StringArrayEvaluator, b *StringValuesEvaluator, state *State) (*BoolEvaluator, error) {
 	isDc := isArithmDeterministic(a, b, state)
 
-	if err := b.Compile(a.StringCmpOpts); err != nil {
+	if err := b.Compile(fieldStringCmpOpts(a.Field, a.StringCmpOpts)); err != nil {
 		return nil, err
 	}
 
@@ -894,3 +905,73 @@ func CIDRArrayContains(a *CIDREvaluator, b *CIDRArrayEvaluator, state *State) (*
 		isDeterministic: isDc,
 	}, nil
 }
+
+// StringPrefix evaluates whether a starts with b
+func StringPrefix(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error) {
+	return stringAffix(a, b, state, strings.HasPrefix)
+}
+
+// StringSuffix evaluates whether a ends with b
+func StringSuffix(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error) {
+	return stringAffix(a, b, state, strings.HasSuffix)
+}
+
+// stringAffix evaluates whether a has b as affix, folding the case of both when one of them is case insensitive
+func stringAffix(a *StringEvaluator, b *StringEvaluator, state *State, hasAffix func(s, affix string) bool) (*BoolEvaluator, error) {
+	isDc := isArithmDeterministic(a, b, state)
+
+	fold := func(s string) string {
+		return s
+	}
+	if a.StringCmpOpts.ScalarCaseInsensitive || b.StringCmpOpts.ScalarCaseInsensitive {
+		fold = fieldsCaseFolding(a.Field, b.Field).broadest(a.StringCmpOpts.CaseFolding, b.StringCmpOpts.CaseFolding).fold
+	}
+
+	if a.EvalFnc != nil && b.EvalFnc != nil {
+		ea, eb := a.EvalFnc, b.EvalFnc
+
+		evalFnc := func(ctx *Context) bool {
+			return hasAffix(fold(ea(ctx)), fold(eb(ctx)))
+		}
+
+		return &BoolEvaluator{
+			EvalFnc:         evalFnc,
+			Weight:          a.Weight + b.Weight,
+			isDeterministic: isDc,
+		}, nil
+	}
+
+	if a.EvalFnc == nil && b.EvalFnc == nil {
+		return &BoolEvaluator{
+			Value:           hasAffix(fold(a.Value), fold(b.Value)),
+			Weight:          a.Weight + b.Weight,
+			isDeterministic: isDc,
+		}, nil
+	}
+
+	if a.EvalFnc != nil {
+		ea, eb := a.EvalFnc, fold(b.Value)
+
+		evalFnc := func(ctx *Context) bool {
+			return hasAffix(fold(ea(ctx)), eb)
+		}
+
+		return &BoolEvaluator{
+			EvalFnc:         evalFnc,
+			Weight:          a.Weight,
+			isDeterministic: isDc,
+		}, nil
+	}
+
+	ea, eb := fold(a.Value), b.EvalFnc
+
+	evalFnc := func(ctx *Context) bool {
+		return hasAffix(ea, fold(eb(ctx)))
+	}
+
+	return &BoolEvaluator{
+		EvalFnc:         evalFnc,
+		Weight:          b.Weight,
+		isDeterministic: isDc,
+	}, nil
+}
@@ -18,14 +18,16 @@ func nextSegment(str string) (bool, string, int) {
 		star = true
 	}
 
+	// the segment ends at the next star, or at the end of the pattern
+	end = len(str)
 	for i, c := range str {
 		if c != '*' {
 			if !inSegment {
 				start = i
 				inSegment = true
 			}
-			end = i
 		} else if inSegment {
+			end = i
 			break
 		}
 	}
@@ -34,29 +36,35 @@ func nextSegment(str string) (bool, string, int) {
 		return star, "", 1
 	}
 
-	end++
-
 	return star, str[start:end], end
 }
 
-func index(s, subtr string, caseInsensitive bool) int {
-	if caseInsensitive {
-		s = strings.ToLower(s)
-		subtr = strings.ToLower(subtr)
+func index(s, subtr string, toLower func(string) string) int {
+	if toLower != nil {
+		s = toLower(s)
+		subtr = toLower(subtr)
 	}
 	return strings.Index(s, subtr)
 }
 
-func hasPrefix(s, prefix string, caseInsensitive bool) bool {
-	if caseInsensitive {
-		s = strings.ToLower(s)
-		prefix = strings.ToLower(prefix)
+func hasPrefix(s, prefix string, toLower func(string) string) bool {
+	if toLower != nil {
+		s = toLower(s)
+		prefix = toLower(prefix)
 	}
 	return strings.HasPrefix(s, prefix)
 }
 
-// PatternMatches matches a pattern against a string
+// PatternMatches matches a pattern against a string, using Unicode case folding if case insensitive
 func PatternMatches(pattern string, str string, caseInsensitive bool) bool {
+	if caseInsensitive {
+		return patternMatches(pattern, str, strings.ToLower)
+	}
+	return patternMatches(pattern, str, nil)
+}
+
+// patternMatches matches a pattern against a string, toLower being used to fold case if not nil
+func patternMatches(pattern string, str string, toLower func(string) string) bool {
 	if pattern == "*" {
 		return true
 	}
@@ -68,13 +76,13 @@ func PatternMatches(pattern string, str string, caseInsensitive bool) bool {
 	for len(pattern) > 0 {
 		star, segment, nextIndex := nextSegment(pattern)
 		if star {
-			index := index(str, segment, caseInsensitive)
+			index := index(str, segment, toLower)
 			if index == -1 {
 				return false
 			}
 			str = str[index+len(segment):]
 		} else {
-			if !hasPrefix(str, segment, caseInsensitive) {
+			if !hasPrefix(str, segment, toLower) {
 				return false
 			}
 			str = str[len(segment):]
@@ -39,6 +39,21 @@ func TestPatternNextSegment(t *testing.T) {
 	if !star || segment != "" {
 		t.Errorf("expected segment not found: %v, %v", star, segment)
 	}
+
+	star, segment, _ = nextSegment("*café*")
+	if !star || segment != "café" {
+		t.Errorf("expected segment not found: %v, %v", star, segment)
+	}
+
+	star, segment, next := nextSegment("ab\xff")
+	if star || segment != "ab\xff" || next != 3 {
+		t.Errorf("expected segment not found: %v, %q, %v", star, segment, next)
+	}
+
+	star, segment, _ = nextSegment("*\xffab*c")
+	if !star || segment != "\xffab" {
+		t.Errorf("expected segment not found: %v, %q", star, segment)
+	}
 }
 
 func TestPatternMatches(t *testing.T) {
@@ -101,4 +116,18 @@ func TestPatternMatches(t *testing.T) {
 			t.Error("should match")
 		}
 	})
+
+	t.Run("invalid-utf8", func(t *testing.T) {
+		if !PatternMatches("ab\xff", "ab\xff", false) {
+			t.Error("should match")
+		}
+
+		if !PatternMatches("*\xff*c", "ab\xffbc", false) {
+			t.Error("should match")
+		}
+
+		if PatternMatches("ab\xff", "abc", true) {
+			t.Error("shouldn't match")
+		}
+	})
 }
@@ -18,6 +18,7 @@ type StringCmpOpts struct {
 	PatternCaseInsensitive bool
 	GlobCaseInsensitive    bool
 	RegexpCaseInsensitive  bool
+	CaseFolding            CaseFolding
 }
 
 // DefaultStringCmpOpts defines the default comparison options
@@ -65,7 +66,7 @@ func (s *StringValues) AppendFieldValue(value FieldValue) {
 func (s *StringValues) Compile(opts StringCmpOpts) error {
 	for _, value := range s.fieldValues {
 		// fast path for scalar value without specific comparison behavior
-		if opts == DefaultStringCmpOpts && value.Type == ScalarValueType {
+		if !opts.ScalarCaseInsensitive && value.Type == ScalarValueType {
 			str := value.Value.(string)
 			s.scalars = append(s.scalars, str)
 			s.scalarCache[str] = true
@@ -129,6 +130,31 @@ func (s *StringValues) Matches(value string) bool {
 	return false
 }
 
+// matchesWithContext returns whether the value matches the string values. The case insensitive patterns folded
+// with the given strategy are matched against the folded value, cached in the context under the given key
+func (s *StringValues) matchesWithContext(ctx *Context, key string, value string, folding CaseFolding) bool {
+	if s.scalarCache != nil && s.scalarCache[value] {
+		return true
+	}
+
+	var folded string
+	var isFolded bool
+	for _, pm := range s.stringMatchers {
+		if p, ok := pm.(*PatternStringMatcher); ok && p.caseInsensitive && p.caseFolding == folding {
+			if !isFolded {
+				folded, isFolded = ctx.foldedValue(key, value, folding), true
+			}
+			if p.matchesFolded(folded) {
+				return true
+			}
+		} else if pm.Matches(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // StringMatcher defines a pattern matcher
 type StringMatcher interface {
 	Compile(pattern string, caseInsensitive bool) error
@@ -193,7 +219,9 @@ func (g *GlobStringMatcher) Contains(value string) bool {
 // PatternStringMatcher defines a pattern matcher
 type PatternStringMatcher struct {
 	pattern         string
+	foldedPattern   string
 	caseInsensitive bool
+	caseFolding     CaseFolding
 }
 
 // Compile a simple pattern
@@ -205,18 +233,31 @@ func (p *PatternStringMatcher) Compile(pattern string, caseInsensitive bool) err
 
 	p.pattern = pattern
 	p.caseInsensitive = caseInsensitive
+	if caseInsensitive {
+		p.pattern = p.caseFolding.normalize(pattern)
+		p.foldedPattern = p.caseFolding.fold(pattern)
+	}
 	return nil
 }
 
 // Matches returns whether the value matches
 func (p *PatternStringMatcher) Matches(value string) bool {
-	return PatternMatches(p.pattern, value, p.caseInsensitive)
+	if p.caseInsensitive {
+		return patternMatches(p.pattern, p.caseFolding.normalize(value), p.caseFolding.toLowerFnc())
+	}
+	return patternMatches(p.pattern, value, nil)
+}
+
+// matchesFolded returns whether the value, already folded with the strategy of the matcher, matches
+func (p *PatternStringMatcher) matchesFolded(folded string) bool {
+	return patternMatches(p.foldedPattern, folded, nil)
 }
 
 // ScalarStringMatcher defines a scalar matcher
 type ScalarStringMatcher struct {
 	value           string
 	caseInsensitive bool
+	caseFolding     CaseFolding
 }
 
 // Compile a simple pattern
@@ -229,7 +270,7 @@ func (s *ScalarStringMatcher) Compile(pattern string, caseInsensitive bool) erro
 // Matches returns whether the value matches
 func (s *ScalarStringMatcher) Matches(value string) bool {
 	if s.caseInsensitive {
-		return strings.EqualFold(s.value, value)
+		return s.caseFolding.equalFoldFnc()(s.value, value)
 	}
 	return s.value == value
 }
@@ -238,7 +279,7 @@ func (s *ScalarStringMatcher) Matches(value string) bool {
 func NewStringMatcher(kind FieldValueType, pattern string, opts StringCmpOpts) (StringMatcher, error) {
 	switch kind {
 	case PatternValueType:
-		var matcher PatternStringMatcher
+		matcher := PatternStringMatcher{caseFolding: opts.CaseFolding}
 		if err := matcher.Compile(pattern, opts.PatternCaseInsensitive); err != nil {
 			return nil, fmt.Errorf("invalid pattern `%s`: %s", pattern, err)
 		}
@@ -256,7 +297,7 @@ func NewStringMatcher(kind FieldValueType, pattern string, opts StringCmpOpts) (
 		}
 		return &matcher, nil
 	case ScalarValueType:
-		var matcher ScalarStringMatcher
+		matcher := ScalarStringMatcher{caseFolding: opts.CaseFolding}
 		if err := matcher.Compile(pattern, opts.ScalarCaseInsensitive); err != nil {
 			return nil, fmt.Errorf("invalid regexp `%s`: %s", pattern, err)
 		}
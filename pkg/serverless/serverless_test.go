@@ -10,6 +10,7 @@ import (
 	"os"
 	"runtime/debug"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -36,7 +37,7 @@ func TestHandleInvocationShouldSetExtraTags(t *testing.T) {
 	callInvocationHandler(d, "arn:aws:lambda:us-east-1:123456789012:function:my-function", deadlineMs, 0, "myRequestID", handleInvocation)
 	architecture := fmt.Sprintf("architecture:%s", tags.ResolveRuntimeArch())
 
-	assert.Equal(t, 14, len(d.ExtraTags.Tags))
+	assert.Equal(t, 16, len(d.ExtraTags.Tags))
 
 	sort.Strings(d.ExtraTags.Tags)
 	assert.Equal(t, "a1:valuea1", d.ExtraTags.Tags[0])
@@ -47,12 +48,14 @@ func TestHandleInvocationShouldSetExtraTags(t *testing.T) {
 	assert.Equal(t, "account_id:123456789012", d.ExtraTags.Tags[5])
 	assert.Equal(t, architecture, d.ExtraTags.Tags[6])
 	assert.Equal(t, "aws_account:123456789012", d.ExtraTags.Tags[7])
-	assert.Equal(t, "dd_extension_version:xxx", d.ExtraTags.Tags[8])
-	assert.Equal(t, "function_arn:arn:aws:lambda:us-east-1:123456789012:function:my-function", d.ExtraTags.Tags[9])
-	assert.Equal(t, "functionname:my-function", d.ExtraTags.Tags[10])
-	assert.Equal(t, "region:us-east-1", d.ExtraTags.Tags[11])
-	assert.Equal(t, "resource:my-function", d.ExtraTags.Tags[12])
-	assert.True(t, d.ExtraTags.Tags[13] == "runtime:unknown" || d.ExtraTags.Tags[13] == "runtime:provided.al2")
+	assert.True(t, strings.HasPrefix(d.ExtraTags.Tags[8], "cpu_vendor:"+tags.ResolveRuntimeArch()))
+	assert.Equal(t, "dd_extension_version:xxx", d.ExtraTags.Tags[9])
+	assert.Equal(t, "function_arn:arn:aws:lambda:us-east-1:123456789012:function:my-function", d.ExtraTags.Tags[10])
+	assert.Equal(t, "functionname:my-function", d.ExtraTags.Tags[11])
+	assert.Equal(t, "partition:aws", d.ExtraTags.Tags[12])
+	assert.Equal(t, "region:us-east-1", d.ExtraTags.Tags[13])
+	assert.Equal(t, "resource:my-function", d.ExtraTags.Tags[14])
+	assert.True(t, d.ExtraTags.Tags[15] == "runtime:unknown" || d.ExtraTags.Tags[15] == "runtime:provided.al2")
 
 	ecs := d.ExecutionContext.GetCurrentState()
 	assert.Equal(t, "arn:aws:lambda:us-east-1:123456789012:function:my-function", ecs.ARN)
@@ -10,7 +10,11 @@ import (
 	"io/ioutil"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/serverless/proc"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
@@ -26,6 +30,10 @@ const (
 	qualifierEnvVar = "AWS_LAMBDA_FUNCTION_VERSION"
 	runtimeVar      = "AWS_EXECUTION_ENV"
 	memorySizeVar   = "AWS_LAMBDA_FUNCTION_MEMORY_SIZE"
+	initTypeVar     = "AWS_LAMBDA_INITIALIZATION_TYPE"
+
+	// Environment variable hinting the language used within a custom runtime
+	runtimeHintVar = "DD_RUNTIME"
 
 	// FunctionARNKey is the tag key for a function's arn
 	FunctionARNKey = "function_arn"
@@ -39,6 +47,10 @@ const (
 	MemorySizeKey = "memorysize"
 	// ArchitectureKey is the tag key for a function's architecture (e.g. x86_64, arm64)
 	ArchitectureKey = "architecture"
+	// CPUVendorKey is the tag key for a function's CPU family (e.g. arm64_graviton2, x86_64_intel)
+	CPUVendorKey = "cpu_vendor"
+	// PackageTypeKey is the tag key for a function's deployment package type, only set for container images
+	PackageTypeKey = "package_type"
 
 	// EnvKey is the tag key for a function's env environment variable
 	EnvKey = "env"
@@ -55,10 +67,24 @@ const (
 
 	extensionVersionKey = "dd_extension_version"
 
+	coldStartKey         = "cold_start"
+	coldStartDurationKey = "cold_start_duration_ms"
+
+	partitionKey  = "partition"
 	regionKey     = "region"
 	accountIDKey  = "account_id"
 	awsAccountKey = "aws_account"
 	resourceKey   = "resource"
+	aliasKey      = "alias"
+
+	configWarningKey      = "dd_config_warning"
+	lowMemoryWarningValue = "low_memory"
+
+	// defaultMinMemorySize is the memory size (in MB) under which the low memory warning tag is added
+	defaultMinMemorySize = 128
+
+	// ImagePackageType is the package type of functions deployed as a container image
+	ImagePackageType = "image"
 
 	// X86LambdaPlatform is for the lambda platform X86_64
 	X86LambdaPlatform = "x86_64"
@@ -66,6 +92,61 @@ const (
 	ArmLambdaPlatform = "arm64"
 	// AmdLambdaPlatform is for the lambda platform Amd64, which is an extendion of X86_64
 	AmdLambdaPlatform = "amd64"
+
+	armImplementerID = "0x41"
+
+	// accountIDLength is the number of digits of an AWS account ID
+	accountIDLength = 12
+)
+
+// gravitonCPUParts maps the "CPU part" values of /proc/cpuinfo to the Graviton generation using that core
+var gravitonCPUParts = map[string]string{
+	"0xd0c": "graviton2", // Neoverse N1
+	"0xd40": "graviton3", // Neoverse V1
+	"0xd4f": "graviton4", // Neoverse V2
+}
+
+// amazonLinuxRegex matches the os-release files of every Amazon Linux version
+var amazonLinuxRegex = regexp.MustCompile(`(?m)^NAME="Amazon Linux`)
+
+// x86CPUVendors maps the "vendor_id" values of /proc/cpuinfo to a short vendor name
+var x86CPUVendors = map[string]string{
+	"GenuineIntel": "intel",
+	"AuthenticAMD": "amd",
+}
+
+// reservedTagKeys are the keys of the tags set by the extension itself.
+// They have priority over user-defined tags when tags have to be dropped.
+var reservedTagKeys = map[string]struct{}{
+	FunctionARNKey:       {},
+	FunctionNameKey:      {},
+	ExecutedVersionKey:   {},
+	RuntimeKey:           {},
+	MemorySizeKey:        {},
+	ArchitectureKey:      {},
+	CPUVendorKey:         {},
+	PackageTypeKey:       {},
+	EnvKey:               {},
+	VersionKey:           {},
+	ServiceKey:           {},
+	extensionVersionKey:  {},
+	coldStartKey:         {},
+	coldStartDurationKey: {},
+	partitionKey:         {},
+	regionKey:            {},
+	accountIDKey:         {},
+	awsAccountKey:        {},
+	resourceKey:          {},
+	aliasKey:             {},
+	configWarningKey:     {},
+}
+
+var (
+	// resolveRuntime is the runtime detection function, overridden in tests
+	resolveRuntime = getRuntime
+
+	runtimeOnce   sync.Once
+	cachedRuntime string
 )
 
 // currentExtensionVersion represents the current version of the Datadog Lambda Extension.
@@ -73,55 +154,127 @@ const (
 // It is replaced at build time with an actual version number.
 var currentExtensionVersion = "xxx"
 
+// TagOptions defines options to apply when building tags
+type TagOptions struct {
+	// PreserveCaseKeys lists the tag keys whose values are kept verbatim instead of being lowercased
+	PreserveCaseKeys []string
+	// MinMemorySize is the memory size (in MB) under which a low memory warning tag is added, 128 if not set
+	MinMemorySize int
+}
+
+// preservesCase returns whether the value of the given tag key must be kept verbatim
+func (o TagOptions) preservesCase(key string) bool {
+	for _, k := range o.PreserveCaseKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
 // BuildTagMap builds a map of tag based on the arn and user defined tags
 func BuildTagMap(arn string, configTags []string) map[string]string {
+	return BuildTagMapWithOptions(arn, configTags, TagOptions{})
+}
+
+// BuildTagMapWithOptions builds a map of tag based on the arn and user defined tags, applying the given options
+func BuildTagMapWithOptions(arn string, configTags []string, opts TagOptions) map[string]string {
 	tags := make(map[string]string)
 
 	architecture := ResolveRuntimeArch()
-	tags = setIfNotEmpty(tags, ArchitectureKey, architecture)
+	tags = setIfNotEmpty(tags, ArchitectureKey, architecture, opts)
+	tags = setIfNotEmpty(tags, CPUVendorKey, getCPUVendor("/proc", architecture), opts)
 
-	tags = setIfNotEmpty(tags, RuntimeKey, getRuntime("/proc", "/etc", runtimeVar))
+	tags = setIfNotEmpty(tags, RuntimeKey, getCachedRuntime("/proc", "/etc", runtimeVar), opts)
+	tags = setIfNotEmpty(tags, PackageTypeKey, getPackageType("/etc"), opts)
 
-	tags = setIfNotEmpty(tags, MemorySizeKey, os.Getenv(memorySizeVar))
+	memorySize := os.Getenv(memorySizeVar)
+	tags = setIfNotEmpty(tags, MemorySizeKey, memorySize, opts)
+	if isLowMemorySize(memorySize, opts) {
+		tags = setIfNotEmpty(tags, configWarningKey, lowMemoryWarningValue, opts)
+	}
 
-	tags = setIfNotEmpty(tags, EnvKey, os.Getenv(envEnvVar))
-	tags = setIfNotEmpty(tags, VersionKey, os.Getenv(versionEnvVar))
-	tags = setIfNotEmpty(tags, ServiceKey, os.Getenv(serviceEnvVar))
+	tags = setIfNotEmpty(tags, EnvKey, os.Getenv(envEnvVar), opts)
+	tags = setIfNotEmpty(tags, VersionKey, os.Getenv(versionEnvVar), opts)
+	tags = setIfNotEmpty(tags, ServiceKey, os.Getenv(serviceEnvVar), opts)
 
+	userTags := make(map[string]string)
 	for _, tag := range configTags {
 		splitTags := strings.Split(tag, ",")
 		for _, singleTag := range splitTags {
-			tags = addTag(tags, singleTag)
+			userTags = addTag(userTags, singleTag, opts)
 		}
 	}
+	tags = MergeTagMaps(tags, userTags, OverlayWins)
 
-	tags = setIfNotEmpty(tags, traceOriginMetadataKey, traceOriginMetadataValue)
-	tags = setIfNotEmpty(tags, computeStatsKey, computeStatsValue)
-	tags = setIfNotEmpty(tags, FunctionARNKey, arn)
-	tags = setIfNotEmpty(tags, extensionVersionKey, GetExtensionVersion())
+	tags = setIfNotEmpty(tags, traceOriginMetadataKey, traceOriginMetadataValue, opts)
+	tags = setIfNotEmpty(tags, computeStatsKey, computeStatsValue, opts)
+	tags = setIfNotEmpty(tags, FunctionARNKey, arn, opts)
+	tags = setIfNotEmpty(tags, extensionVersionKey, GetExtensionVersion(), opts)
 
 	parts := strings.Split(arn, ":")
 	if len(parts) < 6 {
 		return tags
 	}
 
-	tags = setIfNotEmpty(tags, regionKey, parts[3])
-	tags = setIfNotEmpty(tags, awsAccountKey, parts[4])
-	tags = setIfNotEmpty(tags, accountIDKey, parts[4])
-	tags = setIfNotEmpty(tags, FunctionNameKey, parts[6])
-	tags = setIfNotEmpty(tags, resourceKey, parts[6])
+	// the partition (aws, aws-cn, aws-us-gov) doesn't change the position of the other ARN segments
+	tags = setIfNotEmpty(tags, partitionKey, parts[1], opts)
+	tags = setIfNotEmpty(tags, regionKey, parts[3], opts)
+	if accountID, ok := normalizeAccountID(parts[4]); ok {
+		tags = setIfNotEmpty(tags, awsAccountKey, accountID, opts)
+		tags = setIfNotEmpty(tags, accountIDKey, accountID, opts)
+	}
+	tags = setIfNotEmpty(tags, FunctionNameKey, parts[6], opts)
 
 	qualifier := os.Getenv(qualifierEnvVar)
-	if len(qualifier) > 0 {
-		if qualifier != "$LATEST" {
-			tags = setIfNotEmpty(tags, resourceKey, fmt.Sprintf("%s:%s", parts[6], qualifier))
-			tags = setIfNotEmpty(tags, ExecutedVersionKey, qualifier)
-		}
+	if qualifier != "$LATEST" {
+		tags = setIfNotEmpty(tags, ExecutedVersionKey, qualifier, opts)
 	}
 
+	// an alias in the ARN takes precedence over the executed version
+	if len(parts) > 7 && isAlias(parts[7]) {
+		qualifier = parts[7]
+		tags = setIfNotEmpty(tags, aliasKey, parts[7], opts)
+	}
+	tags = setIfNotEmpty(tags, resourceKey, ResolveResourceTag(parts[6], qualifier), opts)
+
 	return tags
 }
 
+// MergePolicy defines which value is kept when a tag key is present in both maps being merged
+type MergePolicy int
+
+const (
+	// OverlayWins keeps the value of the overlay map for conflicting keys
+	OverlayWins MergePolicy = iota
+	// BaseWins keeps the value of the base map for conflicting keys
+	BaseWins
+)
+
+// MergeTagMaps returns a new map holding the tags of both maps, resolving conflicting keys with the given policy
+func MergeTagMaps(base, overlay map[string]string, policy MergePolicy) map[string]string {
+	merged := make(map[string]string, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		if _, exists := merged[k]; exists && policy == BaseWins {
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// ResolveResourceTag returns the value of the resource tag of a function given its qualifier:
+// the bare function name for an empty or $LATEST qualifier, name:qualifier for a version or an alias
+func ResolveResourceTag(functionName, qualifier string) string {
+	if qualifier == "" || qualifier == "$LATEST" {
+		return functionName
+	}
+	return fmt.Sprintf("%s:%s", functionName, qualifier)
+}
+
 // BuildTagsFromMap builds an array of tag based on map of tags
 func BuildTagsFromMap(tags map[string]string) []string {
 	tagsMap := make(map[string]string)
@@ -139,14 +292,71 @@ func BuildTagsFromMap(tags map[string]string) []string {
 	return tagsArray
 }
 
+// BuildTagsFromMapSorted builds an array of tag based on map of tags, sorted lexicographically
+func BuildTagsFromMapSorted(tags map[string]string) []string {
+	tagsArray := BuildTagsFromMap(tags)
+	sort.Strings(tagsArray)
+	return tagsArray
+}
+
+// FormatTagsCapped formats tags as a sorted, comma-separated string whose length doesn't exceed maxTotalLen,
+// so that it fits within DogStatsD limits. Reserved tags (set by the extension itself) are kept first, then
+// user-defined tags are added in lexicographic order as long as they fit in the budget.
+// It returns the formatted tags along with the number of tags dropped because of the cap.
+// A non-positive maxTotalLen disables the cap.
+func FormatTagsCapped(tags []string, maxTotalLen int) (string, int) {
+	sortedTags := make([]string, len(tags))
+	copy(sortedTags, tags)
+	sort.Strings(sortedTags)
+
+	if maxTotalLen <= 0 {
+		return strings.Join(sortedTags, ","), 0
+	}
+
+	reserved := make([]string, 0, len(sortedTags))
+	others := make([]string, 0, len(sortedTags))
+	for _, tag := range sortedTags {
+		key := strings.SplitN(tag, ":", 2)[0]
+		if _, ok := reservedTagKeys[key]; ok {
+			reserved = append(reserved, tag)
+		} else {
+			others = append(others, tag)
+		}
+	}
+
+	kept := make([]string, 0, len(sortedTags))
+	totalLen := 0
+	dropped := 0
+	for _, tag := range append(reserved, others...) {
+		tagLen := len(tag)
+		if len(kept) > 0 {
+			// separator
+			tagLen++
+		}
+		if totalLen+tagLen > maxTotalLen {
+			dropped++
+			continue
+		}
+		kept = append(kept, tag)
+		totalLen += tagLen
+	}
+
+	sort.Strings(kept)
+	return strings.Join(kept, ","), dropped
+}
+
 // BuildTracerTags builds a map of tag from an existing map of tag removing useless tags for traces
 func BuildTracerTags(tags map[string]string) map[string]string {
+	return BuildTracerTagsWithBlacklist(tags, resourceKey)
+}
+
+// BuildTracerTagsWithBlacklist builds a map of tag from an existing map of tag removing the blacklisted keys
+func BuildTracerTagsWithBlacklist(tags map[string]string, blacklist ...string) map[string]string {
 	tagsMap := make(map[string]string)
-	tagBlackList := []string{resourceKey}
 	for k, v := range tags {
 		tagsMap[k] = v
 	}
-	for _, blackListKey := range tagBlackList {
+	for _, blackListKey := range blacklist {
 		delete(tagsMap, blackListKey)
 	}
 	return tagsMap
@@ -154,7 +364,17 @@ func BuildTracerTags(tags map[string]string) map[string]string {
 
 // AddColdStartTag appends the cold_start tag to existing tags
 func AddColdStartTag(tags []string, coldStart bool) []string {
-	tags = append(tags, fmt.Sprintf("cold_start:%v", coldStart))
+	tags = append(tags, fmt.Sprintf("%s:%v", coldStartKey, coldStart))
+	return tags
+}
+
+// AddColdStartDurationTag appends the cold_start_duration_ms tag to existing tags.
+// The duration is rounded to whole milliseconds, and the tag is omitted if the duration isn't positive.
+func AddColdStartDurationTag(tags []string, d time.Duration) []string {
+	if d <= 0 {
+		return tags
+	}
+	tags = append(tags, fmt.Sprintf("%s:%d", coldStartDurationKey, d.Round(time.Millisecond).Milliseconds()))
 	return tags
 }
 
@@ -163,18 +383,90 @@ func GetExtensionVersion() string {
 	return currentExtensionVersion
 }
 
-func setIfNotEmpty(tagMap map[string]string, key string, value string) map[string]string {
+// isLowMemorySize returns whether the configured memory size is below the minimum, ignoring invalid values
+func isLowMemorySize(memorySize string, opts TagOptions) bool {
+	size, err := strconv.Atoi(memorySize)
+	if err != nil {
+		return false
+	}
+	minMemorySize := opts.MinMemorySize
+	if minMemorySize <= 0 {
+		minMemorySize = defaultMinMemorySize
+	}
+	return size < minMemorySize
+}
+
+// normalizeAccountID validates the account ID segment of an ARN, left-padding it with zeros up to 12 digits.
+// It returns false if the segment isn't a valid account ID.
+func normalizeAccountID(accountID string) (string, bool) {
+	if accountID == "" {
+		return "", false
+	}
+	if len(accountID) > accountIDLength {
+		log.Debugf("invalid account ID %q in function ARN: more than %d digits", accountID, accountIDLength)
+		return "", false
+	}
+	for _, c := range accountID {
+		if c < '0' || c > '9' {
+			log.Debugf("invalid account ID %q in function ARN: not numeric", accountID)
+			return "", false
+		}
+	}
+	return strings.Repeat("0", accountIDLength-len(accountID)) + accountID, true
+}
+
+// getPackageType returns ImagePackageType when the function is known to be deployed as a container image,
+// and an empty string otherwise, in which case the tag is omitted. The only positive signal is an OS other
+// than Amazon Linux, which .zip archives always run on. Functions running on Amazon Linux can't be told
+// apart: the AWS base images for container functions run the same managed runtimes as .zip archives, with
+// the same environment variables.
+func getPackageType(osReleasePath string) string {
+	if os.Getenv(initTypeVar) == "" {
+		// not running in a Lambda execution environment
+		return ""
+	}
+	bytesRead, err := ioutil.ReadFile(fmt.Sprintf("%s/os-release", osReleasePath))
+	if err != nil {
+		log.Debug("could not read os-release file")
+		return ""
+	}
+	if amazonLinuxRegex.Match(bytesRead) {
+		return ""
+	}
+	return ImagePackageType
+}
+
+// isAlias returns whether the qualifier of a function ARN is an alias, as opposed to a version
+func isAlias(qualifier string) bool {
+	if qualifier == "" || qualifier == "$LATEST" {
+		return false
+	}
+	_, err := strconv.ParseUint(qualifier, 10, 64)
+	return err != nil
+}
+
+func setIfNotEmpty(tagMap map[string]string, key string, value string, opts TagOptions) map[string]string {
 	if key != "" && value != "" {
-		tagMap[key] = strings.ToLower(value)
+		if !opts.preservesCase(key) {
+			value = strings.ToLower(value)
+		}
+		tagMap[key] = value
 	}
 	return tagMap
 }
 
-func addTag(tagMap map[string]string, tag string) map[string]string {
-	extract := strings.Split(tag, ":")
-	if len(extract) == 2 {
-		tagMap[strings.ToLower(extract[0])] = strings.ToLower(extract[1])
+// addTag adds a key:value or key=value tag to the map, splitting on the first separator found
+// so that values containing separators (e.g. ARNs) are kept whole. Malformed tags are dropped.
+func addTag(tagMap map[string]string, tag string, opts TagOptions) map[string]string {
+	sep := strings.IndexAny(tag, ":=")
+	if sep <= 0 || sep == len(tag)-1 {
+		return tagMap
+	}
+	key, value := strings.ToLower(tag[:sep]), tag[sep+1:]
+	if !opts.preservesCase(key) {
+		value = strings.ToLower(value)
 	}
+	tagMap[key] = value
 	return tagMap
 }
 
@@ -193,10 +485,64 @@ func getRuntimeFromOsReleaseFile(osReleasePath string) string {
 	return runtime
 }
 
+// getCPUVendor refines the given architecture with the CPU family read from the cpuinfo file
+// (e.g. arm64_graviton2), falling back to the architecture itself when it can't be determined.
+func getCPUVendor(procPath string, architecture string) string {
+	bytesRead, err := ioutil.ReadFile(fmt.Sprintf("%s/cpuinfo", procPath))
+	if err != nil {
+		log.Debug("could not read cpuinfo file")
+		return architecture
+	}
+
+	cpuInfo := make(map[string]string)
+	for _, line := range strings.Split(string(bytesRead), "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		// only keep the values of the first processor
+		if _, ok := cpuInfo[key]; !ok {
+			cpuInfo[key] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	if architecture == ArmLambdaPlatform {
+		if cpuInfo["CPU implementer"] == armImplementerID {
+			if generation, ok := gravitonCPUParts[cpuInfo["CPU part"]]; ok {
+				return fmt.Sprintf("%s_%s", architecture, generation)
+			}
+		}
+		return architecture
+	}
+	if vendor, ok := x86CPUVendors[cpuInfo["vendor_id"]]; ok {
+		return fmt.Sprintf("%s_%s", architecture, vendor)
+	}
+	return architecture
+}
+
+// getCachedRuntime returns the runtime of the function, which is only detected once per process lifetime
+func getCachedRuntime(procPath string, osReleasePath string, varName string) string {
+	runtimeOnce.Do(func() {
+		cachedRuntime = resolveRuntime(procPath, osReleasePath, varName)
+	})
+	return cachedRuntime
+}
+
+// ResetRuntimeCache clears the cached runtime so that it is detected again, it should only be used in tests
+func ResetRuntimeCache() {
+	runtimeOnce = sync.Once{}
+	cachedRuntime = ""
+}
+
 func getRuntime(procPath string, osReleasePath string, varName string) string {
 	foundRuntimes := proc.SearchProcsForEnvVariable(procPath, varName)
 	runtime := cleanRuntimes(foundRuntimes)
 	runtime = strings.Replace(runtime, "AWS_Lambda_", "", 1)
+	if len(runtime) == 0 {
+		// custom runtimes don't expose their language, rely on the user provided hint if any
+		runtime = strings.TrimSpace(os.Getenv(runtimeHintVar))
+	}
 	if len(runtime) == 0 {
 		runtime = getRuntimeFromOsReleaseFile(osReleasePath)
 	}
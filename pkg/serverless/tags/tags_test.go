@@ -6,32 +6,59 @@
 package tags
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSetIfNotEmptyWithNonEmptyKey(t *testing.T) {
 	testMap := make(map[string]string)
-	testMap = setIfNotEmpty(testMap, "nonEmptyKey", "VALUE")
+	testMap = setIfNotEmpty(testMap, "nonEmptyKey", "VALUE", TagOptions{})
 	assert.Equal(t, 1, len(testMap))
 	assert.Equal(t, "value", testMap["nonEmptyKey"])
 }
 
 func TestSetIfNotEmptyWithEmptyKey(t *testing.T) {
 	testMap := make(map[string]string)
-	testMap = setIfNotEmpty(testMap, "", "VALUE")
+	testMap = setIfNotEmpty(testMap, "", "VALUE", TagOptions{})
 	assert.Equal(t, 0, len(testMap))
 }
 
 func TestSetIfNotEmptyWithEmptyValue(t *testing.T) {
 	testMap := make(map[string]string)
-	testMap = setIfNotEmpty(testMap, "nonEmptyKey", "")
+	testMap = setIfNotEmpty(testMap, "nonEmptyKey", "", TagOptions{})
 	assert.Equal(t, 0, len(testMap))
 }
 
+func TestMergeTagMaps(t *testing.T) {
+	base := map[string]string{"env": "prod", "service": "api"}
+	overlay := map[string]string{"env": "staging", "team": "payments"}
+
+	t.Run("overlay wins", func(t *testing.T) {
+		merged := MergeTagMaps(base, overlay, OverlayWins)
+		assert.Equal(t, map[string]string{"env": "staging", "service": "api", "team": "payments"}, merged)
+	})
+
+	t.Run("base wins", func(t *testing.T) {
+		merged := MergeTagMaps(base, overlay, BaseWins)
+		assert.Equal(t, map[string]string{"env": "prod", "service": "api", "team": "payments"}, merged)
+	})
+
+	t.Run("inputs untouched", func(t *testing.T) {
+		merged := MergeTagMaps(base, nil, OverlayWins)
+		merged["env"] = "dev"
+		assert.Equal(t, map[string]string{"env": "prod", "service": "api"}, base)
+		assert.Equal(t, map[string]string{"env": "staging", "team": "payments"}, overlay)
+	})
+}
+
 func TestBuildTracerTags(t *testing.T) {
 	tagsMap := map[string]string{
 		"key0":     "value0",
@@ -44,6 +71,27 @@ func TestBuildTracerTags(t *testing.T) {
 	assert.Equal(t, "value1", resultTagsMap["key1"])
 }
 
+func TestBuildTracerTagsWithBlacklist(t *testing.T) {
+	tagsMap := map[string]string{
+		"key0":     "value0",
+		"resource": "value1",
+		"key1":     "value1",
+		"key2":     "value2",
+	}
+	resultTagsMap := BuildTracerTagsWithBlacklist(tagsMap, "resource", "key1", "unknown")
+	assert.Equal(t, map[string]string{"key0": "value0", "key2": "value2"}, resultTagsMap)
+	assert.Equal(t, 4, len(tagsMap))
+}
+
+func TestBuildTracerTagsWithEmptyBlacklist(t *testing.T) {
+	tagsMap := map[string]string{
+		"key0":     "value0",
+		"resource": "value1",
+	}
+	resultTagsMap := BuildTracerTagsWithBlacklist(tagsMap)
+	assert.Equal(t, tagsMap, resultTagsMap)
+}
+
 func TestBuildTagsFromMap(t *testing.T) {
 	tagsMap := map[string]string{
 		"key0":              "value0",
@@ -63,10 +111,25 @@ func TestBuildTagsFromMap(t *testing.T) {
 	}, resultTagsArray)
 }
 
+func TestBuildTagsFromMapSorted(t *testing.T) {
+	tagsMap := map[string]string{
+		"key1":              "value1",
+		"key0":              "value0",
+		"_dd.origin":        "xxx",
+		"_dd.compute_stats": "xxx",
+		"a":                 "b",
+		"key2":              "value2",
+	}
+	expected := []string{"a:b", "key0:value0", "key1:value1", "key2:value2"}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, expected, BuildTagsFromMapSorted(tagsMap))
+	}
+}
+
 func TestBuildTagMapFromArnIncomplete(t *testing.T) {
 	arn := "function:my-function"
 	tagMap := BuildTagMap(arn, []string{"tag0:value0", "TAG1:VALUE1"})
-	assert.Equal(t, 8, len(tagMap))
+	assert.Equal(t, 9, len(tagMap))
 	assert.Equal(t, "lambda", tagMap["_dd.origin"])
 	assert.Equal(t, "1", tagMap["_dd.compute_stats"])
 	assert.Equal(t, "function:my-function", tagMap["function_arn"])
@@ -81,7 +144,7 @@ func TestBuildTagMapFromArnIncomplete(t *testing.T) {
 func TestBuildTagMapFromArnIncompleteWithCommaAndSpaceTags(t *testing.T) {
 	arn := "function:my-function"
 	tagMap := BuildTagMap(arn, []string{"tag0:value0", "tag1:value1,tag2:VALUE2", "TAG3:VALUE3"})
-	assert.Equal(t, 10, len(tagMap))
+	assert.Equal(t, 11, len(tagMap))
 	assert.Equal(t, "lambda", tagMap["_dd.origin"])
 	assert.Equal(t, "1", tagMap["_dd.compute_stats"])
 	assert.Equal(t, "function:my-function", tagMap["function_arn"])
@@ -98,7 +161,7 @@ func TestBuildTagMapFromArnIncompleteWithCommaAndSpaceTags(t *testing.T) {
 func TestBuildTagMapFromArnComplete(t *testing.T) {
 	arn := "arn:aws:lambda:us-east-1:123456789012:function:my-function"
 	tagMap := BuildTagMap(arn, []string{"tag0:value0", "TAG1:VALUE1"})
-	assert.Equal(t, 13, len(tagMap))
+	assert.Equal(t, 15, len(tagMap))
 	assert.Equal(t, "lambda", tagMap["_dd.origin"])
 	assert.Equal(t, "1", tagMap["_dd.compute_stats"])
 	assert.Equal(t, "arn:aws:lambda:us-east-1:123456789012:function:my-function", tagMap["function_arn"])
@@ -125,7 +188,7 @@ func TestBuildTagMapFromArnCompleteWithEnvAndVersionAndService(t *testing.T) {
 
 	arn := "arn:aws:lambda:us-east-1:123456789012:function:my-function"
 	tagMap := BuildTagMap(arn, []string{"tag0:value0", "TAG1:VALUE1"})
-	assert.Equal(t, 16, len(tagMap))
+	assert.Equal(t, 18, len(tagMap))
 	assert.Equal(t, "mytestenv", tagMap["env"])
 	assert.Equal(t, "mytestversion", tagMap["version"])
 	assert.Equal(t, "mytestservice", tagMap["service"])
@@ -148,7 +211,7 @@ func TestBuildTagMapFromArnCompleteWithEnvAndVersionAndService(t *testing.T) {
 func TestBuildTagMapFromArnCompleteWithUpperCase(t *testing.T) {
 	arn := "arn:aws:lambda:us-east-1:123456789012:function:My-Function"
 	tagMap := BuildTagMap(arn, []string{"tag0:value0", "TAG1:VALUE1"})
-	assert.Equal(t, 13, len(tagMap))
+	assert.Equal(t, 15, len(tagMap))
 	assert.Equal(t, "lambda", tagMap["_dd.origin"])
 	assert.Equal(t, "1", tagMap["_dd.compute_stats"])
 	assert.Equal(t, "arn:aws:lambda:us-east-1:123456789012:function:my-function", tagMap["function_arn"])
@@ -168,7 +231,7 @@ func TestBuildTagMapFromArnCompleteWithLatest(t *testing.T) {
 	os.Setenv("AWS_LAMBDA_FUNCTION_VERSION", "$LATEST")
 	arn := "arn:aws:lambda:us-east-1:123456789012:function:my-function"
 	tagMap := BuildTagMap(arn, []string{"tag0:value0", "TAG1:VALUE1"})
-	assert.Equal(t, 13, len(tagMap))
+	assert.Equal(t, 15, len(tagMap))
 	assert.Equal(t, "lambda", tagMap["_dd.origin"])
 	assert.Equal(t, "1", tagMap["_dd.compute_stats"])
 	assert.Equal(t, "arn:aws:lambda:us-east-1:123456789012:function:my-function", tagMap["function_arn"])
@@ -188,7 +251,7 @@ func TestBuildTagMapFromArnCompleteWithVersionNumber(t *testing.T) {
 	os.Setenv("AWS_LAMBDA_FUNCTION_VERSION", "888")
 	arn := "arn:aws:lambda:us-east-1:123456789012:function:my-function"
 	tagMap := BuildTagMap(arn, []string{"tag0:value0", "TAG1:VALUE1"})
-	assert.Equal(t, 14, len(tagMap))
+	assert.Equal(t, 16, len(tagMap))
 	assert.Equal(t, "lambda", tagMap["_dd.origin"])
 	assert.Equal(t, "1", tagMap["_dd.compute_stats"])
 	assert.Equal(t, "arn:aws:lambda:us-east-1:123456789012:function:my-function", tagMap["function_arn"])
@@ -205,12 +268,130 @@ func TestBuildTagMapFromArnCompleteWithVersionNumber(t *testing.T) {
 	assert.True(t, tagMap["runtime"] == "unknown" || tagMap["runtime"] == "provided.al2")
 }
 
+func TestBuildTagMapFromArnWithAlias(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_FUNCTION_VERSION", "12")
+	arn := "arn:aws:lambda:us-east-1:123456789012:function:my-function:Prod"
+	tagMap := BuildTagMap(arn, []string{})
+	assert.Equal(t, "my-function", tagMap["functionname"])
+	assert.Equal(t, "my-function:prod", tagMap["resource"])
+	assert.Equal(t, "prod", tagMap["alias"])
+	assert.Equal(t, "12", tagMap["executedversion"])
+}
+
+func TestBuildTagMapFromArnWithVersionQualifier(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_FUNCTION_VERSION", "12")
+	arn := "arn:aws:lambda:us-east-1:123456789012:function:my-function:12"
+	tagMap := BuildTagMap(arn, []string{})
+	assert.Equal(t, "my-function", tagMap["functionname"])
+	assert.Equal(t, "my-function:12", tagMap["resource"])
+	assert.Equal(t, "12", tagMap["executedversion"])
+	assert.NotContains(t, tagMap, "alias")
+}
+
+func TestBuildTagMapFromArnUnqualified(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_FUNCTION_VERSION", "$LATEST")
+	arn := "arn:aws:lambda:us-east-1:123456789012:function:my-function"
+	tagMap := BuildTagMap(arn, []string{})
+	assert.Equal(t, "my-function", tagMap["functionname"])
+	assert.Equal(t, "my-function", tagMap["resource"])
+	assert.NotContains(t, tagMap, "executedversion")
+	assert.NotContains(t, tagMap, "alias")
+}
+
+func TestBuildTagMapPartitions(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_FUNCTION_VERSION", "3")
+	tests := []struct {
+		arn       string
+		partition string
+		region    string
+	}{
+		{arn: "arn:aws:lambda:us-east-1:123456789012:function:my-function", partition: "aws", region: "us-east-1"},
+		{arn: "arn:aws-cn:lambda:cn-north-1:123456789012:function:my-function", partition: "aws-cn", region: "cn-north-1"},
+		{arn: "arn:aws-us-gov:lambda:us-gov-west-1:123456789012:function:my-function", partition: "aws-us-gov", region: "us-gov-west-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.partition, func(t *testing.T) {
+			tagMap := BuildTagMap(tt.arn, []string{})
+			assert.Equal(t, tt.partition, tagMap["partition"])
+			assert.Equal(t, tt.region, tagMap["region"])
+			assert.Equal(t, "123456789012", tagMap["account_id"])
+			assert.Equal(t, "my-function", tagMap["functionname"])
+			assert.Equal(t, "3", tagMap["executedversion"])
+			assert.Equal(t, "my-function:3", tagMap["resource"])
+		})
+	}
+}
+
+func TestBuildTagMapLowMemoryWarning(t *testing.T) {
+	arn := "arn:aws:lambda:us-east-1:123456789012:function:my-function"
+
+	t.Run("below threshold", func(t *testing.T) {
+		t.Setenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE", "256")
+		tagMap := BuildTagMapWithOptions(arn, []string{}, TagOptions{MinMemorySize: 512})
+		assert.Equal(t, "low_memory", tagMap["dd_config_warning"])
+	})
+
+	t.Run("at threshold", func(t *testing.T) {
+		t.Setenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE", "128")
+		tagMap := BuildTagMap(arn, []string{})
+		assert.NotContains(t, tagMap, "dd_config_warning")
+	})
+
+	t.Run("below default threshold", func(t *testing.T) {
+		t.Setenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE", "64")
+		tagMap := BuildTagMap(arn, []string{})
+		assert.Equal(t, "low_memory", tagMap["dd_config_warning"])
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		t.Setenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE", "lots")
+		tagMap := BuildTagMapWithOptions(arn, []string{}, TagOptions{MinMemorySize: 512})
+		assert.NotContains(t, tagMap, "dd_config_warning")
+		assert.Equal(t, "lots", tagMap["memorysize"])
+	})
+}
+
+func TestNormalizeAccountID(t *testing.T) {
+	accountID, ok := normalizeAccountID("123456789012")
+	assert.True(t, ok)
+	assert.Equal(t, "123456789012", accountID)
+
+	accountID, ok = normalizeAccountID("1234567")
+	assert.True(t, ok)
+	assert.Equal(t, "000001234567", accountID)
+
+	for _, invalid := range []string{"", "12345678901a", "account", "-12345", "1234567890123"} {
+		_, ok = normalizeAccountID(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+func TestBuildTagMapAccountID(t *testing.T) {
+	tagMap := BuildTagMap("arn:aws:lambda:us-east-1:1234567:function:my-function", []string{})
+	assert.Equal(t, "000001234567", tagMap["aws_account"])
+	assert.Equal(t, "000001234567", tagMap["account_id"])
+
+	tagMap = BuildTagMap("arn:aws:lambda:us-east-1:my-account:function:my-function", []string{})
+	assert.NotContains(t, tagMap, "aws_account")
+	assert.NotContains(t, tagMap, "account_id")
+	assert.Equal(t, "us-east-1", tagMap["region"])
+	assert.Equal(t, "my-function", tagMap["functionname"])
+}
+
+func TestIsAlias(t *testing.T) {
+	assert.True(t, isAlias("prod"))
+	assert.True(t, isAlias("v2-canary"))
+	assert.False(t, isAlias("12"))
+	assert.False(t, isAlias("$LATEST"))
+	assert.False(t, isAlias(""))
+}
+
 func TestAddTagInvalid(t *testing.T) {
 	tagMap := map[string]string{
 		"key_a": "value_a",
 		"key_b": "value_b",
 	}
-	addTag(tagMap, "invalidTag")
+	addTag(tagMap, "invalidTag", TagOptions{})
 	assert.Equal(t, 2, len(tagMap))
 	assert.Equal(t, "value_a", tagMap["key_a"])
 	assert.Equal(t, "value_b", tagMap["key_b"])
@@ -221,17 +402,20 @@ func TestAddTagInvalid2(t *testing.T) {
 		"key_a": "value_a",
 		"key_b": "value_b",
 	}
-	addTag(tagMap, "invalidTag:invalid:invalid")
+	addTag(tagMap, "invalidTag:", TagOptions{})
+	addTag(tagMap, ":invalid", TagOptions{})
+	addTag(tagMap, "=invalid", TagOptions{})
 	assert.Equal(t, 2, len(tagMap))
 	assert.Equal(t, "value_a", tagMap["key_a"])
 	assert.Equal(t, "value_b", tagMap["key_b"])
 }
+
 func TestAddTagInvalid3(t *testing.T) {
 	tagMap := map[string]string{
 		"key_a": "value_a",
 		"key_b": "value_b",
 	}
-	addTag(tagMap, "")
+	addTag(tagMap, "", TagOptions{})
 	assert.Equal(t, 2, len(tagMap))
 	assert.Equal(t, "value_a", tagMap["key_a"])
 	assert.Equal(t, "value_b", tagMap["key_b"])
@@ -242,13 +426,62 @@ func TestAddTag(t *testing.T) {
 		"key_a": "value_a",
 		"key_b": "value_b",
 	}
-	addTag(tagMap, "VaLiD:TaG")
+	addTag(tagMap, "VaLiD:TaG", TagOptions{})
 	assert.Equal(t, 3, len(tagMap))
 	assert.Equal(t, "value_a", tagMap["key_a"])
 	assert.Equal(t, "value_b", tagMap["key_b"])
 	assert.Equal(t, "tag", tagMap["valid"])
 }
 
+func TestAddTagEqualSeparator(t *testing.T) {
+	tagMap := make(map[string]string)
+	addTag(tagMap, "Team=Payments", TagOptions{})
+	assert.Equal(t, 1, len(tagMap))
+	assert.Equal(t, "payments", tagMap["team"])
+}
+
+func TestAddTagValueWithSeparators(t *testing.T) {
+	tagMap := make(map[string]string)
+	addTag(tagMap, "queue:arn:aws:sqs:us-east-1:123456789012:my-queue", TagOptions{})
+	addTag(tagMap, "role=arn:aws:iam::123456789012:role/my-role", TagOptions{})
+	addTag(tagMap, "query:a=b", TagOptions{})
+	assert.Equal(t, 3, len(tagMap))
+	assert.Equal(t, "arn:aws:sqs:us-east-1:123456789012:my-queue", tagMap["queue"])
+	assert.Equal(t, "arn:aws:iam::123456789012:role/my-role", tagMap["role"])
+	assert.Equal(t, "a=b", tagMap["query"])
+}
+
+func TestAddTagPreserveCase(t *testing.T) {
+	tagMap := make(map[string]string)
+	opts := TagOptions{PreserveCaseKeys: []string{"git.commit.sha"}}
+	addTag(tagMap, "Git.Commit.Sha:AbC123", opts)
+	addTag(tagMap, "Other:VaLuE", opts)
+	assert.Equal(t, "AbC123", tagMap["git.commit.sha"])
+	assert.Equal(t, "value", tagMap["other"])
+}
+
+func TestBuildTagMapWithOptionsPreserveCase(t *testing.T) {
+	os.Setenv("DD_VERSION", "v1.2.3-RC1")
+	defer os.Unsetenv("DD_VERSION")
+	os.Setenv("DD_SERVICE", "MyService")
+	defer os.Unsetenv("DD_SERVICE")
+
+	arn := "arn:aws:lambda:us-east-1:123456789012:function:My-Function"
+	configTags := []string{"git.commit.sha:DeadBEEF", "TEAM:Serverless"}
+
+	tagMap := BuildTagMapWithOptions(arn, configTags, TagOptions{PreserveCaseKeys: []string{"version", "git.commit.sha"}})
+	assert.Equal(t, "v1.2.3-RC1", tagMap["version"])
+	assert.Equal(t, "DeadBEEF", tagMap["git.commit.sha"])
+	assert.Equal(t, "myservice", tagMap["service"])
+	assert.Equal(t, "serverless", tagMap["team"])
+	assert.Equal(t, "my-function", tagMap["functionname"])
+
+	// the default behavior lowercases all the values
+	tagMap = BuildTagMap(arn, configTags)
+	assert.Equal(t, "v1.2.3-rc1", tagMap["version"])
+	assert.Equal(t, "deadbeef", tagMap["git.commit.sha"])
+}
+
 func TestAddColdStartTagWithoutColdStart(t *testing.T) {
 	generatedTags := AddColdStartTag([]string{
 		"myTagName0:myTagValue0",
@@ -280,7 +513,7 @@ func TestBuildTagMapWithRuntimeAndMemoryTag(t *testing.T) {
 	os.Setenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE", "128")
 	arn := "arn:aws:lambda:us-east-1:123456789012:function:my-function"
 	tagMap := BuildTagMap(arn, []string{"tag0:value0", "TAG1:VALUE1"})
-	assert.Equal(t, 15, len(tagMap))
+	assert.Equal(t, 17, len(tagMap))
 	assert.Equal(t, "lambda", tagMap["_dd.origin"])
 	assert.Equal(t, "1", tagMap["_dd.compute_stats"])
 	assert.Equal(t, "arn:aws:lambda:us-east-1:123456789012:function:my-function", tagMap["function_arn"])
@@ -297,11 +530,109 @@ func TestBuildTagMapWithRuntimeAndMemoryTag(t *testing.T) {
 	assert.True(t, tagMap["architecture"] == X86LambdaPlatform || tagMap["architecture"] == ArmLambdaPlatform)
 }
 
+func TestGetPackageType(t *testing.T) {
+	debianRelease := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(debianRelease, "os-release"), []byte("NAME=\"Debian GNU/Linux\"\nID=debian\n"), 0644))
+
+	t.Run("image", func(t *testing.T) {
+		t.Setenv("AWS_LAMBDA_INITIALIZATION_TYPE", "on-demand")
+		assert.Equal(t, ImagePackageType, getPackageType(debianRelease))
+	})
+
+	t.Run("amazon linux", func(t *testing.T) {
+		// either a .zip archive or a container image built on an AWS base image
+		t.Setenv("AWS_LAMBDA_INITIALIZATION_TYPE", "on-demand")
+		assert.Equal(t, "", getPackageType("./testValid"))
+	})
+
+	t.Run("no os-release", func(t *testing.T) {
+		t.Setenv("AWS_LAMBDA_INITIALIZATION_TYPE", "on-demand")
+		assert.Equal(t, "", getPackageType("/invalid/path"))
+	})
+
+	t.Run("outside of lambda", func(t *testing.T) {
+		t.Setenv("AWS_LAMBDA_INITIALIZATION_TYPE", "")
+		assert.Equal(t, "", getPackageType(debianRelease))
+	})
+}
+
+func TestGetCPUVendor(t *testing.T) {
+	tests := []struct {
+		name         string
+		procPath     string
+		architecture string
+		expected     string
+	}{
+		{"graviton2", "./testCpuInfoGraviton2", ArmLambdaPlatform, "arm64_graviton2"},
+		{"graviton3", "./testCpuInfoGraviton3", ArmLambdaPlatform, "arm64_graviton3"},
+		{"intel", "./testCpuInfoIntel", X86LambdaPlatform, "x86_64_intel"},
+		{"unknown arm core", "./testCpuInfoUnknown", ArmLambdaPlatform, ArmLambdaPlatform},
+		{"mismatched architecture", "./testCpuInfoIntel", ArmLambdaPlatform, ArmLambdaPlatform},
+		{"invalid path", "/invalid/path", X86LambdaPlatform, X86LambdaPlatform},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, getCPUVendor(tt.procPath, tt.architecture))
+		})
+	}
+}
+
+func TestBuildTagMapWithCPUVendor(t *testing.T) {
+	arn := "arn:aws:lambda:us-east-1:123456789012:function:my-function"
+	tagMap := BuildTagMap(arn, []string{})
+	// Result of this test depends on build environment
+	assert.True(t, strings.HasPrefix(tagMap[CPUVendorKey], tagMap[ArchitectureKey]))
+}
+
 func TestGetRuntimeFound(t *testing.T) {
 	result := getRuntime("../proc/testData", "./testValidData", "AWS_EXECUTION_ENV")
 	assert.Equal(t, "nodejs14.x", result)
 }
 
+func TestGetRuntimeFoundIgnoresHint(t *testing.T) {
+	t.Setenv("DD_RUNTIME", "go")
+	result := getRuntime("../proc/testData", "./testValidData", "AWS_EXECUTION_ENV")
+	assert.Equal(t, "nodejs14.x", result)
+}
+
+func TestGetRuntimeFromHint(t *testing.T) {
+	t.Setenv("DD_RUNTIME", "go")
+	result := getRuntime("/invalid/path", "./testValid", "AWS_EXECUTION_ENV")
+	assert.Equal(t, "go", result)
+}
+
+func TestGetRuntimeCustom(t *testing.T) {
+	t.Setenv("DD_RUNTIME", "")
+	result := getRuntime("/invalid/path", "./testValid", "AWS_EXECUTION_ENV")
+	assert.Equal(t, "provided.al2", result)
+
+	result = getRuntime("/invalid/path", "/invalid/path", "AWS_EXECUTION_ENV")
+	assert.Equal(t, "unknown", result)
+}
+
+func TestGetCachedRuntime(t *testing.T) {
+	ResetRuntimeCache()
+	defer ResetRuntimeCache()
+
+	calls := 0
+	resolveRuntime = func(procPath string, osReleasePath string, varName string) string {
+		calls++
+		return getRuntime(procPath, osReleasePath, varName)
+	}
+	defer func() { resolveRuntime = getRuntime }()
+
+	arn := "arn:aws:lambda:us-east-1:123456789012:function:my-function"
+	for i := 0; i < 3; i++ {
+		tagMap := BuildTagMap(arn, []string{})
+		assert.True(t, tagMap["runtime"] == "unknown" || tagMap["runtime"] == "provided.al2")
+	}
+	assert.Equal(t, 1, calls)
+
+	ResetRuntimeCache()
+	assert.Equal(t, "nodejs14.x", getCachedRuntime("../proc/testData", "./testValidData", "AWS_EXECUTION_ENV"))
+	assert.Equal(t, 2, calls)
+}
+
 func TestExtractRuntimeFromOsReleaseFileValid(t *testing.T) {
 	result := getRuntimeFromOsReleaseFile("./testValid")
 	assert.Equal(t, "provided.al2", result)
@@ -333,3 +664,89 @@ func TestCleanRuntimeInvalid(t *testing.T) {
 	}
 	assert.Equal(t, "", cleanRuntimes(runtimes))
 }
+
+func TestFormatTagsCappedWithinBudget(t *testing.T) {
+	tags := []string{"tag1:value1", "functionname:my-function", "tag0:value0"}
+	formatted, dropped := FormatTagsCapped(tags, 100)
+	assert.Equal(t, "functionname:my-function,tag0:value0,tag1:value1", formatted)
+	assert.Equal(t, 0, dropped)
+
+	// exactly at the budget
+	formatted, dropped = FormatTagsCapped(tags, len("functionname:my-function,tag0:value0,tag1:value1"))
+	assert.Equal(t, "functionname:my-function,tag0:value0,tag1:value1", formatted)
+	assert.Equal(t, 0, dropped)
+
+	// the input slice is left untouched
+	assert.Equal(t, []string{"tag1:value1", "functionname:my-function", "tag0:value0"}, tags)
+}
+
+func TestFormatTagsCappedOverBudget(t *testing.T) {
+	tags := []string{"tag1:value1", "functionname:my-function", "tag0:value0", "region:us-east-1"}
+
+	// reserved tags are kept first
+	formatted, dropped := FormatTagsCapped(tags, len("functionname:my-function,region:us-east-1"))
+	assert.Equal(t, "functionname:my-function,region:us-east-1", formatted)
+	assert.Equal(t, 2, dropped)
+
+	// then user tags in lexicographic order
+	formatted, dropped = FormatTagsCapped(tags, len("functionname:my-function,region:us-east-1,tag0:value0"))
+	assert.Equal(t, "functionname:my-function,region:us-east-1,tag0:value0", formatted)
+	assert.Equal(t, 1, dropped)
+
+	formatted, dropped = FormatTagsCapped(tags, 5)
+	assert.Equal(t, "", formatted)
+	assert.Equal(t, 4, dropped)
+}
+
+func TestFormatTagsCappedNoCap(t *testing.T) {
+	formatted, dropped := FormatTagsCapped([]string{"tag1:value1", "tag0:value0"}, 0)
+	assert.Equal(t, "tag0:value0,tag1:value1", formatted)
+	assert.Equal(t, 0, dropped)
+}
+
+func TestAddColdStartDurationTag(t *testing.T) {
+	tags := []string{
+		"myTagName0:myTagValue0",
+	}
+
+	assert.Equal(t, []string{
+		"myTagName0:myTagValue0",
+		"cold_start_duration_ms:250",
+	}, AddColdStartDurationTag(tags, 250*time.Millisecond))
+
+	assert.Equal(t, []string{
+		"myTagName0:myTagValue0",
+		"cold_start_duration_ms:1235",
+	}, AddColdStartDurationTag(tags, 1234600*time.Microsecond))
+
+	assert.Equal(t, []string{
+		"myTagName0:myTagValue0",
+		"cold_start_duration_ms:1",
+	}, AddColdStartDurationTag(tags, 500*time.Microsecond))
+}
+
+func TestAddColdStartDurationTagNotPositive(t *testing.T) {
+	tags := []string{
+		"myTagName0:myTagValue0",
+	}
+	assert.Equal(t, tags, AddColdStartDurationTag(tags, 0))
+	assert.Equal(t, tags, AddColdStartDurationTag(tags, -time.Second))
+}
+
+func TestResolveResourceTag(t *testing.T) {
+	tests := []struct {
+		name      string
+		qualifier string
+		expected  string
+	}{
+		{name: "latest", qualifier: "$LATEST", expected: "my-function"},
+		{name: "numeric version", qualifier: "12", expected: "my-function:12"},
+		{name: "alias", qualifier: "prod", expected: "my-function:prod"},
+		{name: "empty qualifier", qualifier: "", expected: "my-function"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ResolveResourceTag("my-function", tt.qualifier))
+		})
+	}
+}
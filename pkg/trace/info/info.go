@@ -31,9 +31,6 @@ var (
 
 	// TODO: move from package globals to a clean single struct
 
-	traceWriterInfo TraceWriterInfo
-	statsWriterInfo StatsWriterInfo
-
 	watchdogInfo     watchdog.Info
 	rateByService    map[string]float64
 	rateLimiterStats RateLimiterStats
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package info
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+const openMetricsPrefix = "datadog_trace_agent_"
+
+// openMetric is a single metric family with a single sample
+type openMetric struct {
+	name  string
+	typ   string
+	help  string
+	value float64
+}
+
+// WriteOpenMetrics writes the trace and stats writer info to w using the OpenMetrics text format,
+// including the HELP and TYPE metadata of each metric family and the final EOF marker.
+func WriteOpenMetrics(w io.Writer) error {
+	metrics := writerOpenMetrics(loadTraceWriterInfo(), loadStatsWriterInfo())
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		name := openMetricsPrefix + m.name
+		sample := name
+		if m.typ == "counter" {
+			sample += "_total"
+		}
+		bw.WriteString("# HELP " + name + " " + m.help + "\n")
+		bw.WriteString("# TYPE " + name + " " + m.typ + "\n")
+		bw.WriteString(sample + " " + strconv.FormatFloat(m.value, 'g', -1, 64) + "\n")
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+// writerOpenMetrics returns the metrics exported for the trace and stats writer info
+func writerOpenMetrics(twi *TraceWriterInfo, swi *StatsWriterInfo) []openMetric {
+	counter := func(name, help string, value int64) openMetric {
+		return openMetric{name: name, typ: "counter", help: help, value: float64(value)}
+	}
+	gauge := func(name, help string, value float64) openMetric {
+		return openMetric{name: name, typ: "gauge", help: help, value: value}
+	}
+
+	return []openMetric{
+		counter("trace_writer_payloads", "Number of trace payloads sent.", twi.Payloads.Load()),
+		counter("trace_writer_traces", "Number of traces sent.", twi.Traces.Load()),
+		counter("trace_writer_events", "Number of APM events sent.", twi.Events.Load()),
+		counter("trace_writer_spans", "Number of spans sent.", twi.Spans.Load()),
+		counter("trace_writer_errors", "Number of errors encountered while sending trace payloads.", twi.Errors.Load()),
+		counter("trace_writer_retries", "Number of retries of trace payloads.", twi.Retries.Load()),
+		counter("trace_writer_bytes", "Number of compressed bytes of trace payloads sent.", twi.Bytes.Load()),
+		counter("trace_writer_bytes_uncompressed", "Number of uncompressed bytes of trace payloads sent.", twi.BytesUncompressed.Load()),
+		counter("trace_writer_bytes_estimated", "Estimated number of bytes of trace payloads sent.", twi.BytesEstimated.Load()),
+		counter("trace_writer_single_max_size", "Number of traces exceeding the maximum payload size on their own.", twi.SingleMaxSize.Load()),
+		gauge("trace_writer_compression_ratio", "Ratio of uncompressed to compressed bytes of trace payloads.", twi.CompressionRatio()),
+		gauge("trace_writer_spans_per_trace", "Average number of spans per trace sent.", ratio(twi.Spans.Load(), twi.Traces.Load())),
+		counter("stats_writer_payloads", "Number of stats payloads sent.", swi.Payloads.Load()),
+		counter("stats_writer_client_payloads", "Number of client stats payloads sent.", swi.ClientPayloads.Load()),
+		counter("stats_writer_stats_buckets", "Number of stats buckets sent.", swi.StatsBuckets.Load()),
+		counter("stats_writer_stats_entries", "Number of stats entries sent.", swi.StatsEntries.Load()),
+		counter("stats_writer_errors", "Number of errors encountered while sending stats payloads.", swi.Errors.Load()),
+		counter("stats_writer_retries", "Number of retries of stats payloads.", swi.Retries.Load()),
+		counter("stats_writer_splits", "Number of stats payloads split because of their size.", swi.Splits.Load()),
+		counter("stats_writer_bytes", "Number of bytes of stats payloads sent.", swi.Bytes.Load()),
+		gauge("stats_writer_entries_per_bucket", "Average number of stats entries per bucket sent.", ratio(swi.StatsEntries.Load(), swi.StatsBuckets.Load())),
+	}
+}
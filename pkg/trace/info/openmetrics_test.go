@@ -0,0 +1,51 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package info
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	defer func() {
+		ResetTraceWriterInfo()
+		ResetStatsWriterInfo()
+	}()
+	UpdateTraceWriterInfo(TraceWriterInfo{
+		Payloads:          atom(4),
+		Traces:            atom(10),
+		Events:            atom(3),
+		Spans:             atom(45),
+		Errors:            atom(1),
+		Retries:           atom(2),
+		Bytes:             atom(1000),
+		BytesUncompressed: atom(2500),
+		BytesEstimated:    atom(2600),
+		SingleMaxSize:     atom(0),
+	})
+	UpdateStatsWriterInfo(StatsWriterInfo{
+		Payloads:       atom(5),
+		ClientPayloads: atom(6),
+		StatsBuckets:   atom(8),
+		StatsEntries:   atom(20),
+		Errors:         atom(0),
+		Retries:        atom(1),
+		Splits:         atom(2),
+		Bytes:          atom(4096),
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteOpenMetrics(&buf))
+
+	expected, err := ioutil.ReadFile("./testdata/writer.openmetrics")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), buf.String())
+}
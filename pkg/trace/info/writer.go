@@ -7,10 +7,19 @@ package info
 
 import (
 	"encoding/json"
+	"math"
+	"time"
 
 	"go.uber.org/atomic"
 )
 
+// traceWriterInfo and statsWriterInfo hold pointers to the latest snapshots of the writer
+// info, replaced as a whole on update so that updates and reads don't block each other.
+var (
+	traceWriterInfo atomic.Value // *TraceWriterInfo
+	statsWriterInfo atomic.Value // *StatsWriterInfo
+)
+
 // TraceWriterInfo represents statistics from the trace writer.
 type TraceWriterInfo struct {
 	// all atomic values are included as values in this struct, to simplify
@@ -29,6 +38,48 @@ type TraceWriterInfo struct {
 	SingleMaxSize     atomic.Int64
 }
 
+// TraceWriterThroughput represents the rates of the trace writer between two TraceWriterInfo snapshots.
+type TraceWriterThroughput struct {
+	SpansPerSec  float64
+	TracesPerSec float64
+	BytesPerSec  float64
+}
+
+// Throughput returns the rates of the trace writer since the prev snapshot, taken interval earlier.
+// Rates are 0 when the interval is not positive or when a counter went down, as happens on reset.
+func (twi TraceWriterInfo) Throughput(prev TraceWriterInfo, interval time.Duration) TraceWriterThroughput {
+	if interval <= 0 {
+		return TraceWriterThroughput{}
+	}
+	rate := func(prev, cur int64) float64 {
+		if cur < prev {
+			return 0
+		}
+		return float64(cur-prev) / interval.Seconds()
+	}
+	return TraceWriterThroughput{
+		SpansPerSec:  rate(prev.Spans.Load(), twi.Spans.Load()),
+		TracesPerSec: rate(prev.Traces.Load(), twi.Traces.Load()),
+		BytesPerSec:  rate(prev.Bytes.Load(), twi.Bytes.Load()),
+	}
+}
+
+// CompressionRatio returns the ratio of uncompressed to compressed bytes of the trace payloads,
+// or 0 if no bytes were sent.
+func (twi TraceWriterInfo) CompressionRatio() float64 {
+	return ratio(twi.BytesUncompressed.Load(), twi.Bytes.Load())
+}
+
+// EstimationError returns the relative error of the estimated size of the trace payloads
+// compared to their uncompressed size, or 0 if no bytes were sent.
+func (twi TraceWriterInfo) EstimationError() float64 {
+	uncompressed := twi.BytesUncompressed.Load()
+	if uncompressed == 0 {
+		return 0
+	}
+	return math.Abs(float64(twi.BytesEstimated.Load()-uncompressed)) / float64(uncompressed)
+}
+
 // StatsWriterInfo represents statistics from the stats writer.
 type StatsWriterInfo struct {
 	// all atomic values are included as values in this struct, to simplify
@@ -47,15 +98,23 @@ type StatsWriterInfo struct {
 
 // UpdateTraceWriterInfo updates internal trace writer stats
 func UpdateTraceWriterInfo(tws TraceWriterInfo) {
-	infoMu.Lock()
-	defer infoMu.Unlock()
-	traceWriterInfo = tws
+	traceWriterInfo.Store(&tws)
+}
+
+// ResetTraceWriterInfo resets the trace writer stats to zero
+func ResetTraceWriterInfo() {
+	traceWriterInfo.Store(&TraceWriterInfo{})
+}
+
+func loadTraceWriterInfo() *TraceWriterInfo {
+	if tws, ok := traceWriterInfo.Load().(*TraceWriterInfo); ok {
+		return tws
+	}
+	return &TraceWriterInfo{}
 }
 
 func publishTraceWriterInfo() interface{} {
-	infoMu.RLock()
-	defer infoMu.RUnlock()
-	return traceWriterInfo
+	return *loadTraceWriterInfo()
 }
 
 // MarshalJSON implements encoding/json.MarshalJSON.
@@ -77,15 +136,23 @@ func (twi TraceWriterInfo) MarshalJSON() ([]byte, error) {
 
 // UpdateStatsWriterInfo updates internal stats writer stats
 func UpdateStatsWriterInfo(sws StatsWriterInfo) {
-	infoMu.Lock()
-	defer infoMu.Unlock()
-	statsWriterInfo = sws
+	statsWriterInfo.Store(&sws)
+}
+
+// ResetStatsWriterInfo resets the stats writer stats to zero
+func ResetStatsWriterInfo() {
+	statsWriterInfo.Store(&StatsWriterInfo{})
+}
+
+func loadStatsWriterInfo() *StatsWriterInfo {
+	if sws, ok := statsWriterInfo.Load().(*StatsWriterInfo); ok {
+		return sws
+	}
+	return &StatsWriterInfo{}
 }
 
 func publishStatsWriterInfo() interface{} {
-	infoMu.RLock()
-	defer infoMu.RUnlock()
-	return statsWriterInfo
+	return *loadStatsWriterInfo()
 }
 
 // MarshalJSON implements encoding/json.MarshalJSON.
@@ -102,3 +169,37 @@ func (swi StatsWriterInfo) MarshalJSON() ([]byte, error) {
 	}
 	return json.Marshal(asMap)
 }
+
+// ErrorRate returns the number of errors per trace payload sent, or 0 if no payload was sent.
+func (twi TraceWriterInfo) ErrorRate() float64 {
+	return ratio(twi.Errors.Load(), twi.Payloads.Load())
+}
+
+// RetryRate returns the number of retries per trace payload sent, or 0 if no payload was sent.
+func (twi TraceWriterInfo) RetryRate() float64 {
+	return ratio(twi.Retries.Load(), twi.Payloads.Load())
+}
+
+// ErrorRate returns the number of errors per stats payload sent, or 0 if no payload was sent.
+func (swi StatsWriterInfo) ErrorRate() float64 {
+	return ratio(swi.Errors.Load(), swi.Payloads.Load())
+}
+
+// RetryRate returns the number of retries per stats payload sent, or 0 if no payload was sent.
+func (swi StatsWriterInfo) RetryRate() float64 {
+	return ratio(swi.Retries.Load(), swi.Payloads.Load())
+}
+
+// SplitRate returns the number of splits per stats payload sent, or 0 if no payload was sent.
+// A high rate hints that the stats are flushed in payloads too large to be sent at once.
+func (swi StatsWriterInfo) SplitRate() float64 {
+	return ratio(swi.Splits.Load(), swi.Payloads.Load())
+}
+
+// ratio returns a/b, or 0 if b is 0
+func ratio(a, b int64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
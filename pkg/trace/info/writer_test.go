@@ -6,11 +6,17 @@
 package info
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestPublishTraceWriterInfo(t *testing.T) {
-	traceWriterInfo = TraceWriterInfo{
+	UpdateTraceWriterInfo(TraceWriterInfo{
 		// do not use field names here, to ensure we cover all fields
 		atom(1),
 		atom(2),
@@ -22,7 +28,7 @@ func TestPublishTraceWriterInfo(t *testing.T) {
 		atom(8),
 		atom(9),
 		atom(10),
-	}
+	})
 
 	testExpvarPublish(t, publishTraceWriterInfo,
 		map[string]interface{}{
@@ -41,7 +47,7 @@ func TestPublishTraceWriterInfo(t *testing.T) {
 }
 
 func TestPublishStatsWriterInfo(t *testing.T) {
-	statsWriterInfo = StatsWriterInfo{
+	UpdateStatsWriterInfo(StatsWriterInfo{
 		// do not use field names here, to ensure we cover all fields
 		atom(1),
 		atom(2),
@@ -51,7 +57,7 @@ func TestPublishStatsWriterInfo(t *testing.T) {
 		atom(6),
 		atom(7),
 		atom(8),
-	}
+	})
 
 	testExpvarPublish(t, publishStatsWriterInfo,
 		map[string]interface{}{
@@ -75,3 +81,143 @@ func TestPublishRateByService(t *testing.T) {
 			"foo": 123.0,
 		})
 }
+
+func TestTraceWriterInfoThroughput(t *testing.T) {
+	prev := TraceWriterInfo{Spans: atom(100), Traces: atom(10), Bytes: atom(1000)}
+	cur := TraceWriterInfo{Spans: atom(400), Traces: atom(40), Bytes: atom(4000)}
+
+	t.Run("normal", func(t *testing.T) {
+		assert.Equal(t, TraceWriterThroughput{
+			SpansPerSec:  30,
+			TracesPerSec: 3,
+			BytesPerSec:  300,
+		}, cur.Throughput(prev, 10*time.Second))
+	})
+
+	t.Run("zero interval", func(t *testing.T) {
+		assert.Equal(t, TraceWriterThroughput{}, cur.Throughput(prev, 0))
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		assert.Equal(t, TraceWriterThroughput{}, prev.Throughput(cur, 10*time.Second))
+	})
+}
+
+func TestTraceWriterInfoCompression(t *testing.T) {
+	t.Run("zero bytes", func(t *testing.T) {
+		var twi TraceWriterInfo
+		assert.Equal(t, 0.0, twi.CompressionRatio())
+		assert.Equal(t, 0.0, twi.EstimationError())
+	})
+
+	t.Run("overestimated", func(t *testing.T) {
+		twi := TraceWriterInfo{Bytes: atom(250), BytesUncompressed: atom(1000), BytesEstimated: atom(1100)}
+		assert.Equal(t, 4.0, twi.CompressionRatio())
+		assert.InDelta(t, 0.1, twi.EstimationError(), 1e-9)
+	})
+
+	t.Run("underestimated", func(t *testing.T) {
+		twi := TraceWriterInfo{Bytes: atom(500), BytesUncompressed: atom(1000), BytesEstimated: atom(800)}
+		assert.Equal(t, 2.0, twi.CompressionRatio())
+		assert.InDelta(t, 0.2, twi.EstimationError(), 1e-9)
+	})
+}
+
+func TestWriterInfoConcurrentUpdate(t *testing.T) {
+	defer ResetTraceWriterInfo()
+	defer ResetStatsWriterInfo()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int64) {
+			defer wg.Done()
+			for j := int64(0); j < 100; j++ {
+				UpdateTraceWriterInfo(TraceWriterInfo{Payloads: atom(i*100 + j)})
+				UpdateStatsWriterInfo(StatsWriterInfo{Payloads: atom(i*100 + j)})
+			}
+		}(int64(i))
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, err := json.Marshal(publishTraceWriterInfo())
+				assert.NoError(t, err)
+				_, err = json.Marshal(publishStatsWriterInfo())
+				assert.NoError(t, err)
+				assert.NoError(t, WriteOpenMetrics(ioutil.Discard))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestResetWriterInfo(t *testing.T) {
+	UpdateTraceWriterInfo(TraceWriterInfo{Payloads: atom(1), Spans: atom(10), Bytes: atom(100)})
+	UpdateStatsWriterInfo(StatsWriterInfo{Payloads: atom(2), StatsBuckets: atom(20), Bytes: atom(200)})
+
+	ResetTraceWriterInfo()
+	ResetStatsWriterInfo()
+
+	testExpvarPublish(t, publishTraceWriterInfo,
+		map[string]interface{}{
+			"Payloads":          0.0,
+			"Traces":            0.0,
+			"Events":            0.0,
+			"Spans":             0.0,
+			"Errors":            0.0,
+			"Retries":           0.0,
+			"Bytes":             0.0,
+			"BytesUncompressed": 0.0,
+			"BytesEstimated":    0.0,
+			"SingleMaxSize":     0.0,
+		})
+	testExpvarPublish(t, publishStatsWriterInfo,
+		map[string]interface{}{
+			"Payloads":       0.0,
+			"ClientPayloads": 0.0,
+			"StatsBuckets":   0.0,
+			"StatsEntries":   0.0,
+			"Errors":         0.0,
+			"Retries":        0.0,
+			"Splits":         0.0,
+			"Bytes":          0.0,
+		})
+}
+
+func TestStatsWriterInfoSplitRate(t *testing.T) {
+	t.Run("zero payloads", func(t *testing.T) {
+		swi := StatsWriterInfo{Splits: atom(3)}
+		assert.Equal(t, 0.0, swi.SplitRate())
+	})
+
+	t.Run("normal", func(t *testing.T) {
+		swi := StatsWriterInfo{Payloads: atom(8), Splits: atom(2)}
+		assert.Equal(t, 0.25, swi.SplitRate())
+	})
+}
+
+func TestWriterInfoErrorAndRetryRates(t *testing.T) {
+	t.Run("trace writer", func(t *testing.T) {
+		twi := TraceWriterInfo{Payloads: atom(20), Errors: atom(2), Retries: atom(5)}
+		assert.Equal(t, 0.1, twi.ErrorRate())
+		assert.Equal(t, 0.25, twi.RetryRate())
+	})
+
+	t.Run("trace writer zero payloads", func(t *testing.T) {
+		twi := TraceWriterInfo{Errors: atom(2), Retries: atom(5)}
+		assert.Equal(t, 0.0, twi.ErrorRate())
+		assert.Equal(t, 0.0, twi.RetryRate())
+	})
+
+	t.Run("stats writer", func(t *testing.T) {
+		swi := StatsWriterInfo{Payloads: atom(10), Errors: atom(1), Retries: atom(4)}
+		assert.Equal(t, 0.1, swi.ErrorRate())
+		assert.Equal(t, 0.4, swi.RetryRate())
+	})
+
+	t.Run("stats writer zero payloads", func(t *testing.T) {
+		swi := StatsWriterInfo{Errors: atom(1), Retries: atom(4)}
+		assert.Equal(t, 0.0, swi.ErrorRate())
+		assert.Equal(t, 0.0, swi.RetryRate())
+	})
+}
@@ -1,19 +1,114 @@
 package json
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
 // GetNestedValue returns the value in the map specified by the array keys,
 // where each value is another depth level in the map.
-// Returns nil if the map doesn't contain the nested key.
+// Numeric keys can be used to index into arrays, e.g. ("items", "0", "name").
+// Returns nil if the map doesn't contain the nested key, or if no key is given.
 func GetNestedValue(inputMap map[string]interface{}, keys ...string) interface{} {
+	if len(keys) == 0 {
+		return nil
+	}
 	val, exists := inputMap[keys[0]]
 	if !exists {
 		return nil
 	}
-	if len(keys) == 1 {
+	return getNestedValue(val, keys[1:])
+}
+
+// getNestedValue descends into val, which can either be a map or an array,
+// following the remaining keys.
+func getNestedValue(val interface{}, keys []string) interface{} {
+	if len(keys) == 0 {
 		return val
 	}
-	innerMap, ok := val.(map[string]interface{})
-	if !ok {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return GetNestedValue(v, keys...)
+	case []interface{}:
+		idx, err := strconv.Atoi(keys[0])
+		if err != nil || idx < 0 || idx >= len(v) {
+			return nil
+		}
+		return getNestedValue(v[idx], keys[1:])
+	default:
 		return nil
 	}
-	return GetNestedValue(innerMap, keys[1:]...)
+}
+
+// GetNestedValueWithDefault returns the value in the map specified by the array keys,
+// or def if the map doesn't contain the nested key or if its value is null.
+func GetNestedValueWithDefault(inputMap map[string]interface{}, def interface{}, keys ...string) interface{} {
+	if val := GetNestedValue(inputMap, keys...); val != nil {
+		return val
+	}
+	return def
+}
+
+// GetNestedString returns the string value in the map specified by the array keys.
+// ok is false if the map doesn't contain the nested key or if its value isn't a string.
+func GetNestedString(inputMap map[string]interface{}, keys ...string) (string, bool) {
+	val, ok := GetNestedValue(inputMap, keys...).(string)
+	return val, ok
+}
+
+// GetNestedInt returns the integer value in the map specified by the array keys.
+// Both float64 (the default type of JSON numbers) and json.Number values are accepted,
+// as long as they represent a whole number.
+// ok is false if the map doesn't contain the nested key or if its value isn't an integer.
+func GetNestedInt(inputMap map[string]interface{}, keys ...string) (int64, bool) {
+	switch v := GetNestedValue(inputMap, keys...).(type) {
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		i, err := v.Int64()
+		if err != nil {
+			return 0, false
+		}
+		return i, true
+	default:
+		return 0, false
+	}
+}
+
+// SetNestedValue sets the value in the map specified by the array keys,
+// where each value is another depth level in the map. Intermediate levels
+// are created as needed.
+// Returns an error if no key is given, or if an existing intermediate value isn't a map.
+func SetNestedValue(inputMap map[string]interface{}, value interface{}, keys ...string) error {
+	if len(keys) == 0 {
+		return errors.New("no key given")
+	}
+	if inputMap == nil {
+		return errors.New("cannot set a value in a nil map")
+	}
+
+	current := inputMap
+	for i, key := range keys[:len(keys)-1] {
+		val, exists := current[key]
+		if !exists || val == nil {
+			innerMap := make(map[string]interface{})
+			current[key] = innerMap
+			current = innerMap
+			continue
+		}
+		innerMap, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value at %s is not a map: %T", strings.Join(keys[:i+1], "."), val)
+		}
+		current = innerMap
+	}
+	current[keys[len(keys)-1]] = value
+	return nil
 }
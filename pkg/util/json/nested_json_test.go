@@ -1,6 +1,7 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 	"testing"
 
@@ -62,3 +63,167 @@ func TestGetNestedValueExistsEarly(t *testing.T) {
 
 	assert.Equal(t, nil, GetNestedValue(jsonMap, "key2", "key1"))
 }
+
+func TestGetNestedValueArray(t *testing.T) {
+	rawJSON := []byte(`{"items": [{"name": "first"}, {"name": "second", "tags": ["a", "b"]}], "matrix": [[1, 2], [3, 4]]}`)
+	jsonMap := make(map[string]interface{})
+	err := json.Unmarshal(rawJSON, &jsonMap)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "first", GetNestedValue(jsonMap, "items", "0", "name"))
+	assert.Equal(t, "second", GetNestedValue(jsonMap, "items", "1", "name"))
+	assert.Equal(t, "b", GetNestedValue(jsonMap, "items", "1", "tags", "1"))
+	assert.Equal(t, float64(3), GetNestedValue(jsonMap, "matrix", "1", "0"))
+	assert.Equal(t, map[string]interface{}{"name": "first"}, GetNestedValue(jsonMap, "items", "0"))
+}
+
+func TestGetNestedValueArrayDoesntExist(t *testing.T) {
+	rawJSON := []byte(`{"items": [{"name": "first"}], "key": {"key2": "val"}}`)
+	jsonMap := make(map[string]interface{})
+	err := json.Unmarshal(rawJSON, &jsonMap)
+	assert.Nil(t, err)
+
+	assert.Equal(t, nil, GetNestedValue(jsonMap, "items", "name"))
+	assert.Equal(t, nil, GetNestedValue(jsonMap, "items", "1", "name"))
+	assert.Equal(t, nil, GetNestedValue(jsonMap, "items", "-1", "name"))
+	assert.Equal(t, nil, GetNestedValue(jsonMap, "items", "0", "doesnt_exist"))
+	assert.Equal(t, nil, GetNestedValue(jsonMap, "key", "0"))
+}
+
+func TestGetNestedValueWithDefault(t *testing.T) {
+	rawJSON := []byte(`{"key":"val", "key2": {"key3": null, "key4": false}}`)
+	jsonMap := make(map[string]interface{})
+	err := json.Unmarshal(rawJSON, &jsonMap)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "val", GetNestedValueWithDefault(jsonMap, "default", "key"))
+	assert.Equal(t, false, GetNestedValueWithDefault(jsonMap, true, "key2", "key4"))
+	assert.Equal(t, "default", GetNestedValueWithDefault(jsonMap, "default", "doesnt_exist"))
+	assert.Equal(t, "default", GetNestedValueWithDefault(jsonMap, "default", "key2", "key3"))
+	assert.Equal(t, nil, GetNestedValueWithDefault(jsonMap, nil, "key2", "doesnt_exist"))
+}
+
+func TestGetNestedString(t *testing.T) {
+	rawJSON := []byte(`{"key":"val", "key2": {"key3": 42, "key4": null}}`)
+	jsonMap := make(map[string]interface{})
+	err := json.Unmarshal(rawJSON, &jsonMap)
+	assert.Nil(t, err)
+
+	val, ok := GetNestedString(jsonMap, "key")
+	assert.True(t, ok)
+	assert.Equal(t, "val", val)
+
+	_, ok = GetNestedString(jsonMap, "key2", "key3")
+	assert.False(t, ok)
+
+	_, ok = GetNestedString(jsonMap, "key2", "key4")
+	assert.False(t, ok)
+
+	_, ok = GetNestedString(jsonMap, "doesnt_exist")
+	assert.False(t, ok)
+}
+
+func TestGetNestedInt(t *testing.T) {
+	rawJSON := []byte(`{"key":"val", "key2": {"key3": 42, "key4": 4.2, "key5": -7}}`)
+	jsonMap := make(map[string]interface{})
+	err := json.Unmarshal(rawJSON, &jsonMap)
+	assert.Nil(t, err)
+
+	val, ok := GetNestedInt(jsonMap, "key2", "key3")
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), val)
+
+	val, ok = GetNestedInt(jsonMap, "key2", "key5")
+	assert.True(t, ok)
+	assert.Equal(t, int64(-7), val)
+
+	_, ok = GetNestedInt(jsonMap, "key2", "key4")
+	assert.False(t, ok)
+
+	_, ok = GetNestedInt(jsonMap, "key")
+	assert.False(t, ok)
+
+	_, ok = GetNestedInt(jsonMap, "doesnt_exist")
+	assert.False(t, ok)
+}
+
+func TestGetNestedIntJSONNumber(t *testing.T) {
+	rawJSON := []byte(`{"key": {"key2": 9007199254740993, "key3": 4.2}}`)
+	jsonMap := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(rawJSON))
+	decoder.UseNumber()
+	err := decoder.Decode(&jsonMap)
+	assert.Nil(t, err)
+
+	val, ok := GetNestedInt(jsonMap, "key", "key2")
+	assert.True(t, ok)
+	assert.Equal(t, int64(9007199254740993), val)
+
+	_, ok = GetNestedInt(jsonMap, "key", "key3")
+	assert.False(t, ok)
+}
+
+func TestGetNestedValueNoKeys(t *testing.T) {
+	rawJSON := []byte(`{"key":"val"}`)
+	jsonMap := make(map[string]interface{})
+	err := json.Unmarshal(rawJSON, &jsonMap)
+	assert.Nil(t, err)
+
+	assert.NotPanics(t, func() {
+		assert.Equal(t, nil, GetNestedValue(jsonMap))
+		assert.Equal(t, nil, GetNestedValue(jsonMap, []string{}...))
+		assert.Equal(t, nil, GetNestedValue(nil))
+	})
+	assert.Equal(t, "default", GetNestedValueWithDefault(jsonMap, "default"))
+}
+
+func TestSetNestedValueEmptyMap(t *testing.T) {
+	jsonMap := make(map[string]interface{})
+
+	assert.NoError(t, SetNestedValue(jsonMap, "val", "key", "key2", "key3"))
+	assert.NoError(t, SetNestedValue(jsonMap, 42, "key", "key4"))
+
+	assert.Equal(t, map[string]interface{}{
+		"key": map[string]interface{}{
+			"key2": map[string]interface{}{
+				"key3": "val",
+			},
+			"key4": 42,
+		},
+	}, jsonMap)
+	assert.Equal(t, "val", GetNestedValue(jsonMap, "key", "key2", "key3"))
+}
+
+func TestSetNestedValueExisting(t *testing.T) {
+	rawJSON := []byte(`{"key":"val", "key2": {"key3": "val2", "key4": null}}`)
+	jsonMap := make(map[string]interface{})
+	err := json.Unmarshal(rawJSON, &jsonMap)
+	assert.Nil(t, err)
+
+	assert.NoError(t, SetNestedValue(jsonMap, "new_val", "key2", "key3"))
+	assert.NoError(t, SetNestedValue(jsonMap, "val3", "key2", "key4", "key5"))
+	assert.NoError(t, SetNestedValue(jsonMap, "val4", "key"))
+
+	assert.Equal(t, "new_val", GetNestedValue(jsonMap, "key2", "key3"))
+	assert.Equal(t, "val3", GetNestedValue(jsonMap, "key2", "key4", "key5"))
+	assert.Equal(t, "val4", GetNestedValue(jsonMap, "key"))
+}
+
+func TestSetNestedValueScalarCollision(t *testing.T) {
+	rawJSON := []byte(`{"key":"val", "key2": {"key3": 42}}`)
+	jsonMap := make(map[string]interface{})
+	err := json.Unmarshal(rawJSON, &jsonMap)
+	assert.Nil(t, err)
+
+	assert.Error(t, SetNestedValue(jsonMap, "new_val", "key", "key2"))
+	assert.Error(t, SetNestedValue(jsonMap, "new_val", "key2", "key3", "key4"))
+
+	// the map is left untouched
+	assert.Equal(t, "val", GetNestedValue(jsonMap, "key"))
+	assert.Equal(t, float64(42), GetNestedValue(jsonMap, "key2", "key3"))
+}
+
+func TestSetNestedValueInvalidArgs(t *testing.T) {
+	assert.Error(t, SetNestedValue(make(map[string]interface{}), "val"))
+	assert.Error(t, SetNestedValue(nil, "val", "key"))
+}
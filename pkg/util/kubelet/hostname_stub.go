@@ -11,14 +11,40 @@ package kubelet
 import (
 	"context"
 	"fmt"
+	"os"
+	"strconv"
 )
 
-// GetHostname builds a hostname from the kubernetes nodename and an optional cluster-name
+// nodeNameEnvVars are the environment variables the node name is read from, usually set
+// through the downward API, in order of precedence
+var nodeNameEnvVars = []string{"DD_KUBERNETES_NODE_NAME", "NODE_NAME"}
+
+// hostNetworkEnvVar overrides whether the agent is considered to run with hostNetwork
+const hostNetworkEnvVar = "DD_KUBERNETES_HOST_NETWORK"
+
+// GetHostname returns the kubernetes nodename when it is set in the environment
 func GetHostname(ctx context.Context) (string, error) {
-	return "", fmt.Errorf("kubelet hostname provider is not enabled")
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	for _, envVar := range nodeNameEnvVars {
+		if nodeName := os.Getenv(envVar); nodeName != "" {
+			return nodeName, nil
+		}
+	}
+	return "", fmt.Errorf("kubelet hostname provider is not enabled and no node name is set in the environment")
 }
 
-// IsAgentKubeHostNetwork returns true if the agent is running on a POD with hostNetwork
+// IsAgentKubeHostNetwork returns true if the agent is running on a POD with hostNetwork.
+// Without the kubelet it can't be detected, so it defaults to true unless DD_KUBERNETES_HOST_NETWORK says otherwise.
 func IsAgentKubeHostNetwork() (bool, error) {
-	return true, nil
+	value, ok := os.LookupEnv(hostNetworkEnvVar)
+	if !ok || value == "" {
+		return true, nil
+	}
+	hostNetwork, err := strconv.ParseBool(value)
+	if err != nil {
+		return true, fmt.Errorf("invalid value %q for %s: %w", value, hostNetworkEnvVar, err)
+	}
+	return hostNetwork, nil
 }
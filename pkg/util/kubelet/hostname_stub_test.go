@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !kubelet
+// +build !kubelet
+
+package kubelet
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHostnameFromEnv(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("unset", func(t *testing.T) {
+		t.Setenv("DD_KUBERNETES_NODE_NAME", "")
+		t.Setenv("NODE_NAME", "")
+
+		_, err := GetHostname(ctx)
+		assert.Error(t, err)
+	})
+
+	t.Run("downward API", func(t *testing.T) {
+		t.Setenv("DD_KUBERNETES_NODE_NAME", "")
+		t.Setenv("NODE_NAME", "node-a")
+
+		hostname, err := GetHostname(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "node-a", hostname)
+	})
+
+	t.Run("datadog env var first", func(t *testing.T) {
+		t.Setenv("DD_KUBERNETES_NODE_NAME", "node-b")
+		t.Setenv("NODE_NAME", "node-a")
+
+		hostname, err := GetHostname(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "node-b", hostname)
+	})
+}
+
+func TestIsAgentKubeHostNetworkFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    bool
+		expectedErr bool
+	}{
+		{name: "unset", value: "", expected: true},
+		{name: "true", value: "true", expected: true},
+		{name: "false", value: "false", expected: false},
+		{name: "invalid", value: "maybe", expected: true, expectedErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DD_KUBERNETES_HOST_NETWORK", tt.value)
+
+			hostNetwork, err := IsAgentKubeHostNetwork()
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, hostNetwork)
+		})
+	}
+}
+
+func TestGetHostnameCancelledContext(t *testing.T) {
+	t.Setenv("DD_KUBERNETES_NODE_NAME", "node-a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := GetHostname(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}